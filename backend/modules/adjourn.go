@@ -0,0 +1,214 @@
+// Quoridor Chess 対局の中断と再開
+// カジュアル戦で両プレイヤーが合意した場合に局面をストレージに保存してマッチを終了し、後から同じ局面で再開できるようにする
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	adjournedGameCollection = "adjourned_games" // 中断した対局のストレージコレクション（システムが所有、キーは元のマッチID）
+
+	NotificationCodeAdjournedResumed = 103 // 中断した対局が再開されたことの通知
+
+	minAdjournClockInitial = 10 * time.Minute // 中断できる対局の持ち時間の下限（これより短い早指しは中断できない）
+)
+
+// AdjournedGame - 保存した中断中の対局
+type AdjournedGame struct {
+	GameID         string     `json:"game_id"`                    // 中断した対局のID（元のマッチID）
+	PlayerIDs      []string   `json:"player_ids"`                 // 対局者のユーザーID
+	Variant        string     `json:"variant"`                    // バリアント名
+	State          *GameState `json:"state"`                      // 中断時点のゲーム状態
+	AdjournedAt    int64      `json:"adjourned_at"`               // 中断した時刻（Unix時刻）
+	ResumedMatchID string     `json:"resumed_match_id,omitempty"` // 再開したマッチのID（再開済みの場合）
+}
+
+// ResumeAdjournedRequest - resume_adjourned RPCのリクエスト
+type ResumeAdjournedRequest struct {
+	GameID string `json:"game_id"`
+}
+
+// readAdjournedGame - 中断した対局を読み込む（存在しない場合は nil）
+func readAdjournedGame(ctx context.Context, nk runtime.NakamaModule, gameID string) (*AdjournedGame, string, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: adjournedGameCollection,
+		Key:        gameID,
+	}})
+	if err != nil || len(objects) == 0 {
+		return nil, "", err
+	}
+	game := &AdjournedGame{}
+	if err := json.Unmarshal([]byte(objects[0].GetValue()), game); err != nil {
+		return nil, "", err
+	}
+	return game, objects[0].GetVersion(), nil
+}
+
+// writeAdjournedGame - 中断した対局を保存
+func writeAdjournedGame(ctx context.Context, nk runtime.NakamaModule, game *AdjournedGame, version string) error {
+	value, err := json.Marshal(game)
+	if err != nil {
+		return err
+	}
+	_, err = nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      adjournedGameCollection,
+		Key:             game.GameID,
+		Value:           string(value),
+		Version:         version,
+		PermissionRead:  0,
+		PermissionWrite: 0,
+	}})
+	return err
+}
+
+// handleAdjourn - 中断の提案を処理し、両プレイヤーが合意したら局面を保存する
+// 対局を中断した場合は true を返す（呼び出し側でマッチを終了する）
+func (m *QuoridorChessMatch) handleAdjourn(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, userID string) bool {
+	if !m.gameState.GameStarted || m.gameState.Ranked || m.gameState.TournamentID != "" {
+		m.rejectAction(dispatcher, userID, "adjourn", "adjournment is only available in casual games in progress")
+		return false
+	}
+	// 早指しは中断・再開を挟むと持ち時間の意味がなくなるため中断できない
+	if clock := m.ruleset.ClockInitialMs; clock > 0 && clock < minAdjournClockInitial.Milliseconds() {
+		m.rejectAction(dispatcher, userID, "adjourn", "adjournment is not available with short time controls")
+		return false
+	}
+	if m.gameState.Players[userID] == nil {
+		return false
+	}
+	m.adjournOffers[userID] = true
+
+	// 相手がまだ合意していなければ提案を伝える
+	opponentID := m.opponentOf(userID)
+	if !m.adjournOffers[opponentID] {
+		if presence, ok := m.presences[opponentID]; ok {
+			msg := map[string]interface{}{
+				"type": "adjourn_offered",
+				"data": &AdjournOfferedData{PlayerID: userID},
+			}
+			m.sendMessage(dispatcher, 1, msg, []runtime.Presence{presence}, true)
+		}
+		return false
+	}
+
+	game := &AdjournedGame{
+		GameID:      m.matchID,
+		PlayerIDs:   []string{userID, opponentID},
+		Variant:     m.ruleset.Name,
+		State:       m.gameState,
+		AdjournedAt: time.Now().Unix(),
+	}
+	if err := writeAdjournedGame(ctx, nk, game, ""); err != nil {
+		logger.Error("Failed to store adjourned game %s: %v", m.matchID, err)
+		m.rejectAction(dispatcher, userID, "adjourn", "failed to save the game")
+		m.adjournOffers = make(map[string]bool)
+		return false
+	}
+	m.recordEvent("adjourned", EventSourceServer, "", nil)
+
+	msg := map[string]interface{}{
+		"type": "game_adjourned",
+		"data": &GameAdjournedData{GameID: m.matchID},
+	}
+	m.sendMessage(dispatcher, 1, msg, nil, true)
+	return true
+}
+
+// restoreAdjourned - 中断した対局の局面をこのマッチに復元
+func (m *QuoridorChessMatch) restoreAdjourned(ctx context.Context, nk runtime.NakamaModule, gameID string) error {
+	game, _, err := readAdjournedGame(ctx, nk, gameID)
+	if err != nil {
+		return err
+	}
+	if game == nil || game.State == nil {
+		return runtime.NewError("adjourned game not found", errCodeNotFound)
+	}
+
+	state := game.State
+	state.GameStarted = false
+	state.Winner = ""
+	state.ResultReason = ""
+	state.LastAction = nil
+	state.Ranked = false
+	m.gameState = state
+	m.resumedFrom = gameID
+	return nil
+}
+
+// ResumeAdjourned - 中断した対局を新しいマッチで再開するRPC（対局者のみ）
+// すでに再開済みの場合はそのマッチのIDを返す
+func ResumeAdjourned(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return "", err
+	}
+	req := &ResumeAdjournedRequest{}
+	if err := json.Unmarshal([]byte(payload), req); err != nil || req.GameID == "" || len(req.GameID) > maxMatchIDLength {
+		return "", errInvalidPayload
+	}
+
+	game, version, err := readAdjournedGame(ctx, nk, req.GameID)
+	if err != nil {
+		logger.Error("resume_adjourned: failed to read game: %v", err)
+		return "", runtime.NewError("failed to read adjourned game", errCodeInternal)
+	}
+	if game == nil {
+		return "", runtime.NewError("adjourned game not found", errCodeNotFound)
+	}
+	participant := false
+	for _, id := range game.PlayerIDs {
+		if id == userID {
+			participant = true
+		}
+	}
+	if !participant {
+		return "", runtime.NewError("only players of the game can resume it", errCodePermissionDenied)
+	}
+
+	// 再開済みのマッチが残っていればそれに参加させる
+	if game.ResumedMatchID != "" {
+		if match, err := nk.MatchGet(ctx, game.ResumedMatchID); err == nil && match != nil {
+			return marshalResumedMatch(game.ResumedMatchID)
+		}
+	}
+
+	matchID, err := nk.MatchCreate(ctx, "quoridor_chess", map[string]interface{}{
+		"resume_game_id": game.GameID,
+		"variant":        game.Variant,
+	})
+	if err != nil {
+		logger.Error("resume_adjourned: failed to create match: %v", err)
+		return "", runtime.NewError("failed to resume game", errCodeInternal)
+	}
+	game.ResumedMatchID = matchID
+	if err := writeAdjournedGame(ctx, nk, game, version); err != nil {
+		logger.Warn("resume_adjourned: failed to record resumed match for %s: %v", game.GameID, err)
+	}
+
+	// 相手にも再開を知らせる
+	for _, id := range game.PlayerIDs {
+		if id == userID {
+			continue
+		}
+		content := map[string]interface{}{"game_id": game.GameID, "match_id": matchID}
+		if err := nk.NotificationSend(ctx, id, "Adjourned game resumed", content, NotificationCodeAdjournedResumed, userID, true); err != nil {
+			logger.Warn("Failed to notify %s of resumed game: %v", id, err)
+		}
+	}
+	return marshalResumedMatch(matchID)
+}
+
+// marshalResumedMatch - resume_adjourned RPCのレスポンスを作成
+func marshalResumedMatch(matchID string) (string, error) {
+	response, err := json.Marshal(map[string]interface{}{"match_id": matchID})
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
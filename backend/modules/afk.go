@@ -0,0 +1,115 @@
+// Quoridor Chess 放置検出
+// 手番のプレイヤーが一定時間入力しない場合に警告し、さらに放置が続くと負けとする
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	afkWarningAfter = 45 * time.Second // 放置警告を送るまでの時間
+	afkForfeitAfter = 90 * time.Second // 放置による負けとするまでの時間
+)
+
+// recordActivity - プレイヤーの入力（ハートビートを含む）を記録
+func (m *QuoridorChessMatch) recordActivity(userID string) {
+	m.lastActivity[userID] = time.Now()
+	if userID == m.gameState.CurrentTurn {
+		m.afkWarned = false
+	}
+}
+
+// idleDuration - 手番のプレイヤーが最後に入力してからの経過時間（手番開始より前の入力は数えない）
+func (m *QuoridorChessMatch) idleDuration(userID string) time.Duration {
+	since := m.turnStartedAt
+	if last, ok := m.lastActivity[userID]; ok && last.After(since) {
+		since = last
+	}
+	return time.Since(since)
+}
+
+// afkTimeRemaining - 放置を続けた場合に負けになるまでの時間を返す
+// 時間制限のある対局では、持ち時間と1ターンの制限時間のうち先に切れる方までの時間
+func (m *QuoridorChessMatch) afkTimeRemaining(userID string, idle time.Duration) time.Duration {
+	if m.ruleset.ClockInitialMs <= 0 && m.ruleset.TurnTimeLimitMs <= 0 {
+		return afkForfeitAfter - idle
+	}
+	remaining := time.Duration(-1)
+	if player := m.gameState.Players[userID]; player != nil && player.Clock != nil {
+		remaining = time.Duration(player.Clock.RemainingMs) * time.Millisecond
+	}
+	if m.ruleset.TurnTimeLimitMs > 0 && !m.turnChangedAt.IsZero() {
+		turn := time.Duration(m.ruleset.TurnTimeLimitMs)*time.Millisecond - time.Since(m.turnChangedAt)
+		if remaining < 0 || turn < remaining {
+			remaining = turn
+		}
+	}
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining
+}
+
+// checkAFK - 手番のプレイヤーの放置を検出し、警告または負けの処理を行う
+// 接続したまま入力しないプレイヤーのみを対象とし、時間制限のある対局では警告だけ送って負けは時計に任せる
+func (m *QuoridorChessMatch) checkAFK(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher) {
+	if !m.gameState.GameStarted || m.gameState.CurrentTurn == "" || m.turnStartedAt.IsZero() {
+		return
+	}
+
+	userID := m.gameState.CurrentTurn
+	opponentID := m.opponentOf(userID)
+	if opponentID == "" {
+		return
+	}
+	// 切断中のプレイヤーは checkReconnectGrace が再接続の猶予時間で扱う
+	if player := m.gameState.Players[userID]; player == nil || player.Disconnected {
+		return
+	}
+	idle := m.idleDuration(userID)
+	// 持ち時間や1ターンの制限時間のある対局は時計の時間切れで決着させる
+	timed := m.ruleset.ClockInitialMs > 0 || m.ruleset.TurnTimeLimitMs > 0
+
+	switch {
+	case idle >= afkForfeitAfter && !timed:
+		// 放置による負け（切断とは区別する）
+		logger.Info("Player %s forfeited match %s by AFK", userID, m.matchID)
+		m.endGame(ctx, logger, nk, opponentID, ResultReasonAFK)
+		updateMsg := map[string]interface{}{
+			"type": "game_state_update",
+			"data": m.gameState,
+		}
+		m.sendMessage(dispatcher, 1, updateMsg, nil, true)
+
+	case idle >= afkWarningAfter && !m.afkWarned:
+		m.afkWarned = true
+		remaining := int(m.afkTimeRemaining(userID, idle).Seconds())
+		m.recordEvent("afk_warning", EventSourceServer, userID, map[string]interface{}{"seconds_remaining": remaining})
+
+		// 本人への警告
+		if presence, ok := m.presences[userID]; ok {
+			warningMsg := map[string]interface{}{
+				"type": "afk_warning",
+				"data": map[string]interface{}{
+					"seconds_remaining": remaining,
+				},
+			}
+			m.sendMessage(dispatcher, 1, warningMsg, []runtime.Presence{presence}, true)
+		}
+
+		// 相手への通知
+		if presence, ok := m.presences[opponentID]; ok {
+			noticeMsg := map[string]interface{}{
+				"type": "opponent_afk",
+				"data": map[string]interface{}{
+					"player_id":         userID,
+					"seconds_remaining": remaining,
+				},
+			}
+			m.sendMessage(dispatcher, 1, noticeMsg, []runtime.Presence{presence}, true)
+		}
+	}
+}
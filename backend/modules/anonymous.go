@@ -0,0 +1,56 @@
+// Quoridor Chess 匿名クイックプレイ
+// 対局ごとに使い捨ての別名で遊び、チャットはエモートのみ、プロフィールや履歴に何も残さないモード
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// anonymousMatchSearchLimit - 匿名クイックプレイで参加先を探すマッチ数
+const anonymousMatchSearchLimit = 10
+
+// anonymousAlias - 匿名モードの別名（マッチごとに変わるため、別の対局と結び付けられない）
+func (m *QuoridorChessMatch) anonymousAlias(userID string) string {
+	return generateAlias(m.matchID + ":" + userID)
+}
+
+// emoteOnlyChat - 自由入力のチャットを禁止してエモートのみにするかどうかを返す
+func (m *QuoridorChessMatch) emoteOnlyChat() bool {
+	return m.kidSafe || m.anonymous
+}
+
+// AnonymousQuickPlay - 参加待ちの匿名マッチに参加するか、なければ新しく作成してマッチIDを返すRPC
+func AnonymousQuickPlay(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	if _, err := contextUserID(ctx); err != nil {
+		return "", err
+	}
+
+	minSize := 1
+	maxSize := MaxPlayers - 1
+	matches, err := nk.MatchList(ctx, anonymousMatchSearchLimit, true, "", &minSize, &maxSize, "+label.open:true +label.anonymous:true")
+	if err != nil {
+		logger.Error("anonymous_quick_play: failed to list matches: %v", err)
+		return "", runtime.NewError("failed to list matches", errCodeInternal)
+	}
+
+	matchID := ""
+	if len(matches) > 0 {
+		matchID = matches[0].GetMatchId()
+	} else {
+		matchID, err = nk.MatchCreate(ctx, "quoridor_chess", map[string]interface{}{"anonymous": true})
+		if err != nil {
+			logger.Error("anonymous_quick_play: failed to create match: %v", err)
+			return "", runtime.NewError("failed to create match", errCodeInternal)
+		}
+	}
+
+	response, err := json.Marshal(map[string]interface{}{"match_id": matchID})
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
@@ -0,0 +1,45 @@
+// Quoridor Chess 監査ログ
+// 不正の疑いがある操作などをストレージに記録し、運営が後から確認できるようにする
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const auditEventCollection = "audit_events" // 監査イベントのストレージコレクション
+
+// AuditEvent - 監査ログの1件
+type AuditEvent struct {
+	Type      string                 `json:"type"`              // イベント種別
+	MatchID   string                 `json:"match_id"`          // 関連するマッチID
+	UserID    string                 `json:"user_id"`           // 操作したユーザーID
+	Details   map[string]interface{} `json:"details,omitempty"` // 詳細情報
+	CreatedAt int64                  `json:"created_at"`        // 記録時刻（Unix時刻）
+}
+
+// writeAuditEvent - 監査イベントを記録（失敗してもゲーム進行は止めない）
+func writeAuditEvent(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, event *AuditEvent) {
+	now := time.Now()
+	event.CreatedAt = now.Unix()
+	logger.Warn("Audit event %s: match=%s user=%s details=%v", event.Type, event.MatchID, event.UserID, event.Details)
+
+	value, err := json.Marshal(event)
+	if err != nil {
+		logger.Error("Failed to encode audit event: %v", err)
+		return
+	}
+	if _, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      auditEventCollection,
+		Key:             fmt.Sprintf("%s-%d", event.Type, now.UnixNano()),
+		Value:           string(value),
+		PermissionRead:  0,
+		PermissionWrite: 0,
+	}}); err != nil {
+		logger.Error("Failed to store audit event: %v", err)
+	}
+}
@@ -0,0 +1,228 @@
+// Quoridor Chess 対戦回避リスト
+// 通報・ブロックした相手との再戦を一定期間防ぐためのリストを管理する
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	avoidListCollection = "avoid_list"       // 回避リストのストレージコレクション
+	avoidListKey        = "list"             // 回避リストのストレージキー
+	avoidListMaxEntries = 10                 // 回避リストに登録できる最大人数（待ち行列の分断を防ぐ）
+	avoidListDuration   = 7 * 24 * time.Hour // 回避の有効期間
+)
+
+// AvoidEntry - 回避リストの1件
+type AvoidEntry struct {
+	UserID    string `json:"user_id"`    // 回避する相手のユーザーID
+	AddedAt   int64  `json:"added_at"`   // 登録時刻（Unix時刻）
+	ExpiresAt int64  `json:"expires_at"` // 有効期限（Unix時刻）
+}
+
+// AvoidList - ユーザーごとの回避リスト
+type AvoidList struct {
+	Entries []*AvoidEntry `json:"entries"`
+}
+
+// AvoidPlayerRequest - 回避リスト操作RPCのリクエスト
+type AvoidPlayerRequest struct {
+	UserID string `json:"user_id"`
+}
+
+// pruneExpired - 期限切れのエントリを取り除く
+func (l *AvoidList) pruneExpired(now int64) {
+	active := l.Entries[:0]
+	for _, entry := range l.Entries {
+		if entry.ExpiresAt > now {
+			active = append(active, entry)
+		}
+	}
+	l.Entries = active
+}
+
+// contains - 指定ユーザーが有効なエントリとして登録されているかどうかを返す
+func (l *AvoidList) contains(userID string, now int64) bool {
+	for _, entry := range l.Entries {
+		if entry.UserID == userID && entry.ExpiresAt > now {
+			return true
+		}
+	}
+	return false
+}
+
+// readAvoidList - ユーザーの回避リストを読み込む（未作成の場合は空のリスト）
+func readAvoidList(ctx context.Context, nk runtime.NakamaModule, userID string) (*AvoidList, string, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: avoidListCollection,
+		Key:        avoidListKey,
+		UserID:     userID,
+	}})
+	if err != nil {
+		return nil, "", err
+	}
+
+	list := &AvoidList{Entries: []*AvoidEntry{}}
+	if len(objects) == 0 {
+		return list, "", nil
+	}
+	if err := json.Unmarshal([]byte(objects[0].GetValue()), list); err != nil {
+		return nil, "", err
+	}
+	return list, objects[0].GetVersion(), nil
+}
+
+// writeAvoidList - 回避リストを保存（version による楽観的ロック付き）
+func writeAvoidList(ctx context.Context, nk runtime.NakamaModule, userID, version string, list *AvoidList) error {
+	value, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	if version == "" {
+		// 新規作成時は既存オブジェクトがないことを条件にする
+		version = "*"
+	}
+	_, err = nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      avoidListCollection,
+		Key:             avoidListKey,
+		UserID:          userID,
+		Value:           string(value),
+		Version:         version,
+		PermissionRead:  1,
+		PermissionWrite: 0,
+	}})
+	return err
+}
+
+// isAvoidedPair - どちらかが相手を回避リストに登録しているかどうかを返す
+func isAvoidedPair(ctx context.Context, nk runtime.NakamaModule, userA, userB string) (bool, error) {
+	now := time.Now().Unix()
+	for _, pair := range [][2]string{{userA, userB}, {userB, userA}} {
+		list, _, err := readAvoidList(ctx, nk, pair[0])
+		if err != nil {
+			return false, err
+		}
+		if list.contains(pair[1], now) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// AddAvoidPlayer - 回避リストに相手を追加するRPC
+// すでに登録済みの場合は有効期限を延長する
+func AddAvoidPlayer(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return "", err
+	}
+	req := &AvoidPlayerRequest{}
+	if err := json.Unmarshal([]byte(payload), req); err != nil || req.UserID == "" {
+		return "", errInvalidPayload
+	}
+	if req.UserID == userID {
+		return "", runtime.NewError("cannot avoid yourself", errCodeInvalidArgument)
+	}
+
+	list, version, err := readAvoidList(ctx, nk, userID)
+	if err != nil {
+		logger.Error("add_avoid_player: failed to read avoid list: %v", err)
+		return "", runtime.NewError("failed to read avoid list", errCodeInternal)
+	}
+
+	now := time.Now()
+	list.pruneExpired(now.Unix())
+	expiresAt := now.Add(avoidListDuration).Unix()
+	found := false
+	for _, entry := range list.Entries {
+		if entry.UserID == req.UserID {
+			entry.ExpiresAt = expiresAt
+			found = true
+		}
+	}
+	if !found {
+		if len(list.Entries) >= avoidListMaxEntries {
+			return "", runtime.NewError("avoid list is full", errCodeResourceExhausted)
+		}
+		list.Entries = append(list.Entries, &AvoidEntry{
+			UserID:    req.UserID,
+			AddedAt:   now.Unix(),
+			ExpiresAt: expiresAt,
+		})
+	}
+
+	if err := writeAvoidList(ctx, nk, userID, version, list); err != nil {
+		logger.Error("add_avoid_player: failed to write avoid list: %v", err)
+		return "", runtime.NewError("failed to write avoid list", errCodeInternal)
+	}
+
+	response, err := json.Marshal(list)
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
+
+// RemoveAvoidPlayer - 回避リストから相手を削除するRPC
+func RemoveAvoidPlayer(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return "", err
+	}
+	req := &AvoidPlayerRequest{}
+	if err := json.Unmarshal([]byte(payload), req); err != nil || req.UserID == "" {
+		return "", errInvalidPayload
+	}
+
+	list, version, err := readAvoidList(ctx, nk, userID)
+	if err != nil {
+		logger.Error("remove_avoid_player: failed to read avoid list: %v", err)
+		return "", runtime.NewError("failed to read avoid list", errCodeInternal)
+	}
+
+	list.pruneExpired(time.Now().Unix())
+	remaining := list.Entries[:0]
+	for _, entry := range list.Entries {
+		if entry.UserID != req.UserID {
+			remaining = append(remaining, entry)
+		}
+	}
+	list.Entries = remaining
+
+	if err := writeAvoidList(ctx, nk, userID, version, list); err != nil {
+		logger.Error("remove_avoid_player: failed to write avoid list: %v", err)
+		return "", runtime.NewError("failed to write avoid list", errCodeInternal)
+	}
+
+	response, err := json.Marshal(list)
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
+
+// ListAvoidPlayers - 有効な回避リストを返すRPC
+func ListAvoidPlayers(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	list, _, err := readAvoidList(ctx, nk, userID)
+	if err != nil {
+		logger.Error("list_avoid_players: failed to read avoid list: %v", err)
+		return "", runtime.NewError("failed to read avoid list", errCodeInternal)
+	}
+	list.pruneExpired(time.Now().Unix())
+
+	response, err := json.Marshal(list)
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
@@ -0,0 +1,143 @@
+// Quoridor Chess 帯域の集計と低帯域モード
+// プレゼンスごとに送信したバイト数を数え、一定時間内の送信量が上限を超えたプレイヤーや、
+// 参加時のメタデータで low_bandwidth を指定したプレイヤーには、全体の状態の代わりに差分だけを送り、重要でないメッセージ（エモートなど）を省く
+package main
+
+import (
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	bandwidthWindow = 10 * time.Second // 送信量を数える時間幅
+	bandwidthBudget = 48 * 1024        // 時間幅の中でこのバイト数を超えたら低帯域モードに切り替える
+)
+
+// 低帯域モードに切り替えた理由
+const (
+	LowBandwidthReasonRequested = "requested" // 参加時のメタデータで指定された
+	LowBandwidthReasonBudget    = "budget"    // 送信量が上限を超えた
+)
+
+// bandwidthUsage - プレゼンスごとの送信量
+type bandwidthUsage struct {
+	total       int64     // マッチ全体で送信したバイト数
+	windowStart time.Time // 現在の時間幅の開始時刻
+	windowBytes int64     // 現在の時間幅で送信したバイト数
+}
+
+// StateDeltaData - 低帯域モードのプレイヤーに game_state_update の代わりに送る差分
+// 盤面は直前の操作（last_action）と各コマの位置だけを送り、クライアントは手元の盤面に適用する
+type StateDeltaData struct {
+	Seq          int64                `json:"seq"`
+	Ply          int                  `json:"ply"`                   // 対局開始からの手数
+	CurrentTurn  string               `json:"current_turn"`          // 現在のターンのプレイヤーID
+	GameStarted  bool                 `json:"game_started"`          // 対局中かどうか
+	Winner       string               `json:"winner,omitempty"`      // 勝者のプレイヤーID（対局終了時）
+	ResultReason string               `json:"result_reason"`         // 決着の理由（対局終了時）
+	LastAction   *ActionHint          `json:"last_action,omitempty"` // 直前に受理した操作
+	Positions    map[string]*Position `json:"positions"`             // 各プレイヤーのコマの位置
+	Walls        map[string]int       `json:"walls"`                 // 各プレイヤーの残り壁数
+	ClocksMs     map[string]int64     `json:"clocks_ms,omitempty"`   // 各プレイヤーの残りの持ち時間（時間制限のある対局のみ）
+}
+
+// BandwidthModeData - 低帯域モードに切り替えたことの通知（本人のみ）
+type BandwidthModeData struct {
+	LowBandwidth bool   `json:"low_bandwidth"`
+	Reason       string `json:"reason"` // 切り替えた理由（"requested" / "budget"）
+}
+
+// setLowBandwidthRequest - 参加時のメタデータの low_bandwidth を反映する
+func (m *QuoridorChessMatch) setLowBandwidthRequest(userID string, metadata map[string]string) {
+	if metadata["low_bandwidth"] == "true" {
+		m.lowBandwidth[userID] = true
+		return
+	}
+	delete(m.lowBandwidth, userID)
+}
+
+// accountBytes - 送信したバイト数をプレゼンスごとに加算し、上限を超えたプレイヤーを低帯域モードに切り替える
+func (m *QuoridorChessMatch) accountBytes(dispatcher runtime.MatchDispatcher, presences []runtime.Presence, size int) {
+	now := time.Now()
+	exceeded := make([]runtime.Presence, 0)
+	for _, presence := range presences {
+		userID := presence.GetUserId()
+		usage := m.bandwidth[userID]
+		if usage == nil {
+			usage = &bandwidthUsage{windowStart: now}
+			m.bandwidth[userID] = usage
+		}
+		if now.Sub(usage.windowStart) >= bandwidthWindow {
+			usage.windowStart, usage.windowBytes = now, 0
+		}
+		usage.total += int64(size)
+		usage.windowBytes += int64(size)
+		if usage.windowBytes > bandwidthBudget && !m.lowBandwidth[userID] {
+			m.lowBandwidth[userID] = true
+			exceeded = append(exceeded, presence)
+		}
+	}
+
+	for _, presence := range exceeded {
+		m.recordEvent("low_bandwidth", EventSourceServer, presence.GetUserId(), map[string]interface{}{"reason": LowBandwidthReasonBudget})
+		msg := map[string]interface{}{
+			"type": "bandwidth_mode",
+			"data": &BandwidthModeData{LowBandwidth: true, Reason: LowBandwidthReasonBudget},
+		}
+		m.sendMessage(dispatcher, 1, msg, []runtime.Presence{presence}, true)
+	}
+}
+
+// bytesSent - プレイヤーごとにマッチ全体で送信したバイト数を返す
+func (m *QuoridorChessMatch) bytesSent() map[string]int64 {
+	sent := make(map[string]int64, len(m.bandwidth))
+	for id, usage := range m.bandwidth {
+		sent[id] = usage.total
+	}
+	return sent
+}
+
+// isFullStateUpdate - 低帯域モードのプレイヤーには差分に置き換えて送るメッセージかどうか
+func isFullStateUpdate(msg interface{}) bool {
+	fields, ok := msg.(map[string]interface{})
+	return ok && fields["type"] == "game_state_update"
+}
+
+// stateDelta - 現在のゲーム状態の差分を作成
+func (m *QuoridorChessMatch) stateDelta() *StateDeltaData {
+	delta := &StateDeltaData{
+		Seq:          m.gameState.Seq,
+		Ply:          m.ply(),
+		CurrentTurn:  m.gameState.CurrentTurn,
+		GameStarted:  m.gameState.GameStarted,
+		Winner:       m.gameState.Winner,
+		ResultReason: m.gameState.ResultReason,
+		LastAction:   m.gameState.LastAction,
+		Positions:    make(map[string]*Position, len(m.gameState.Players)),
+		Walls:        make(map[string]int, len(m.gameState.Players)),
+	}
+	for id, player := range m.gameState.Players {
+		delta.Positions[id] = player.Position
+		delta.Walls[id] = player.Walls
+		if player.Clock != nil {
+			if delta.ClocksMs == nil {
+				delta.ClocksMs = make(map[string]int64)
+			}
+			delta.ClocksMs[id] = player.Clock.RemainingMs
+		}
+	}
+	return delta
+}
+
+// sendStateDelta - 低帯域モードのプレゼンスに全体の状態の代わりに差分を送信
+func (m *QuoridorChessMatch) sendStateDelta(dispatcher runtime.MatchDispatcher, presences []runtime.Presence) {
+	if len(presences) == 0 {
+		return
+	}
+	msg := map[string]interface{}{
+		"type": "state_delta",
+		"data": m.stateDelta(),
+	}
+	m.sendMessage(dispatcher, 1, msg, presences, true)
+}
@@ -0,0 +1,164 @@
+// Quoridor Chess ボード関連のロジック
+// 壁による移動制限の判定と、ゴールまでの最短経路探索を担当
+package main
+
+import "strconv"
+
+// 壁の座標の考え方:
+//   水平壁 - Start(x, y) と End(x+1, y) を結ぶ溝に置かれ、行 y と行 y+1 の間を列 x, x+1 にわたって塞ぐ
+//   垂直壁 - Start(x, y) と End(x, y+1) を結ぶ溝に置かれ、列 x と列 x+1 の間を行 y, y+1 にわたって塞ぐ
+
+// 4方向の移動ベクトル（上・下・左・右）
+var directions = []Position{
+	{X: 0, Y: -1},
+	{X: 0, Y: 1},
+	{X: -1, Y: 0},
+	{X: 1, Y: 0},
+}
+
+// squareName - 座標を棋譜表記（列 a-i、行 9-1）に変換
+func squareName(x, y int) string {
+	return string(rune('a'+x)) + strconv.Itoa(9-y)
+}
+
+// InBounds - 座標がボード範囲内かどうかを返す
+func (b *Board) InBounds(x, y int) bool {
+	return x >= 0 && x < b.Size && y >= 0 && y < b.Size
+}
+
+// WallOnGrid - 壁がボード内の溝に沿って正しい長さ（2マス分）で置かれているかどうかを返す
+func (b *Board) WallOnGrid(wall Wall) bool {
+	if wall.Start == nil || wall.End == nil {
+		return false
+	}
+	x, y := wall.Start.X, wall.Start.Y
+	// 壁の開始点は溝の交点（0 から Size-2 まで）にある必要がある
+	if x < 0 || x > b.Size-2 || y < 0 || y > b.Size-2 {
+		return false
+	}
+	if wall.Horizontal {
+		return wall.End.X == x+1 && wall.End.Y == y
+	}
+	return wall.End.X == x && wall.End.Y == y+1
+}
+
+// wallsConflict - 2枚の壁が重なる・交差する・同じ溝の一部を共有するかどうかを返す
+func wallsConflict(a, b Wall) bool {
+	if a.Start == nil || b.Start == nil {
+		return false
+	}
+	ax, ay, bx, by := a.Start.X, a.Start.Y, b.Start.X, b.Start.Y
+	if a.Horizontal != b.Horizontal {
+		// 向きが異なる壁は中央の交点が同じ場合に交差する
+		return ax == bx && ay == by
+	}
+	if a.Horizontal {
+		// 同じ行の溝で、列が1つ以内にずれていれば一部を共有する
+		return ay == by && abs(ax-bx) <= 1
+	}
+	return ax == bx && abs(ay-by) <= 1
+}
+
+// CollidesWithWalls - 壁が配置済みの壁のいずれかと重なる・交差するかどうかを返す
+func (b *Board) CollidesWithWalls(wall Wall) bool {
+	for _, placed := range b.Walls {
+		if wallsConflict(wall, placed) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBlocked - 隣接する2マス間の移動が壁で塞がれているかどうかを返す
+func (b *Board) IsBlocked(fromX, fromY, toX, toY int) bool {
+	for _, wall := range b.Walls {
+		if wall.Start == nil {
+			continue
+		}
+		wx, wy := wall.Start.X, wall.Start.Y
+		if wall.Horizontal {
+			// 縦方向の移動のみを塞ぐ
+			if fromX != toX || (fromX != wx && fromX != wx+1) {
+				continue
+			}
+			upper := fromY
+			if toY < upper {
+				upper = toY
+			}
+			if upper == wy {
+				return true
+			}
+		} else {
+			// 横方向の移動のみを塞ぐ
+			if fromY != toY || (fromY != wy && fromY != wy+1) {
+				continue
+			}
+			left := fromX
+			if toX < left {
+				left = toX
+			}
+			if left == wx {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Goal - 経路探索で目指す場所（Square があればそのマス、なければ Row の行全体）
+type Goal struct {
+	Row    int
+	Square *Position
+}
+
+// Reached - 指定したマスがゴールかどうかを返す
+func (g Goal) Reached(x, y int) bool {
+	if g.Square != nil {
+		return x == g.Square.X && y == g.Square.Y
+	}
+	return y == g.Row
+}
+
+// ShortestPathTo - 指定位置からゴールまでの最短手数を幅優先探索で求める
+// コマは障害物として扱わず、壁のみを考慮する。到達不能な場合は -1 を返す
+func (b *Board) ShortestPathTo(from *Position, goal Goal) int {
+	if from == nil || !b.InBounds(from.X, from.Y) {
+		return -1
+	}
+
+	dist := make([]int, b.Size*b.Size)
+	for i := range dist {
+		dist[i] = -1
+	}
+	dist[from.Y*b.Size+from.X] = 0
+	queue := []Position{*from}
+
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		d := dist[cur.Y*b.Size+cur.X]
+		if goal.Reached(cur.X, cur.Y) {
+			return d
+		}
+		for _, dir := range directions {
+			nx, ny := cur.X+dir.X, cur.Y+dir.Y
+			if !b.InBounds(nx, ny) || dist[ny*b.Size+nx] >= 0 {
+				continue
+			}
+			if b.IsBlocked(cur.X, cur.Y, nx, ny) {
+				continue
+			}
+			dist[ny*b.Size+nx] = d + 1
+			queue = append(queue, Position{X: nx, Y: ny})
+		}
+	}
+
+	return -1
+}
+
+// WithWall - 壁を1枚追加した仮のボードを返す（元のボードは変更しない）
+func (b *Board) WithWall(wall Wall) *Board {
+	walls := make([]Wall, len(b.Walls), len(b.Walls)+1)
+	copy(walls, b.Walls)
+	return &Board{Size: b.Size, Walls: append(walls, wall)}
+}
@@ -0,0 +1,79 @@
+package main
+
+import "testing"
+
+// hWall - 左上の交点が (x, y) の水平壁
+func hWall(x, y int) Wall {
+	return Wall{Start: &Position{X: x, Y: y}, End: &Position{X: x + 1, Y: y}, Horizontal: true}
+}
+
+// vWall - 左上の交点が (x, y) の垂直壁
+func vWall(x, y int) Wall {
+	return Wall{Start: &Position{X: x, Y: y}, End: &Position{X: x, Y: y + 1}}
+}
+
+func TestWallsConflict(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Wall
+		want bool
+	}{
+		{"同じ位置の水平壁", hWall(3, 3), hWall(3, 3), true},
+		{"半分重なる水平壁", hWall(3, 3), hWall(4, 3), true},
+		{"左に半分重なる水平壁", hWall(3, 3), hWall(2, 3), true},
+		{"端が接するだけの水平壁", hWall(3, 3), hWall(5, 3), false},
+		{"別の行の水平壁", hWall(3, 3), hWall(3, 4), false},
+		{"半分重なる垂直壁", vWall(3, 3), vWall(3, 4), true},
+		{"端が接するだけの垂直壁", vWall(3, 3), vWall(3, 5), false},
+		{"別の列の垂直壁", vWall(3, 3), vWall(4, 3), false},
+		{"中央で交差する壁", hWall(3, 3), vWall(3, 3), true},
+		{"T字に接するだけの壁", hWall(3, 3), vWall(4, 3), false},
+		{"交点がずれた水平壁と垂直壁", hWall(3, 3), vWall(3, 4), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wallsConflict(tt.a, tt.b); got != tt.want {
+				t.Errorf("wallsConflict(a, b) = %v, want %v", got, tt.want)
+			}
+			if got := wallsConflict(tt.b, tt.a); got != tt.want {
+				t.Errorf("wallsConflict(b, a) = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShortestPathTo(t *testing.T) {
+	flag := Position{X: 4, Y: 0}
+	tests := []struct {
+		name  string
+		walls []Wall
+		from  Position
+		goal  Goal
+		want  int
+	}{
+		{"壁なしでゴール行へ", nil, Position{X: 4, Y: 8}, Goal{Row: 0}, 8},
+		{"ゴール行にいる", nil, Position{X: 2, Y: 0}, Goal{Row: 0}, 0},
+		{"正面の水平壁を回り込む", []Wall{hWall(4, 7)}, Position{X: 4, Y: 8}, Goal{Row: 0}, 9},
+		{"旗のマスへ", nil, Position{X: 0, Y: 8}, Goal{Row: 0, Square: &flag}, 12},
+		{
+			"端の列だけが開いている",
+			[]Wall{hWall(0, 0), hWall(2, 0), hWall(4, 0), hWall(6, 0), vWall(7, 0)},
+			Position{X: 4, Y: 8}, Goal{Row: 0}, 12,
+		},
+		{
+			"壁に囲まれて到達できない",
+			[]Wall{hWall(0, 0), hWall(2, 0), hWall(4, 0), hWall(6, 0), hWall(7, 1), vWall(7, 0)},
+			Position{X: 4, Y: 8}, Goal{Row: 0}, -1,
+		},
+		{"ボードの外", nil, Position{X: 9, Y: 8}, Goal{Row: 0}, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			board := &Board{Size: 9, Walls: tt.walls}
+			from := tt.from
+			if got := board.ShortestPathTo(&from, tt.goal); got != tt.want {
+				t.Errorf("ShortestPathTo = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+// Quoridor Chess 対局のブックマーク
+// 自分や他人の対局をブックマークし、ライブラリ画面からすぐに参照できるようにする
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	bookmarksCollection = "bookmarks" // ブックマークのストレージコレクション
+	bookmarksKey        = "list"      // ブックマークのストレージキー
+	maxBookmarks        = 200         // 1人が保存できるブックマークの最大数
+	maxMatchIDLength    = 128         // マッチIDとして受け付ける最大長
+)
+
+// Bookmark - ブックマーク1件
+type Bookmark struct {
+	MatchID string `json:"match_id"` // ブックマークした対局のマッチID
+	AddedAt int64  `json:"added_at"` // 登録時刻（Unix時刻）
+}
+
+// BookmarkList - ユーザーごとのブックマーク一覧（新しい順）
+type BookmarkList struct {
+	Bookmarks []*Bookmark `json:"bookmarks"`
+}
+
+// BookmarkGameRequest - ブックマーク操作RPCのリクエスト
+type BookmarkGameRequest struct {
+	MatchID string `json:"match_id"`
+}
+
+// readBookmarks - ブックマーク一覧を読み込む（未作成の場合は空）
+func readBookmarks(ctx context.Context, nk runtime.NakamaModule, userID string) (*BookmarkList, string, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: bookmarksCollection,
+		Key:        bookmarksKey,
+		UserID:     userID,
+	}})
+	if err != nil {
+		return nil, "", err
+	}
+
+	list := &BookmarkList{Bookmarks: []*Bookmark{}}
+	if len(objects) == 0 {
+		return list, "", nil
+	}
+	if err := json.Unmarshal([]byte(objects[0].GetValue()), list); err != nil {
+		return nil, "", err
+	}
+	return list, objects[0].GetVersion(), nil
+}
+
+// writeBookmarks - ブックマーク一覧を保存（本人のみ閲覧可能）
+func writeBookmarks(ctx context.Context, nk runtime.NakamaModule, userID, version string, list *BookmarkList) error {
+	value, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	if version == "" {
+		version = "*"
+	}
+	_, err = nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      bookmarksCollection,
+		Key:             bookmarksKey,
+		UserID:          userID,
+		Value:           string(value),
+		Version:         version,
+		PermissionRead:  1,
+		PermissionWrite: 0,
+	}})
+	return err
+}
+
+// parseBookmarkRequest - ブックマーク操作RPCの共通の入力チェック
+func parseBookmarkRequest(ctx context.Context, payload string) (string, *BookmarkGameRequest, error) {
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	req := &BookmarkGameRequest{}
+	if err := json.Unmarshal([]byte(payload), req); err != nil || req.MatchID == "" || len(req.MatchID) > maxMatchIDLength {
+		return "", nil, errInvalidPayload
+	}
+	return userID, req, nil
+}
+
+// BookmarkGame - 対局をブックマークするRPC（登録済みの場合は何もしない）
+func BookmarkGame(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, req, err := parseBookmarkRequest(ctx, payload)
+	if err != nil {
+		return "", err
+	}
+
+	list, version, err := readBookmarks(ctx, nk, userID)
+	if err != nil {
+		logger.Error("bookmark_game: failed to read bookmarks: %v", err)
+		return "", runtime.NewError("failed to read bookmarks", errCodeInternal)
+	}
+	for _, bookmark := range list.Bookmarks {
+		if bookmark.MatchID == req.MatchID {
+			response, err := json.Marshal(list)
+			if err != nil {
+				return "", err
+			}
+			return string(response), nil
+		}
+	}
+	if len(list.Bookmarks) >= maxBookmarks {
+		return "", runtime.NewError("bookmark limit reached", errCodeResourceExhausted)
+	}
+
+	// 新しいブックマークを先頭に追加
+	list.Bookmarks = append([]*Bookmark{{MatchID: req.MatchID, AddedAt: time.Now().Unix()}}, list.Bookmarks...)
+	if err := writeBookmarks(ctx, nk, userID, version, list); err != nil {
+		logger.Error("bookmark_game: failed to write bookmarks: %v", err)
+		return "", runtime.NewError("failed to write bookmarks", errCodeInternal)
+	}
+
+	response, err := json.Marshal(list)
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
+
+// UnbookmarkGame - 対局のブックマークを解除するRPC
+func UnbookmarkGame(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, req, err := parseBookmarkRequest(ctx, payload)
+	if err != nil {
+		return "", err
+	}
+
+	list, version, err := readBookmarks(ctx, nk, userID)
+	if err != nil {
+		logger.Error("unbookmark_game: failed to read bookmarks: %v", err)
+		return "", runtime.NewError("failed to read bookmarks", errCodeInternal)
+	}
+	remaining := list.Bookmarks[:0]
+	for _, bookmark := range list.Bookmarks {
+		if bookmark.MatchID != req.MatchID {
+			remaining = append(remaining, bookmark)
+		}
+	}
+	list.Bookmarks = remaining
+
+	if err := writeBookmarks(ctx, nk, userID, version, list); err != nil {
+		logger.Error("unbookmark_game: failed to write bookmarks: %v", err)
+		return "", runtime.NewError("failed to write bookmarks", errCodeInternal)
+	}
+
+	response, err := json.Marshal(list)
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
+
+// ListBookmarks - ブックマーク一覧を返すRPC
+func ListBookmarks(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	list, _, err := readBookmarks(ctx, nk, userID)
+	if err != nil {
+		logger.Error("list_bookmarks: failed to read bookmarks: %v", err)
+		return "", runtime.NewError("failed to read bookmarks", errCodeInternal)
+	}
+
+	response, err := json.Marshal(list)
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
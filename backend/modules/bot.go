@@ -0,0 +1,338 @@
+// Quoridor Chess ボット対戦
+// 2人目の席をサーバーが操作するボットにした練習用のマッチを作成し、ボットの手番ではマッチループで手を選んで指す
+// ボットは通常のプレイヤーと同じ合法手と壁の検証を通った操作だけを行う
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	BotPlayerID  = "bot"                  // ボットの席のプレイヤーID
+	botUsername  = "Bot"                  // ボットの表示名
+	botThinkTime = 600 * time.Millisecond // 手番が来てからボットが指すまでの時間（即座に指して見づらくならないように）
+)
+
+// ボットの難易度
+const (
+	BotDifficultyEasy   = "easy"   // 壁を使わず、ときどき最短経路から外れる
+	BotDifficultyMedium = "medium" // 1手先の評価値で移動と壁を選ぶ（既定）
+	BotDifficultyHard   = "hard"   // 相手の応手まで読んで移動と壁を選ぶ
+)
+
+const (
+	botSearchWidth = 12  // 上級で相手の応手まで読む候補の数
+	botWallWeight  = 1   // 上級の評価で残りの壁1枚に与える価値（最短手数1手分）
+	botWinScore    = 100 // ゴールに到達する（される）局面の評価値
+)
+
+// botLevel - 難易度ごとの探索の設定
+type botLevel struct {
+	walls      bool    // 壁を使うかどうか
+	depth      int     // 読む手数（1 は自分の操作だけ、2 は相手の応手まで）
+	randomMove float64 // 評価せずにでたらめな移動を選ぶ確率
+}
+
+// botDifficulties - 難易度ごとの探索の設定
+var botDifficulties = map[string]botLevel{
+	BotDifficultyEasy:   {walls: false, depth: 1, randomMove: 0.25},
+	BotDifficultyMedium: {walls: true, depth: 1},
+	BotDifficultyHard:   {walls: true, depth: 2},
+}
+
+// PlayVsBotRequest - play_vs_bot RPCのリクエスト
+type PlayVsBotRequest struct {
+	Variant    string `json:"variant"`    // バリアント名（省略時は標準ルール）
+	Difficulty string `json:"difficulty"` // ボットの難易度（"easy" / "medium" / "hard"、省略時は "medium"）
+}
+
+// botAction - ボットが選んだ操作（移動か壁のどちらか一方）
+type botAction struct {
+	move  *Position
+	wall  *Wall
+	score int // 操作後の評価値（相手の最短手数 - 自分の最短手数、上級では相手の応手の後の値）
+}
+
+// vsBot - ボット対戦のマッチかどうか
+func (m *QuoridorChessMatch) vsBot() bool {
+	return m.botOwner != ""
+}
+
+// isBot - ボットの席のプレイヤーかどうか
+func (m *QuoridorChessMatch) isBot(userID string) bool {
+	return m.vsBot() && userID == BotPlayerID
+}
+
+// seatedPlayers - 席についている対局者の数（接続中のプレイヤーとボット）
+func (m *QuoridorChessMatch) seatedPlayers() int {
+	seated := len(m.presences)
+	if m.vsBot() && m.gameState.Players[BotPlayerID] != nil {
+		seated++
+	}
+	return seated
+}
+
+// checkBotJoin - ボット対戦には作成者本人だけが対局者として参加できる。問題なければ空文字列を返す
+func (m *QuoridorChessMatch) checkBotJoin(userID string) string {
+	if m.vsBot() && userID != m.botOwner {
+		return "This is a practice match against the bot"
+	}
+	return ""
+}
+
+// addBotPlayer - ボット対戦で作成者が席についたら、ボットを2人目（黒）の席につける
+func (m *QuoridorChessMatch) addBotPlayer() {
+	if !m.vsBot() || m.gameState.Players[BotPlayerID] != nil {
+		return
+	}
+	m.gameState.Players[BotPlayerID] = &Player{
+		ID:       BotPlayerID,
+		Username: botUsername,
+		Position: &Position{X: 4, Y: 0},
+		Walls:    InitialWalls,
+		Color:    "black",
+		Clock:    m.ruleset.newClock(),
+	}
+}
+
+// chooseBotAction - 難易度に応じてボットの操作を選ぶ
+func (m *QuoridorChessMatch) chooseBotAction(bot *Player) *botAction {
+	return m.searchAction(bot, botDifficulties[m.botDifficulty], time.Time{})
+}
+
+// searchAction - 探索の設定に従ってプレイヤーの操作を選ぶ（ボットの手とヒントで共用）
+// 評価値は（相手の最短手数 - 自分の最短手数）で、壁は移動より評価値が良くなる場合だけ置く
+// ゴールはルールセットの勝利条件に従い、押し出しや壁の風化も評価に含める
+// deadline を指定した場合、相手の応手を読むのはその時刻までに評価し終えた候補だけにする
+func (m *QuoridorChessMatch) searchAction(player *Player, level botLevel, deadline time.Time) *botAction {
+	opponent := m.gameState.Players[m.opponentOf(player.ID)]
+	moves := m.legalMoves(player)
+	if len(moves) > 0 && level.randomMove > 0 && rand.Float64() < level.randomMove {
+		return &botAction{move: &moves[rand.Intn(len(moves))]}
+	}
+
+	board := m.botBoard()
+	candidates := make([]*botAction, 0, len(moves))
+	for _, move := range moves {
+		move := move
+		score := -board.ShortestPathTo(&move, m.goalOf(player))
+		if opponent != nil {
+			score += board.ShortestPathTo(m.pushedPosition(player, opponent, &move), m.goalOf(opponent))
+		}
+		candidates = append(candidates, &botAction{move: &move, score: score})
+	}
+	if level.walls && opponent != nil && player.Walls > 0 && !m.wallPlacedThisTurn() {
+		// 置けるかどうかは現在の盤面で判定し、評価だけ探索用の盤面で行う
+		pawns := []botPawn{{player.Position, m.goalOf(player)}, {opponent.Position, m.goalOf(opponent)}}
+		for _, wall := range botWalls(m.gameState.Board, pawns) {
+			wall := wall
+			withWall := board.WithWall(wall)
+			score := withWall.ShortestPathTo(opponent.Position, m.goalOf(opponent)) - withWall.ShortestPathTo(player.Position, m.goalOf(player))
+			candidates = append(candidates, &botAction{wall: &wall, score: score})
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	// 上級では評価値の高い候補だけ、相手の最善の応手まで読んで評価し直す
+	if level.depth >= 2 && opponent != nil {
+		sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+		if len(candidates) > botSearchWidth {
+			candidates = candidates[:botSearchWidth]
+		}
+		evaluated := 0
+		for _, candidate := range candidates {
+			if !deadline.IsZero() && time.Now().After(deadline) {
+				break
+			}
+			candidate.score = m.worstReply(player, opponent, candidate)
+			evaluated++
+		}
+		if evaluated > 0 {
+			candidates = candidates[:evaluated]
+		}
+	}
+
+	best := candidates[0]
+	for _, candidate := range candidates[1:] {
+		// 同点であれば壁を温存して移動を選ぶ
+		if candidate.score > best.score || (candidate.score == best.score && best.wall != nil && candidate.wall == nil) {
+			best = candidate
+		}
+	}
+	return best
+}
+
+// worstReply - 操作に対して相手が最善の応手（前進か壁）を指した後の評価値を返す
+// 残りの壁の数の差も評価に加え、壁を無駄に使わないようにする
+func (m *QuoridorChessMatch) worstReply(player, opponent *Player, action *botAction) int {
+	board := m.botBoard()
+	self, other, walls := player.Position, opponent.Position, player.Walls
+	if action.move != nil {
+		self = action.move
+		other = m.pushedPosition(player, opponent, action.move)
+	} else {
+		board = board.WithWall(*action.wall)
+		walls--
+	}
+	selfGoal, opponentGoal := m.goalOf(player), m.goalOf(opponent)
+	own := board.ShortestPathTo(self, selfGoal)
+	if own == 0 {
+		return botWinScore
+	}
+	theirs := board.ShortestPathTo(other, opponentGoal)
+	if theirs <= 1 {
+		return -botWinScore
+	}
+
+	// 相手が最短経路に沿って前進した場合
+	worst := theirs - 1 - own + botWallWeight*(walls-opponent.Walls)
+	if opponent.Walls <= 0 {
+		return worst
+	}
+	pawns := []botPawn{{self, selfGoal}, {other, opponentGoal}}
+	for _, wall := range botWalls(board, pawns) {
+		withWall := board.WithWall(wall)
+		score := withWall.ShortestPathTo(other, opponentGoal) - withWall.ShortestPathTo(self, selfGoal) + botWallWeight*(walls-opponent.Walls+1)
+		if score < worst {
+			worst = score
+		}
+	}
+	return worst
+}
+
+// botPawn - 壁の候補を調べるときのコマの位置とゴール
+type botPawn struct {
+	position *Position
+	goal     Goal
+}
+
+// botBoard - 探索に使う盤面を返す
+// 壁の風化バリアントでは、自分が次に指すまでに消える壁を除いて評価する
+func (m *QuoridorChessMatch) botBoard() *Board {
+	board := m.gameState.Board
+	if m.ruleset.WallDecayTurns <= 0 {
+		return board
+	}
+	lifetime := m.ruleset.WallDecayTurns * len(m.gameState.Players)
+	ply := m.ply() + len(m.gameState.Players)
+	walls := make([]Wall, 0, len(board.Walls))
+	for _, wall := range board.Walls {
+		if ply-wall.PlacedPly < lifetime {
+			walls = append(walls, wall)
+		}
+	}
+	return &Board{Size: board.Size, Walls: walls}
+}
+
+// pushedPosition - プレイヤーが move に移動した後の相手のコマの位置を返す（押し出しバリアントでは押し出された先）
+func (m *QuoridorChessMatch) pushedPosition(player, opponent *Player, move *Position) *Position {
+	if !m.ruleset.Push || opponent.Position == nil || *opponent.Position != *move {
+		return opponent.Position
+	}
+	return &Position{X: 2*move.X - player.Position.X, Y: 2*move.Y - player.Position.Y}
+}
+
+// botWalls - 盤面に置ける壁（溝に沿い、他の壁と重ならず、どのコマのゴールへの経路も塞がない）をすべて返す
+func botWalls(board *Board, pawns []botPawn) []Wall {
+	walls := make([]Wall, 0, 2*(board.Size-1)*(board.Size-1))
+	for x := 0; x <= board.Size-2; x++ {
+		for y := 0; y <= board.Size-2; y++ {
+			for _, horizontal := range []bool{true, false} {
+				wall := Wall{Start: &Position{X: x, Y: y}, End: &Position{X: x, Y: y + 1}, Horizontal: horizontal}
+				if horizontal {
+					wall.End = &Position{X: x + 1, Y: y}
+				}
+				if board.CollidesWithWalls(wall) {
+					continue
+				}
+				withWall := board.WithWall(wall)
+				blocked := false
+				for _, pawn := range pawns {
+					if withWall.ShortestPathTo(pawn.position, pawn.goal) < 0 {
+						blocked = true
+						break
+					}
+				}
+				if !blocked {
+					walls = append(walls, wall)
+				}
+			}
+		}
+	}
+	return walls
+}
+
+// playBotTurn - ボットの手番であれば、少し待ってから操作を選んで指す
+func (m *QuoridorChessMatch) playBotTurn(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher) {
+	if !m.gameState.GameStarted || !m.isBot(m.gameState.CurrentTurn) {
+		return
+	}
+	if time.Since(m.turnStartedAt) < botThinkTime {
+		return
+	}
+	bot := m.gameState.Players[BotPlayerID]
+	action := m.chooseBotAction(bot)
+	if action == nil {
+		logger.Warn("Bot has no legal action in match %s", m.matchID)
+		return
+	}
+	if action.wall != nil {
+		m.applyWall(dispatcher, bot, *action.wall)
+		return
+	}
+	m.applyMove(ctx, logger, nk, dispatcher, bot, action.move.X, action.move.Y, false)
+}
+
+// botDifficultyParam - マッチ作成時のパラメータからボットの難易度を決める（誤った値の場合は既定の "medium"）
+func botDifficultyParam(params map[string]interface{}) string {
+	if difficulty, _ := params["bot_difficulty"].(string); botDifficulties[difficulty] != (botLevel{}) {
+		return difficulty
+	}
+	return BotDifficultyMedium
+}
+
+// PlayVsBot - ボットと対戦する練習用のマッチを作成するRPC（カジュアル戦のみ、作成者だけが参加できる）
+func PlayVsBot(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return "", err
+	}
+	req := &PlayVsBotRequest{}
+	if payload != "" {
+		if err := json.Unmarshal([]byte(payload), req); err != nil {
+			return "", errInvalidPayload
+		}
+	}
+
+	if req.Difficulty == "" {
+		req.Difficulty = BotDifficultyMedium
+	}
+	if _, ok := botDifficulties[req.Difficulty]; !ok {
+		return "", runtime.NewError("unknown bot difficulty", errCodeInvalidArgument)
+	}
+
+	matchID, err := nk.MatchCreate(ctx, "quoridor_chess", map[string]interface{}{
+		"bot_owner":      userID,
+		"bot_difficulty": req.Difficulty,
+		"variant":        req.Variant,
+	})
+	if err != nil {
+		logger.Error("play_vs_bot: failed to create match: %v", err)
+		return "", runtime.NewError("failed to create match", errCodeInternal)
+	}
+
+	response, err := json.Marshal(map[string]interface{}{"match_id": matchID})
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
@@ -0,0 +1,124 @@
+// Quoridor Chess メッセージ送信
+// マッチ内のメッセージ送信を一元化し、送信に失敗するプレゼンスへの配信を調整する
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// sendMessage - メッセージをJSONに変換して送信
+// recipients が nil の場合は全員に送る。essential が false のメッセージ（エモートなど）は、
+// 送信に失敗して遅延中と判断したプレゼンスには送らず、重要なメッセージの配信を優先する
+func (m *QuoridorChessMatch) sendMessage(dispatcher runtime.MatchDispatcher, opCode int64, msg interface{}, recipients []runtime.Presence, essential bool) {
+	// 全員宛てのメッセージはイベントとして通し番号を進める（ロングポーリングの変更検知用）
+	if recipients == nil {
+		m.gameState.Seq++
+	}
+	msgBytes, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	// 中継中のマッチでは全員宛てのメッセージを遅らせて中継ストリームにも流す
+	if recipients == nil {
+		m.queueRelay(opCode, msg, msgBytes)
+	}
+
+	// 遅延中・低帯域モードのプレゼンスがいなければ通常どおり送信
+	if recipients == nil && len(m.lagging) == 0 && len(m.lowBandwidth) == 0 {
+		if err := dispatcher.BroadcastMessage(opCode, msgBytes, nil, nil, true); err != nil {
+			m.sendIndividually(dispatcher, opCode, msgBytes, m.presenceList())
+			return
+		}
+		m.accountBytes(dispatcher, m.presenceList(), len(msgBytes))
+		return
+	}
+
+	if recipients == nil {
+		recipients = m.presenceList()
+	}
+	targets := make([]runtime.Presence, 0, len(recipients))
+	deltaTargets := make([]runtime.Presence, 0)
+	for _, presence := range recipients {
+		userID := presence.GetUserId()
+		if !essential && (m.lagging[userID] || m.lowBandwidth[userID]) {
+			continue // 遅延中・低帯域モードのプレゼンスには重要でないメッセージを送らない
+		}
+		if m.lowBandwidth[userID] && isFullStateUpdate(msg) {
+			deltaTargets = append(deltaTargets, presence) // 全体の状態の代わりに差分を送る
+			continue
+		}
+		targets = append(targets, presence)
+	}
+	m.sendStateDelta(dispatcher, deltaTargets)
+	if len(targets) == 0 {
+		return
+	}
+
+	if err := dispatcher.BroadcastMessage(opCode, msgBytes, targets, nil, true); err != nil {
+		m.sendIndividually(dispatcher, opCode, msgBytes, targets)
+		return
+	}
+	m.accountBytes(dispatcher, targets, len(msgBytes))
+	m.markDelivered(dispatcher, targets)
+}
+
+// sendIndividually - 一括送信に失敗した場合に1人ずつ送り直し、失敗したプレゼンスを遅延中として記録
+func (m *QuoridorChessMatch) sendIndividually(dispatcher runtime.MatchDispatcher, opCode int64, msgBytes []byte, targets []runtime.Presence) {
+	delivered := make([]runtime.Presence, 0, len(targets))
+	for _, presence := range targets {
+		if err := dispatcher.BroadcastMessage(opCode, msgBytes, []runtime.Presence{presence}, nil, true); err != nil {
+			m.lagging[presence.GetUserId()] = true
+			continue
+		}
+		delivered = append(delivered, presence)
+	}
+	m.accountBytes(dispatcher, delivered, len(msgBytes))
+	m.markDelivered(dispatcher, delivered)
+}
+
+// markDelivered - 遅延中だったプレゼンスへの送信が成功したら復帰させ、取りこぼした分を全体の状態で補う
+func (m *QuoridorChessMatch) markDelivered(dispatcher runtime.MatchDispatcher, delivered []runtime.Presence) {
+	recovered := make([]runtime.Presence, 0)
+	for _, presence := range delivered {
+		if m.lagging[presence.GetUserId()] {
+			delete(m.lagging, presence.GetUserId())
+			recovered = append(recovered, presence)
+		}
+	}
+	if len(recovered) == 0 {
+		return
+	}
+
+	resyncMsg := map[string]interface{}{
+		"type": "state_resync",
+		"data": m.gameState,
+	}
+	m.sendMessage(dispatcher, 1, resyncMsg, recovered, true)
+}
+
+// rejectAction - 受け付けなかった操作を送信者本人に通知
+func (m *QuoridorChessMatch) rejectAction(dispatcher runtime.MatchDispatcher, userID, action, reason string) {
+	presence, ok := m.presences[userID]
+	if !ok {
+		return
+	}
+	msg := map[string]interface{}{
+		"type": "action_rejected",
+		"data": &ActionRejectedData{Action: action, Reason: reason},
+	}
+	m.sendMessage(dispatcher, 1, msg, []runtime.Presence{presence}, true)
+}
+
+// presenceList - 接続中のプレゼンス（観戦者を含む）の一覧を返す
+func (m *QuoridorChessMatch) presenceList() []runtime.Presence {
+	presences := make([]runtime.Presence, 0, len(m.presences)+len(m.spectators))
+	for _, presence := range m.presences {
+		presences = append(presences, presence)
+	}
+	for _, presence := range m.spectators {
+		presences = append(presences, presence)
+	}
+	return presences
+}
@@ -0,0 +1,73 @@
+// Quoridor Chess 操作の先行入力
+// 相手のターン中に送られた次の操作をプレイヤーごとに1つだけ保留し、自分のターンが来たときに検証して適用する
+package main
+
+import (
+	"context"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// bufferedAction - 保留中の操作
+type bufferedAction struct {
+	Type string                 // 操作の種類（"move" / "place_wall"）
+	Data map[string]interface{} // 送られてきたメッセージのデータ
+}
+
+// bufferAction - 相手のターン中に送られた操作を保留する（すでに保留中の操作は置き換える）
+func (m *QuoridorChessMatch) bufferAction(dispatcher runtime.MatchDispatcher, userID, actionType string, data map[string]interface{}) {
+	if m.gameState.Players[userID] == nil {
+		return
+	}
+	m.bufferedActions[userID] = &bufferedAction{Type: actionType, Data: data}
+
+	presence, ok := m.presences[userID]
+	if !ok {
+		return
+	}
+	msg := map[string]interface{}{
+		"type": "action_buffered",
+		"data": &ActionBufferedData{Action: actionType},
+	}
+	m.sendMessage(dispatcher, 1, msg, []runtime.Presence{presence}, true)
+}
+
+// playBufferedAction - 手番のプレイヤーに保留中の操作があれば検証して適用する
+// 局面が変わって適用できなくなった場合は本人に通知して破棄する
+func (m *QuoridorChessMatch) playBufferedAction(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher) {
+	if !m.gameState.GameStarted {
+		return
+	}
+	userID := m.gameState.CurrentTurn
+	action, ok := m.bufferedActions[userID]
+	if !ok {
+		return
+	}
+	delete(m.bufferedActions, userID)
+
+	player := m.gameState.Players[userID]
+	if player == nil {
+		return
+	}
+
+	var reason string
+	switch action.Type {
+	case "move":
+		reason = m.handleMove(ctx, logger, nk, dispatcher, player, action.Data)
+	case "place_wall":
+		reason = m.placeWall(dispatcher, player, action.Data)
+	}
+	if reason == "" {
+		return
+	}
+
+	presence, ok := m.presences[userID]
+	if !ok {
+		return
+	}
+	msg := map[string]interface{}{
+		"type": "buffered_action_invalidated",
+		"data": &BufferedActionInvalidatedData{Action: action.Type, Reason: reason},
+	}
+	m.sendMessage(dispatcher, 1, msg, []runtime.Presence{presence}, true)
+}
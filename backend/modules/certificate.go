@@ -0,0 +1,155 @@
+// Quoridor Chess 対局結果証明書
+// 対局終了時にサーバーキーで署名した結果を保存し、外部の大会運営が改ざんの有無を検証できるようにする
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const resultCertificateCollection = "result_certificates" // 結果証明書のストレージコレクション
+
+// ResultCertificate - 署名対象となる対局結果
+type ResultCertificate struct {
+	MatchID      string              `json:"match_id"`      // マッチID
+	Players      []CertificatePlayer `json:"players"`       // 対局者（色順）
+	WinnerID     string              `json:"winner_id"`     // 勝者のユーザーID
+	PositionHash string              `json:"position_hash"` // 最終局面のハッシュ（SHA-256）
+	MoveCount    int                 `json:"move_count"`    // 総手数
+	FinishedAt   int64               `json:"finished_at"`   // 対局終了時刻（Unix時刻）
+}
+
+// CertificatePlayer - 証明書に含めるプレイヤー情報
+type CertificatePlayer struct {
+	ID    string `json:"id"`
+	Color string `json:"color"`
+}
+
+// SignedResultCertificate - 証明書本体（JSON文字列）と署名
+// 検証側は certificate の文字列をそのままHMAC-SHA256で署名し、signature と比較する
+type SignedResultCertificate struct {
+	Certificate string `json:"certificate"` // 証明書のJSON
+	Signature   string `json:"signature"`   // HMAC-SHA256署名（16進数）
+}
+
+// GetResultCertificateRequest - get_result_certificate RPCのリクエスト
+type GetResultCertificateRequest struct {
+	MatchID string `json:"match_id"`
+}
+
+// canonicalPosition - 局面を一意な文字列に変換（色順のコマ位置と、座標順に並べた壁）
+func canonicalPosition(gs *GameState) string {
+	pawns := make([]string, 0, len(gs.Players))
+	for _, player := range gs.Players {
+		pawns = append(pawns, fmt.Sprintf("%s:%s", player.Color, squareName(player.Position.X, player.Position.Y)))
+	}
+	sort.Strings(pawns)
+
+	walls := make([]string, 0, len(gs.Board.Walls))
+	for _, wall := range gs.Board.Walls {
+		if wall.Start == nil {
+			continue
+		}
+		orientation := "v"
+		if wall.Horizontal {
+			orientation = "h"
+		}
+		walls = append(walls, orientation+squareName(wall.Start.X, wall.Start.Y))
+	}
+	sort.Strings(walls)
+
+	return strings.Join(pawns, ",") + "|" + strings.Join(walls, ",") + "|" + gs.CurrentTurn
+}
+
+// positionHash - 局面のSHA-256ハッシュを返す
+func positionHash(gs *GameState) string {
+	sum := sha256.Sum256([]byte(canonicalPosition(gs)))
+	return hex.EncodeToString(sum[:])
+}
+
+// signCertificate - 証明書のJSONをサーバーキーで署名
+func signCertificate(key string, certificate []byte) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(certificate)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// issueResultCertificate - 対局結果の証明書を作成して保存
+// サーバーキーが未設定のデプロイと、ボットとの練習対局では発行しない
+func (m *QuoridorChessMatch) issueResultCertificate(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule) {
+	key := envValue(ctx, EnvResultCertificateKey, "")
+	if key == "" || m.vsBot() {
+		return
+	}
+
+	certificate := &ResultCertificate{
+		MatchID:      m.matchID,
+		Players:      make([]CertificatePlayer, 0, len(m.gameState.Players)),
+		WinnerID:     m.gameState.Winner,
+		PositionHash: positionHash(m.gameState),
+		MoveCount:    m.ply(),
+		FinishedAt:   time.Now().Unix(),
+	}
+	for id, player := range m.gameState.Players {
+		certificate.Players = append(certificate.Players, CertificatePlayer{ID: id, Color: player.Color})
+	}
+	sort.Slice(certificate.Players, func(i, j int) bool {
+		return certificate.Players[i].Color > certificate.Players[j].Color
+	})
+
+	certificateJSON, err := json.Marshal(certificate)
+	if err != nil {
+		logger.Error("Failed to encode result certificate: %v", err)
+		return
+	}
+	signed := &SignedResultCertificate{
+		Certificate: string(certificateJSON),
+		Signature:   signCertificate(key, certificateJSON),
+	}
+	value, err := json.Marshal(signed)
+	if err != nil {
+		logger.Error("Failed to encode signed result certificate: %v", err)
+		return
+	}
+
+	if _, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      resultCertificateCollection,
+		Key:             m.matchID,
+		Value:           string(value),
+		PermissionRead:  2,
+		PermissionWrite: 0,
+	}}); err != nil {
+		logger.Error("Failed to store result certificate for match %s: %v", m.matchID, err)
+	}
+}
+
+// GetResultCertificate - 対局結果の証明書を返すRPC
+func GetResultCertificate(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	req := &GetResultCertificateRequest{}
+	if err := json.Unmarshal([]byte(payload), req); err != nil || req.MatchID == "" {
+		return "", errInvalidPayload
+	}
+
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: resultCertificateCollection,
+		Key:        req.MatchID,
+	}})
+	if err != nil {
+		logger.Error("get_result_certificate: failed to read certificate: %v", err)
+		return "", runtime.NewError("failed to read certificate", errCodeInternal)
+	}
+	if len(objects) == 0 {
+		return "", runtime.NewError("certificate not found", errCodeNotFound)
+	}
+	return objects[0].GetValue(), nil
+}
@@ -0,0 +1,110 @@
+// Quoridor Chess チャット履歴
+// マッチ内のチャットを通し番号付きで保持し、途中から観戦を始めた人がRPCで過去分を取得できるようにする
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	defaultChatHistoryLimit = 50  // 1回の取得件数の既定値
+	maxChatHistoryLimit     = 100 // 1回の取得件数の上限
+)
+
+// GetMatchChatRequest - get_match_chat RPCのリクエスト
+type GetMatchChatRequest struct {
+	MatchID   string `json:"match_id"`
+	BeforeSeq int64  `json:"before_seq"` // この番号より前のチャットを返す（0 の場合は最新から）
+	Limit     int    `json:"limit"`
+}
+
+// GetMatchChatResponse - get_match_chat RPCのレスポンス（古い順）
+type GetMatchChatResponse struct {
+	Messages []*ChatData `json:"messages"`
+	HasMore  bool        `json:"has_more"` // さらに古いチャットが残っているかどうか
+}
+
+// recordChat - 送信したチャットを履歴に追加（上限を超えたら古いものから破棄）
+func (m *QuoridorChessMatch) recordChat(entry *ChatData) {
+	m.chatSeq++
+	entry.Seq = m.chatSeq
+	m.chatHistory = append(m.chatHistory, entry)
+}
+
+// handleGetChatSignal - get_chat シグナルに対して before_seq より前のチャットを最大 limit 件返す
+func (m *QuoridorChessMatch) handleGetChatSignal(data json.RawMessage) string {
+	req := &GetMatchChatRequest{}
+	if err := json.Unmarshal(data, req); err != nil {
+		return signalError("invalid get_chat payload")
+	}
+
+	end := len(m.chatHistory)
+	if req.BeforeSeq > 0 {
+		end = 0
+		for end < len(m.chatHistory) && m.chatHistory[end].Seq < req.BeforeSeq {
+			end++
+		}
+	}
+	start := end - req.Limit
+	if start < 0 {
+		start = 0
+	}
+
+	response, _ := json.Marshal(map[string]interface{}{
+		"success":  true,
+		"messages": m.chatHistory[start:end],
+		"has_more": start > 0,
+	})
+	return string(response)
+}
+
+// GetMatchChat - マッチのチャット履歴をページ単位で返すRPC
+func GetMatchChat(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	if _, err := contextUserID(ctx); err != nil {
+		return "", err
+	}
+	req := &GetMatchChatRequest{}
+	if err := json.Unmarshal([]byte(payload), req); err != nil || req.MatchID == "" || len(req.MatchID) > maxMatchIDLength || req.BeforeSeq < 0 {
+		return "", errInvalidPayload
+	}
+	if req.Limit <= 0 {
+		req.Limit = defaultChatHistoryLimit
+	}
+	if req.Limit > maxChatHistoryLimit {
+		req.Limit = maxChatHistoryLimit
+	}
+
+	signal, err := json.Marshal(map[string]interface{}{
+		"type": "get_chat",
+		"data": req,
+	})
+	if err != nil {
+		return "", err
+	}
+	result, err := nk.MatchSignal(ctx, req.MatchID, string(signal))
+	if err != nil {
+		return "", runtime.NewError("match not found", errCodeNotFound)
+	}
+
+	var response struct {
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+		GetMatchChatResponse
+	}
+	if err := json.Unmarshal([]byte(result), &response); err != nil {
+		return "", runtime.NewError("invalid response from match", errCodeInternal)
+	}
+	if !response.Success {
+		return "", runtime.NewError(response.Error, errCodeInvalidArgument)
+	}
+
+	out, err := json.Marshal(&response.GetMatchChatResponse)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
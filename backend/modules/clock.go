@@ -0,0 +1,143 @@
+// Quoridor Chess 持ち時間
+// 手番のプレイヤーの持ち時間をティックごとに減らし、1手指すごとに加算時間を足す。持ち時間か1ターンの制限時間が切れたら負けとする
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	defaultClockIncrement = 5 * time.Second  // 持ち時間だけを指定した場合の1手ごとの加算時間
+	maxClockInitial       = 3 * time.Hour    // 指定できる持ち時間の上限
+	maxClockIncrement     = 5 * time.Minute  // 指定できる加算時間の上限
+	maxTurnTimeLimit      = 10 * time.Minute // 指定できる1ターンの制限時間の上限
+)
+
+// Clock - プレイヤーの持ち時間
+type Clock struct {
+	RemainingMs int64 `json:"remaining_ms"` // 残りの持ち時間（ミリ秒）
+	IncrementMs int64 `json:"increment_ms"` // 1手指すごとに加算される時間（ミリ秒）
+}
+
+// timeControl - マッチ作成時のパラメータから持ち時間と加算時間（ミリ秒）を決める
+// clock_initial_ms を指定した場合のみ時計を使い、指定がないか 0 の場合は時間制限なし
+func timeControl(params map[string]interface{}) (int64, int64) {
+	initial := int64(0)
+	increment := defaultClockIncrement.Milliseconds()
+	if value, ok := params["clock_initial_ms"].(float64); ok && value >= 0 && value <= float64(maxClockInitial.Milliseconds()) {
+		initial = int64(value)
+	}
+	if value, ok := params["clock_increment_ms"].(float64); ok && value >= 0 && value <= float64(maxClockIncrement.Milliseconds()) {
+		increment = int64(value)
+	}
+	if initial == 0 {
+		return 0, 0
+	}
+	return initial, increment
+}
+
+// turnTimeLimit - マッチ作成時のパラメータから1ターンの制限時間（ミリ秒）を決める（指定がなければ制限なし）
+func turnTimeLimit(params map[string]interface{}) int64 {
+	if value, ok := params["turn_time_limit_ms"].(float64); ok && value > 0 && value <= float64(maxTurnTimeLimit.Milliseconds()) {
+		return int64(value)
+	}
+	return 0
+}
+
+// newClock - ルールセットの持ち時間でプレイヤーの時計を作成（時間制限なしの場合は nil）
+func (r *Ruleset) newClock() *Clock {
+	if r.ClockInitialMs <= 0 {
+		return nil
+	}
+	return &Clock{RemainingMs: r.ClockInitialMs, IncrementMs: r.ClockIncrementMs}
+}
+
+// chargeClock - 前回の精算から経過した時間を手番のプレイヤーの持ち時間から減らす
+func (m *QuoridorChessMatch) chargeClock() *Clock {
+	now := time.Now()
+	last := m.clockUpdatedAt
+	m.clockUpdatedAt = now
+
+	player := m.gameState.Players[m.gameState.CurrentTurn]
+	if player == nil || player.Clock == nil || last.IsZero() {
+		return nil
+	}
+	player.Clock.RemainingMs -= now.Sub(last).Milliseconds()
+	if player.Clock.RemainingMs < 0 {
+		player.Clock.RemainingMs = 0
+	}
+	return player.Clock
+}
+
+// completeClockTurn - 指し終えたプレイヤーの持ち時間を精算し、加算時間を足す
+func (m *QuoridorChessMatch) completeClockTurn() {
+	if clock := m.chargeClock(); clock != nil {
+		clock.RemainingMs += clock.IncrementMs
+	}
+}
+
+// tickClock - 手番のプレイヤーの持ち時間を減らし、切れた場合は相手の勝ちとする
+func (m *QuoridorChessMatch) tickClock(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher) {
+	if !m.gameState.GameStarted {
+		m.clockUpdatedAt = time.Time{}
+		return
+	}
+	clock := m.chargeClock()
+	if clock == nil || clock.RemainingMs > 0 {
+		return
+	}
+
+	userID := m.gameState.CurrentTurn
+	opponentID := m.opponentOf(userID)
+	if opponentID == "" {
+		return
+	}
+	logger.Info("Player %s ran out of time in match %s", userID, m.matchID)
+	m.endGame(ctx, logger, nk, opponentID, ResultReasonTimeout)
+	updateMsg := map[string]interface{}{
+		"type": "game_state_update",
+		"data": m.gameState,
+	}
+	m.sendMessage(dispatcher, 1, updateMsg, nil, true)
+}
+
+// checkTurnTimeout - 手番が移ってから制限時間内に指さなかった場合、相手の勝ちとして turn_timeout を通知する
+func (m *QuoridorChessMatch) checkTurnTimeout(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher) {
+	if !m.gameState.GameStarted {
+		m.turnChangedAt = time.Time{}
+		return
+	}
+	if m.ruleset.TurnTimeLimitMs <= 0 {
+		return
+	}
+	// 対局開始・再開直後は最初のティックから数える
+	if m.turnChangedAt.IsZero() {
+		m.turnChangedAt = time.Now()
+		return
+	}
+	if time.Since(m.turnChangedAt).Milliseconds() < m.ruleset.TurnTimeLimitMs {
+		return
+	}
+
+	userID := m.gameState.CurrentTurn
+	opponentID := m.opponentOf(userID)
+	if opponentID == "" {
+		return
+	}
+	logger.Info("Player %s exceeded the turn time limit in match %s", userID, m.matchID)
+	m.endGame(ctx, logger, nk, opponentID, ResultReasonTurnTimeout)
+
+	timeoutMsg := map[string]interface{}{
+		"type": "turn_timeout",
+		"data": &TurnTimeoutData{PlayerID: userID, WinnerID: opponentID},
+	}
+	m.sendMessage(dispatcher, 1, timeoutMsg, nil, true)
+	updateMsg := map[string]interface{}{
+		"type": "game_state_update",
+		"data": m.gameState,
+	}
+	m.sendMessage(dispatcher, 1, updateMsg, nil, true)
+}
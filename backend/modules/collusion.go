@@ -0,0 +1,183 @@
+// Quoridor Chess 談合（勝ち星のやり取り）の検出
+// レーティング対象の対局で同じ2人の対戦履歴を記録し、一方的な結果や交互の勝ち負けが続く組み合わせを審査対象にする
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	pairHistoryCollection = "pair_history"     // 2人の対戦履歴のストレージコレクション（システムが所有）
+	pairHistoryWindow     = 7 * 24 * time.Hour // 対戦履歴を評価する期間
+	maxPairHistoryGames   = 50                 // 保持する対戦履歴の最大件数
+
+	collusionMinGames      = 5 // 一方的な結果と判断するのに必要な期間内の対戦数
+	collusionOneSidedRatio = 9 // 期間内の勝ちの割合がこの値（10分率）以上なら一方的
+	winTradingMinGames     = 6 // 交互の勝ち負けと判断するのに必要な連続対戦数
+	repeatPairingFreeGames = 3 // 期間内でレーティングの上限を設けない対戦数
+)
+
+// PairGame - 2人の対戦1回分
+type PairGame struct {
+	MatchID    string `json:"match_id"`
+	WinnerID   string `json:"winner_id"`
+	FinishedAt int64  `json:"finished_at"`
+}
+
+// PairHistory - 2人の対戦履歴（古い順）
+type PairHistory struct {
+	Games []PairGame `json:"games"`
+	// 次の対戦で得られるレーティングの割合（1.0 で制限なし）。レーティングの計算時に参照する
+	RatingGainFactor float64 `json:"rating_gain_factor"`
+}
+
+// pairKey - 2人のユーザーIDから順序に依存しないキーを作成
+func pairKey(a, b string) string {
+	ids := []string{a, b}
+	sort.Strings(ids)
+	return strings.Join(ids, ":")
+}
+
+// readPairHistory - 2人の対戦履歴を読み込む（未作成の場合は空）
+func readPairHistory(ctx context.Context, nk runtime.NakamaModule, key string) (*PairHistory, string, error) {
+	history := &PairHistory{RatingGainFactor: 1}
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: pairHistoryCollection,
+		Key:        key,
+	}})
+	if err != nil {
+		return nil, "", err
+	}
+	if len(objects) == 0 {
+		return history, "", nil
+	}
+	if err := json.Unmarshal([]byte(objects[0].GetValue()), history); err != nil {
+		return nil, "", err
+	}
+	return history, objects[0].GetVersion(), nil
+}
+
+// recentGames - 評価期間内の対戦を返す
+func (h *PairHistory) recentGames(now time.Time) []PairGame {
+	cutoff := now.Add(-pairHistoryWindow).Unix()
+	for i, game := range h.Games {
+		if game.FinishedAt >= cutoff {
+			return h.Games[i:]
+		}
+	}
+	return nil
+}
+
+// repeatPairingFactor - 期間内の対戦数に応じたレーティング獲得の割合（同じ相手との対戦が続くほど小さくなる）
+func repeatPairingFactor(games int) float64 {
+	factor := 1.0
+	for i := repeatPairingFreeGames; i < games; i++ {
+		factor /= 2
+	}
+	return factor
+}
+
+// isOneSided - 期間内の対戦で片方がほとんど勝っているかどうかを返す
+func isOneSided(games []PairGame) bool {
+	if len(games) < collusionMinGames {
+		return false
+	}
+	wins := make(map[string]int)
+	for _, game := range games {
+		wins[game.WinnerID]++
+	}
+	for _, count := range wins {
+		if count*10 >= len(games)*collusionOneSidedRatio {
+			return true
+		}
+	}
+	return false
+}
+
+// isWinTrading - 直近の対戦で勝者が毎回入れ替わっているかどうかを返す
+func isWinTrading(games []PairGame) bool {
+	if len(games) < winTradingMinGames {
+		return false
+	}
+	recent := games[len(games)-winTradingMinGames:]
+	for i := 1; i < len(recent); i++ {
+		if recent[i].WinnerID == "" || recent[i].WinnerID == recent[i-1].WinnerID {
+			return false
+		}
+	}
+	return true
+}
+
+// checkCollusion - レーティング対象の対局の終了時に対戦履歴を更新し、不自然な組み合わせを審査対象にする
+func (m *QuoridorChessMatch) checkCollusion(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule) {
+	// ボットとの対局は対戦履歴に残さない
+	if !m.gameState.Ranked || len(m.gameState.Players) != MaxPlayers || m.vsBot() {
+		return
+	}
+	playerIDs := make([]string, 0, len(m.gameState.Players))
+	for id := range m.gameState.Players {
+		playerIDs = append(playerIDs, id)
+	}
+	key := pairKey(playerIDs[0], playerIDs[1])
+
+	history, version, err := readPairHistory(ctx, nk, key)
+	if err != nil {
+		logger.Error("Failed to read pair history %s: %v", key, err)
+		return
+	}
+
+	now := time.Now()
+	history.Games = append(history.recentGames(now), PairGame{
+		MatchID:    m.matchID,
+		WinnerID:   m.gameState.Winner,
+		FinishedAt: now.Unix(),
+	})
+	if len(history.Games) > maxPairHistoryGames {
+		history.Games = history.Games[len(history.Games)-maxPairHistoryGames:]
+	}
+	history.RatingGainFactor = repeatPairingFactor(len(history.Games))
+
+	value, err := json.Marshal(history)
+	if err != nil {
+		return
+	}
+	if version == "" {
+		version = "*"
+	}
+	if _, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      pairHistoryCollection,
+		Key:             key,
+		Value:           string(value),
+		Version:         version,
+		PermissionRead:  0,
+		PermissionWrite: 0,
+	}}); err != nil {
+		logger.Error("Failed to write pair history %s: %v", key, err)
+	}
+
+	oneSided, trading := isOneSided(history.Games), isWinTrading(history.Games)
+	if !oneSided && !trading {
+		return
+	}
+	writeAuditEvent(ctx, logger, nk, &AuditEvent{
+		Type:    "collusion_suspected",
+		MatchID: m.matchID,
+		Details: map[string]interface{}{
+			"players":     playerIDs,
+			"games":       len(history.Games),
+			"one_sided":   oneSided,
+			"win_trading": trading,
+		},
+	})
+	for _, userID := range playerIDs {
+		if err := flagForReview(ctx, nk, userID, reviewFlagCollusion); err != nil {
+			logger.Error("Failed to flag %s for review: %v", userID, err)
+		}
+	}
+}
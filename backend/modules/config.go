@@ -0,0 +1,172 @@
+// Quoridor Chess モジュール設定
+// Nakama設定ファイルの runtime.env から渡される値を読み取る
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// runtime.env のキー
+const (
+	EnvRegion      = "region"        // このノードがホストするリージョン名
+	EnvKidSafeMode = "kid_safe_mode" // "true" でキッズセーフモードを有効化
+
+	EnvResultWebhookURL    = "result_webhook_url"    // 対局結果の送信先URL
+	EnvResultWebhookSecret = "result_webhook_secret" // 対局結果の署名に使うHMACキー
+
+	EnvResultCertificateKey = "result_certificate_key" // 結果証明書の署名に使うサーバーキー
+
+	EnvInviteURLBase = "invite_url_base" // 招待コードのQRに埋め込むURLの前半部分
+
+	EnvReconnectGraceSeconds = "reconnect_grace_seconds" // 対局中に切断したプレイヤーの再接続を待つ秒数
+
+	EnvNewsLobbyRoom = "news_lobby_room" // 注目の結果のお知らせを配信するロビーのチャンネル名（未設定の場合は配信しない）
+
+	EnvMatchMaxEvents   = "match_max_events"   // マッチごとにメモリに保持するイベントログの最大件数
+	EnvMatchMaxChat     = "match_max_chat"     // マッチごとにメモリに保持するチャットの最大件数
+	EnvMatchMaxNotation = "match_max_notation" // マッチごとにメモリに保持する棋譜の最大手数
+
+	EnvRatingSystem = "rating_system" // クライアントに表示するレーティング方式（"elo" / "glicko2"）
+
+	EnvMatchMaxSpectators = "match_max_spectators" // マッチごとの観戦者の上限（0 で観戦を受け付けない）
+
+	EnvBroadcastDelaySeconds = "broadcast_delay_seconds" // 中継ストリームに流すまで遅らせる秒数（対局者への助言を防ぐ）
+)
+
+const (
+	defaultReconnectGrace = 60 * time.Second // 再接続を待つ既定の時間
+	maxReconnectGrace     = 10 * time.Minute // 再接続を待つ時間の上限
+
+	defaultMatchMaxEvents   = 2000   // イベントログの既定の上限
+	defaultMatchMaxChat     = 500    // チャットの既定の上限
+	defaultMatchMaxNotation = 400    // 棋譜の既定の上限
+	minHistoryLimit         = 50     // 上限として設定できる最小値
+	maxHistoryLimit         = 100000 // 上限として設定できる最大値
+
+	defaultMatchMaxSpectators = 50   // 観戦者の既定の上限
+	maxMatchMaxSpectators     = 1000 // 観戦者の上限として設定できる最大値
+
+	defaultBroadcastDelay = 30 * time.Second // 中継を遅らせる既定の時間
+	maxBroadcastDelay     = 15 * time.Minute // 中継を遅らせる時間の上限
+)
+
+// envValue - runtime.env の値を取得（未設定の場合は defaultValue を返す）
+func envValue(ctx context.Context, key, defaultValue string) string {
+	env, ok := ctx.Value(runtime.RUNTIME_CTX_ENV).(map[string]string)
+	if !ok {
+		return defaultValue
+	}
+	if value, ok := env[key]; ok && value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// hostNode - マッチをホストしているNakamaノード名を返す
+func hostNode(ctx context.Context) string {
+	node, _ := ctx.Value(runtime.RUNTIME_CTX_NODE).(string)
+	return node
+}
+
+// hostRegion - このノードのリージョン名を返す（未設定の場合はノード名で代用）
+func hostRegion(ctx context.Context) string {
+	return envValue(ctx, EnvRegion, hostNode(ctx))
+}
+
+// kidSafeMode - キッズセーフモードが有効なデプロイかどうかを返す
+func kidSafeMode(ctx context.Context) bool {
+	return envValue(ctx, EnvKidSafeMode, "false") == "true"
+}
+
+// parseReconnectGrace - 再接続の猶予時間の設定値を解釈する（0 以上、上限以下の秒数）
+func parseReconnectGrace(value string) (time.Duration, error) {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 || time.Duration(seconds)*time.Second > maxReconnectGrace {
+		return 0, fmt.Errorf("%s must be an integer between 0 and %d", EnvReconnectGraceSeconds, int(maxReconnectGrace.Seconds()))
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// reconnectGrace - 対局中に切断したプレイヤーの再接続を待つ時間を返す（誤った値の場合は既定値）
+func reconnectGrace(ctx context.Context) time.Duration {
+	value := envValue(ctx, EnvReconnectGraceSeconds, "")
+	if value == "" {
+		return defaultReconnectGrace
+	}
+	grace, err := parseReconnectGrace(value)
+	if err != nil {
+		return defaultReconnectGrace
+	}
+	return grace
+}
+
+// parseBroadcastDelay - 中継を遅らせる秒数の設定値を解釈する（0 以上、上限以下）
+func parseBroadcastDelay(value string) (time.Duration, error) {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 || time.Duration(seconds)*time.Second > maxBroadcastDelay {
+		return 0, fmt.Errorf("%s must be an integer between 0 and %d", EnvBroadcastDelaySeconds, int(maxBroadcastDelay.Seconds()))
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// broadcastDelay - 中継ストリームに流すまで遅らせる時間を返す（誤った値の場合は既定値）
+func broadcastDelay(ctx context.Context) time.Duration {
+	value := envValue(ctx, EnvBroadcastDelaySeconds, "")
+	if value == "" {
+		return defaultBroadcastDelay
+	}
+	delay, err := parseBroadcastDelay(value)
+	if err != nil {
+		return defaultBroadcastDelay
+	}
+	return delay
+}
+
+// parseHistoryLimit - マッチのメモリ上の履歴の上限の設定値を解釈する
+func parseHistoryLimit(key, value string) (int, error) {
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < minHistoryLimit || limit > maxHistoryLimit {
+		return 0, fmt.Errorf("%s must be an integer between %d and %d", key, minHistoryLimit, maxHistoryLimit)
+	}
+	return limit, nil
+}
+
+// historyLimit - マッチのメモリ上の履歴の上限を返す（誤った値の場合は既定値）
+func historyLimit(ctx context.Context, key string, defaultLimit int) int {
+	value := envValue(ctx, key, "")
+	if value == "" {
+		return defaultLimit
+	}
+	limit, err := parseHistoryLimit(key, value)
+	if err != nil {
+		return defaultLimit
+	}
+	return limit
+}
+
+// parseSpectatorCap - マッチごとの観戦者の上限の設定値を解釈する（0 以上、上限以下）
+func parseSpectatorCap(value string) (int, error) {
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 || limit > maxMatchMaxSpectators {
+		return 0, fmt.Errorf("%s must be an integer between 0 and %d", EnvMatchMaxSpectators, maxMatchMaxSpectators)
+	}
+	return limit, nil
+}
+
+// spectatorCap - マッチごとの観戦者の上限を返す（誤った値の場合は既定値）
+func spectatorCap(ctx context.Context) int {
+	value := envValue(ctx, EnvMatchMaxSpectators, "")
+	if value == "" {
+		return defaultMatchMaxSpectators
+	}
+	limit, err := parseSpectatorCap(value)
+	if err != nil {
+		return defaultMatchMaxSpectators
+	}
+	return limit
+}
@@ -0,0 +1,159 @@
+// Quoridor Chess 起動時の設定検証
+// モジュールの初期化時に runtime.env の設定を検証し、必須の設定に誤りがあれば起動を止め、
+// 任意の連携（結果Webhook、結果証明書）の設定に誤りがあればカジュアル戦のみの縮退モードで起動する
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const minCertificateKeyLength = 16 // 結果証明書の署名キーとして受け付ける最小長
+
+// casualOnly - 任意の連携の設定に誤りがあり、レーティング戦を無効にしているかどうか（起動時に決定）
+var casualOnly bool
+
+// ConfigReport - 設定検証の結果
+type ConfigReport struct {
+	Errors   []string // 起動を止める誤り
+	Degraded []string // レーティング戦を無効にして起動する誤り
+	Warnings []string // 起動には影響しない誤り
+}
+
+// validateModuleConfig - runtime.env の設定を検証
+func validateModuleConfig(ctx context.Context) *ConfigReport {
+	report := &ConfigReport{}
+
+	if region := envValue(ctx, EnvRegion, ""); region != "" && !regionPattern.MatchString(region) {
+		report.Errors = append(report.Errors, fmt.Sprintf("%s %q must match %s", EnvRegion, region, regionPattern.String()))
+	}
+	// キッズセーフモードは誤った値で無効のまま起動しないよう、起動を止める
+	if value := envValue(ctx, EnvKidSafeMode, ""); value != "" && value != "true" && value != "false" {
+		report.Errors = append(report.Errors, fmt.Sprintf("%s must be \"true\" or \"false\", got %q", EnvKidSafeMode, value))
+	}
+	for variant, condition := range victoryConditions {
+		if condition == nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("variant %q has no victory condition", variant))
+		}
+	}
+
+	// 結果Webhookは署名付きでしか送らない
+	if webhookURL := envValue(ctx, EnvResultWebhookURL, ""); webhookURL != "" {
+		parsed, err := url.Parse(webhookURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			report.Degraded = append(report.Degraded, fmt.Sprintf("%s %q is not an http(s) URL", EnvResultWebhookURL, webhookURL))
+		} else if envValue(ctx, EnvResultWebhookSecret, "") == "" {
+			report.Degraded = append(report.Degraded, fmt.Sprintf("%s is set but %s is empty", EnvResultWebhookURL, EnvResultWebhookSecret))
+		}
+	}
+	if key := envValue(ctx, EnvResultCertificateKey, ""); key != "" && len(key) < minCertificateKeyLength {
+		report.Degraded = append(report.Degraded, fmt.Sprintf("%s must be at least %d characters", EnvResultCertificateKey, minCertificateKeyLength))
+	}
+	if base := envValue(ctx, EnvInviteURLBase, ""); base != "" && !strings.Contains(base, "://") {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("%s %q has no URL scheme", EnvInviteURLBase, base))
+	}
+
+	if value := envValue(ctx, EnvReconnectGraceSeconds, ""); value != "" {
+		if _, err := parseReconnectGrace(value); err != nil {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("%v, using the default of %d", err, int(defaultReconnectGrace.Seconds())))
+		}
+	}
+	if system := envValue(ctx, EnvRatingSystem, ""); system != "" && system != RatingSystemElo && system != RatingSystemGlicko2 {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("%s %q is not %q or %q, using %q", EnvRatingSystem, system, RatingSystemElo, RatingSystemGlicko2, RatingSystemElo))
+	}
+	if value := envValue(ctx, EnvMatchMaxSpectators, ""); value != "" {
+		if _, err := parseSpectatorCap(value); err != nil {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("%v, using the default of %d", err, defaultMatchMaxSpectators))
+		}
+	}
+	if value := envValue(ctx, EnvBroadcastDelaySeconds, ""); value != "" {
+		if _, err := parseBroadcastDelay(value); err != nil {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("%v, using the default of %d", err, int(defaultBroadcastDelay.Seconds())))
+		}
+	}
+	for _, limit := range []struct {
+		key          string
+		defaultLimit int
+	}{
+		{EnvMatchMaxEvents, defaultMatchMaxEvents},
+		{EnvMatchMaxChat, defaultMatchMaxChat},
+		{EnvMatchMaxNotation, defaultMatchMaxNotation},
+	} {
+		if value := envValue(ctx, limit.key, ""); value != "" {
+			if _, err := parseHistoryLimit(limit.key, value); err != nil {
+				report.Warnings = append(report.Warnings, fmt.Sprintf("%v, using the default of %d", err, limit.defaultLimit))
+			}
+		}
+	}
+
+	return report
+}
+
+// applyModuleConfig - 設定を検証し、誤りをログに出力する
+// 必須の設定に誤りがあればエラーを返し、任意の連携の誤りであれば縮退モードにする
+func applyModuleConfig(ctx context.Context, logger runtime.Logger) error {
+	report := validateModuleConfig(ctx)
+	for _, issue := range report.Errors {
+		logger.Error("Invalid module config: %s", issue)
+	}
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("invalid module config: %s", strings.Join(report.Errors, "; "))
+	}
+
+	casualOnly = len(report.Degraded) > 0
+	for _, issue := range report.Degraded {
+		logger.Warn("Module config problem, ranked play disabled: %s", issue)
+	}
+	for _, issue := range report.Warnings {
+		logger.Warn("Module config problem: %s", issue)
+	}
+	return nil
+}
+
+// loggingInitializer - 登録したハンドラーをモジュールのバージョンとともにログに出力する Initializer
+type loggingInitializer struct {
+	runtime.Initializer
+	logger runtime.Logger
+}
+
+func (i *loggingInitializer) RegisterRpc(id string, fn func(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error)) error {
+	if err := i.Initializer.RegisterRpc(id, fn); err != nil {
+		i.logger.Error("Failed to register RPC %s: %v", id, err)
+		return err
+	}
+	i.logger.Info("Registered RPC %s (module %s)", id, ModuleVersion)
+	return nil
+}
+
+func (i *loggingInitializer) RegisterMatch(name string, fn func(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule) (runtime.Match, error)) error {
+	if err := i.Initializer.RegisterMatch(name, fn); err != nil {
+		i.logger.Error("Failed to register match handler %s: %v", name, err)
+		return err
+	}
+	i.logger.Info("Registered match handler %s (module %s, protocol %d)", name, ModuleVersion, ProtocolVersion)
+	return nil
+}
+
+func (i *loggingInitializer) RegisterMatchmakerMatched(fn func(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, entries []runtime.MatchmakerEntry) (string, error)) error {
+	if err := i.Initializer.RegisterMatchmakerMatched(fn); err != nil {
+		i.logger.Error("Failed to register matchmaker matched hook: %v", err)
+		return err
+	}
+	i.logger.Info("Registered matchmaker matched hook (module %s)", ModuleVersion)
+	return nil
+}
+
+func (i *loggingInitializer) RegisterTournamentEnd(fn func(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, tournament *api.Tournament, end, reset int64) error) error {
+	if err := i.Initializer.RegisterTournamentEnd(fn); err != nil {
+		i.logger.Error("Failed to register tournament end hook: %v", err)
+		return err
+	}
+	i.logger.Info("Registered tournament end hook (module %s)", ModuleVersion)
+	return nil
+}
@@ -0,0 +1,220 @@
+// Quoridor Chess 接続品質の分析
+// 対局中の切断と再接続を対局記録に残し、リージョン・プラットフォーム別に集計して接続障害の傾向を把握できるようにする
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	defaultConnectivityHours = 24        // 集計対象の既定期間（時間）
+	maxConnectivityHours     = 168       // 集計対象の最大期間（時間）
+	maxConnectivityScan      = 5000      // 1回の集計で走査する対局結果の上限
+	connectivityPageSize     = 100       // 対局結果を読み込む1ページの件数
+	platformUnknown          = "unknown" // プラットフォームが不明な場合の集計上の値
+)
+
+// 参加時のメタデータで受け付けるプラットフォーム名
+var knownPlatforms = map[string]bool{
+	"ios":     true,
+	"android": true,
+	"web":     true,
+	"desktop": true,
+}
+
+// DisconnectRecord - 対局中の1回の切断の記録
+type DisconnectRecord struct {
+	PlayerID       string `json:"player_id"`       // 切断したプレイヤーのユーザーID
+	Platform       string `json:"platform"`        // 切断したプレイヤーのプラットフォーム
+	Ply            int    `json:"ply"`             // 切断した時点の手数
+	DisconnectedAt int64  `json:"disconnected_at"` // 切断時刻（Unix時刻、ミリ秒）
+	DurationMs     int64  `json:"duration_ms"`     // 再接続または対局終了までの時間（ミリ秒）
+	Reconnected    bool   `json:"reconnected"`     // 対局中に再接続できたかどうか
+	open           bool   // 再接続待ちの間は true
+}
+
+// ConnectivityReportRequest - admin_connectivity_report RPCのリクエスト
+type ConnectivityReportRequest struct {
+	Hours int `json:"hours"` // 直近何時間に終了した対局を集計するか
+}
+
+// ConnectivityGroup - リージョン・プラットフォームの組ごとの集計結果
+type ConnectivityGroup struct {
+	Region           string  `json:"region"`
+	Platform         string  `json:"platform"`
+	Matches          int     `json:"matches"`           // このプラットフォームのプレイヤーが参加した対局数
+	Disconnects      int     `json:"disconnects"`       // 切断回数
+	Reconnects       int     `json:"reconnects"`        // 再接続できた回数
+	ReconnectRate    float64 `json:"reconnect_rate"`    // 再接続できた割合
+	AvgDurationMs    int64   `json:"avg_duration_ms"`   // 切断していた時間の平均（ミリ秒）
+	DisconnectLosses int     `json:"disconnect_losses"` // 切断による負けの数
+	totalDurationMs  int64
+}
+
+// normalizePlatform - 参加時のメタデータのプラットフォーム名を集計用の値に揃える
+func normalizePlatform(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if knownPlatforms[value] {
+		return value
+	}
+	return platformUnknown
+}
+
+// platformOf - プレイヤーのプラットフォームを返す
+func (m *QuoridorChessMatch) platformOf(userID string) string {
+	if platform, ok := m.platforms[userID]; ok {
+		return platform
+	}
+	return platformUnknown
+}
+
+// connectivityTags - メトリクスに付けるタグ
+func (m *QuoridorChessMatch) connectivityTags(userID string) map[string]string {
+	return map[string]string{
+		"region":   m.label.Region,
+		"platform": m.platformOf(userID),
+	}
+}
+
+// openDisconnect - 切断を記録し、メトリクスに加算する
+func (m *QuoridorChessMatch) openDisconnect(nk runtime.NakamaModule, userID string, at time.Time) {
+	m.disconnects = append(m.disconnects, &DisconnectRecord{
+		PlayerID:       userID,
+		Platform:       m.platformOf(userID),
+		Ply:            m.ply(),
+		DisconnectedAt: at.UnixMilli(),
+		open:           true,
+	})
+	nk.MetricsCounterAdd("quoridor_disconnects", m.connectivityTags(userID), 1)
+}
+
+// closeDisconnect - 再接続待ちの切断を締めくくり、切断していた時間をメトリクスに記録する
+func (m *QuoridorChessMatch) closeDisconnect(nk runtime.NakamaModule, userID string, reconnected bool) {
+	for _, record := range m.disconnects {
+		if record.PlayerID != userID || !record.open {
+			continue
+		}
+		record.open = false
+		record.Reconnected = reconnected
+		record.DurationMs = time.Now().UnixMilli() - record.DisconnectedAt
+
+		tags := m.connectivityTags(userID)
+		if reconnected {
+			nk.MetricsCounterAdd("quoridor_reconnects", tags, 1)
+		}
+		nk.MetricsTimerRecord("quoridor_disconnect_duration", tags, time.Duration(record.DurationMs)*time.Millisecond)
+	}
+}
+
+// closeAllDisconnects - 対局終了時に再接続待ちのままの切断をすべて締めくくる
+func (m *QuoridorChessMatch) closeAllDisconnects(nk runtime.NakamaModule) {
+	for _, record := range m.disconnects {
+		if record.open {
+			m.closeDisconnect(nk, record.PlayerID, false)
+		}
+	}
+}
+
+// AdminConnectivityReport - 直近の対局の切断状況をリージョン・プラットフォーム別に集計するRPC（サーバー間呼び出しのみ）
+func AdminConnectivityReport(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	if err := requireServerCaller(ctx); err != nil {
+		return "", err
+	}
+	req := &ConnectivityReportRequest{}
+	if payload != "" {
+		if err := json.Unmarshal([]byte(payload), req); err != nil {
+			return "", errInvalidPayload
+		}
+	}
+	hours := req.Hours
+	if hours <= 0 {
+		hours = defaultConnectivityHours
+	}
+	if hours > maxConnectivityHours {
+		hours = maxConnectivityHours
+	}
+	since := time.Now().Add(-time.Duration(hours) * time.Hour).Unix()
+
+	groups := make(map[string]*ConnectivityGroup)
+	group := func(region, platform string) *ConnectivityGroup {
+		key := region + "/" + platform
+		if groups[key] == nil {
+			groups[key] = &ConnectivityGroup{Region: region, Platform: platform}
+		}
+		return groups[key]
+	}
+
+	scanned, matches := 0, 0
+	cursor := ""
+	for scanned < maxConnectivityScan {
+		objects, next, err := nk.StorageList(ctx, "", "", matchResultCollection, connectivityPageSize, cursor)
+		if err != nil {
+			logger.Error("admin_connectivity_report: failed to list match results: %v", err)
+			return "", runtime.NewError("failed to list match results", errCodeInternal)
+		}
+		for _, object := range objects {
+			scanned++
+			result := &MatchResult{}
+			if err := json.Unmarshal([]byte(object.GetValue()), result); err != nil || result.FinishedAt < since {
+				continue
+			}
+			matches++
+			for playerID, platform := range result.Platforms {
+				g := group(result.Region, platform)
+				g.Matches++
+				if result.Reason == ResultReasonDisconnect && result.WinnerID != playerID {
+					g.DisconnectLosses++
+				}
+			}
+			for _, record := range result.Disconnects {
+				g := group(result.Region, record.Platform)
+				g.Disconnects++
+				g.totalDurationMs += record.DurationMs
+				if record.Reconnected {
+					g.Reconnects++
+				}
+			}
+		}
+		if next == "" || len(objects) == 0 {
+			break
+		}
+		cursor = next
+	}
+
+	report := make([]*ConnectivityGroup, 0, len(groups))
+	for _, g := range groups {
+		if g.Disconnects > 0 {
+			g.ReconnectRate = float64(g.Reconnects) / float64(g.Disconnects)
+			g.AvgDurationMs = g.totalDurationMs / int64(g.Disconnects)
+		}
+		report = append(report, g)
+	}
+	// 切断の多い組から並べる
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Disconnects != report[j].Disconnects {
+			return report[i].Disconnects > report[j].Disconnects
+		}
+		if report[i].Region != report[j].Region {
+			return report[i].Region < report[j].Region
+		}
+		return report[i].Platform < report[j].Platform
+	})
+
+	response, err := json.Marshal(map[string]interface{}{
+		"hours":   hours,
+		"scanned": scanned,
+		"matches": matches,
+		"groups":  report,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
@@ -0,0 +1,196 @@
+// Quoridor Chess 盤面の訂正
+// サーバーのルール不具合などで誤った状態になった対局を、管理者が正しい局面に直して再開できるようにする
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// CorrectedPlayer - 訂正後のプレイヤーの状態
+type CorrectedPlayer struct {
+	Position *Position `json:"position"` // 訂正後の位置
+	Walls    int       `json:"walls"`    // 訂正後の残り壁数
+}
+
+// PositionCorrection - 訂正後の局面（correct_position シグナルのデータ）
+type PositionCorrection struct {
+	Players     map[string]*CorrectedPlayer `json:"players"`      // ユーザーID -> 訂正後の状態（対局中の全プレイヤー分が必要）
+	Walls       []Wall                      `json:"walls"`        // 訂正後の壁の配置
+	CurrentTurn string                      `json:"current_turn"` // 訂正後の手番のプレイヤーID
+	Reason      string                      `json:"reason"`       // 訂正の理由（監査ログと通知に使う）
+}
+
+// AdminCorrectPositionRequest - admin_correct_position RPCのリクエスト
+type AdminCorrectPositionRequest struct {
+	MatchID string `json:"match_id"`
+	PositionCorrection
+}
+
+// signalError - MatchSignal のエラー応答を作成
+func signalError(message string) string {
+	response, _ := json.Marshal(map[string]interface{}{"success": false, "error": message})
+	return string(response)
+}
+
+// validateCorrection - 訂正後の局面が対局として矛盾していないかを検証
+func (m *QuoridorChessMatch) validateCorrection(correction *PositionCorrection) error {
+	if !m.gameState.GameStarted {
+		return fmt.Errorf("game is not in progress")
+	}
+	if len(correction.Players) != len(m.gameState.Players) {
+		return fmt.Errorf("position must cover every player in the match")
+	}
+	if m.gameState.Players[correction.CurrentTurn] == nil {
+		return fmt.Errorf("current_turn must be a player in the match")
+	}
+
+	board := &Board{Size: m.gameState.Board.Size, Walls: correction.Walls}
+	for i, wall := range board.Walls {
+		if !board.WallOnGrid(wall) {
+			return fmt.Errorf("wall is not on a valid groove")
+		}
+		for _, other := range board.Walls[:i] {
+			if wallsConflict(wall, other) {
+				return fmt.Errorf("walls overlap or cross")
+			}
+		}
+	}
+
+	// 置かれた壁と残り壁数の合計は初期の壁数と一致する必要がある
+	wallsInHand := 0
+	occupied := make(map[Position]bool)
+	for id, player := range m.gameState.Players {
+		corrected := correction.Players[id]
+		if corrected == nil || corrected.Position == nil {
+			return fmt.Errorf("missing position for player %s", id)
+		}
+		pos := *corrected.Position
+		if !board.InBounds(pos.X, pos.Y) {
+			return fmt.Errorf("position out of bounds for player %s", id)
+		}
+		if occupied[pos] {
+			return fmt.Errorf("players cannot share a square")
+		}
+		occupied[pos] = true
+		goal := m.victoryCondition().Goal(board, player.Color)
+		if goal.Reached(pos.X, pos.Y) {
+			return fmt.Errorf("player %s is already on the goal", id)
+		}
+		if corrected.Walls < 0 || corrected.Walls > InitialWalls {
+			return fmt.Errorf("invalid wall count for player %s", id)
+		}
+		if board.ShortestPathTo(&pos, goal) < 0 {
+			return fmt.Errorf("player %s has no path to the goal", id)
+		}
+		wallsInHand += corrected.Walls
+	}
+	if wallsInHand+len(board.Walls) != InitialWalls*len(m.gameState.Players) {
+		return fmt.Errorf("placed walls and walls in hand do not add up")
+	}
+	return nil
+}
+
+// handlePositionCorrection - 訂正後の局面を検証して適用し、全員に再同期させる
+func (m *QuoridorChessMatch) handlePositionCorrection(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, data json.RawMessage) string {
+	correction := &PositionCorrection{}
+	if err := json.Unmarshal(data, correction); err != nil {
+		return signalError("invalid correction payload")
+	}
+	if err := m.validateCorrection(correction); err != nil {
+		return signalError(err.Error())
+	}
+
+	// 訂正前の局面を監査ログに残す
+	before := map[string]interface{}{
+		"walls":        m.gameState.Board.Walls,
+		"current_turn": m.gameState.CurrentTurn,
+	}
+	beforePlayers := make(map[string]*CorrectedPlayer, len(m.gameState.Players))
+	for id, player := range m.gameState.Players {
+		beforePlayers[id] = &CorrectedPlayer{Position: player.Position, Walls: player.Walls}
+	}
+	before["players"] = beforePlayers
+
+	for id, player := range m.gameState.Players {
+		corrected := correction.Players[id]
+		player.Position = &Position{X: corrected.Position.X, Y: corrected.Position.Y}
+		player.Walls = corrected.Walls
+	}
+	walls := make([]Wall, len(correction.Walls))
+	copy(walls, correction.Walls)
+	m.gameState.Board.Walls = walls
+	m.gameState.CurrentTurn = correction.CurrentTurn
+	m.gameState.LastAction = nil
+	m.gameState.TurnActions = []string{}
+	m.turnChangedAt = time.Now()
+	m.startTurnTimer()
+	m.recordEvent("position_corrected", EventSourceServer, "", map[string]interface{}{
+		"reason": correction.Reason,
+		"after":  correction,
+	})
+
+	writeAuditEvent(ctx, logger, nk, &AuditEvent{
+		Type:    "position_corrected",
+		MatchID: m.matchID,
+		Details: map[string]interface{}{
+			"reason": correction.Reason,
+			"before": before,
+			"after":  correction,
+		},
+	})
+
+	// 訂正後の局面で全員を再同期
+	msg := map[string]interface{}{
+		"type": "position_corrected",
+		"data": &PositionCorrectedData{
+			Reason:    correction.Reason,
+			GameState: m.gameState,
+		},
+	}
+	m.sendMessage(dispatcher, 1, msg, nil, true)
+
+	response, _ := json.Marshal(map[string]interface{}{"success": true, "game_state": m.gameState})
+	return string(response)
+}
+
+// AdminCorrectPosition - 対局中のマッチの局面を訂正するRPC（サーバー間呼び出しのみ）
+func AdminCorrectPosition(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	if err := requireServerCaller(ctx); err != nil {
+		return "", err
+	}
+	req := &AdminCorrectPositionRequest{}
+	if err := json.Unmarshal([]byte(payload), req); err != nil || req.MatchID == "" || req.Reason == "" {
+		return "", errInvalidPayload
+	}
+
+	signal, err := json.Marshal(map[string]interface{}{
+		"type": "correct_position",
+		"data": &req.PositionCorrection,
+	})
+	if err != nil {
+		return "", err
+	}
+	result, err := nk.MatchSignal(ctx, req.MatchID, string(signal))
+	if err != nil {
+		logger.Warn("admin_correct_position: signal to match %s failed: %v", req.MatchID, err)
+		return "", runtime.NewError("match not found", errCodeNotFound)
+	}
+
+	var response struct {
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(result), &response); err != nil {
+		return "", runtime.NewError("invalid response from match", errCodeInternal)
+	}
+	if !response.Success {
+		return "", runtime.NewError(response.Error, errCodeFailedPrecondition)
+	}
+	return result, nil
+}
@@ -0,0 +1,37 @@
+// Quoridor Chess 壁の風化バリアント
+// 置いてから一定のターン数が経った壁を盤面から取り除き、全員に通知する
+package main
+
+import "github.com/heroiclabs/nakama-common/runtime"
+
+// expireWalls - 風化した壁を取り除く（ルールセットで壁の寿命が決まっている場合のみ）
+func (m *QuoridorChessMatch) expireWalls(dispatcher runtime.MatchDispatcher) {
+	if m.ruleset == nil || m.ruleset.WallDecayTurns <= 0 {
+		return
+	}
+	// 両者が1手ずつ指して1ターン
+	lifetime := m.ruleset.WallDecayTurns * len(m.gameState.Players)
+	ply := m.ply()
+
+	board := m.gameState.Board
+	remaining := make([]Wall, 0, len(board.Walls))
+	expired := make([]Wall, 0)
+	for _, wall := range board.Walls {
+		if ply-wall.PlacedPly >= lifetime {
+			expired = append(expired, wall)
+			continue
+		}
+		remaining = append(remaining, wall)
+	}
+	if len(expired) == 0 {
+		return
+	}
+	board.Walls = remaining
+
+	m.recordEvent("walls_expired", EventSourceServer, "", map[string]interface{}{"walls": expired})
+	msg := map[string]interface{}{
+		"type": "walls_expired",
+		"data": &WallsExpiredData{Walls: expired},
+	}
+	m.sendMessage(dispatcher, 1, msg, nil, true)
+}
@@ -0,0 +1,435 @@
+// Quoridor Chess 対局結果への異議申し立て
+// 対局終了後の一定時間内に対局者が異議を申し立て、運営が管理用RPCで審査・解決できるようにする
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+	"unicode/utf8"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	disputeCollection               = "disputes"       // 異議申し立てのストレージコレクション（システムが所有、キーはマッチID）
+	disputeWindow                   = 30 * time.Minute // 対局終了から申し立てを受け付ける時間
+	maxDisputeDescription           = 1000             // 申し立て内容の最大文字数
+	defaultDisputeListLimit         = 50               // 一覧取得の既定件数
+	maxDisputeListLimit             = 100              // 一覧取得の最大件数
+	NotificationCodeDisputeResolved = 101              // 異議申し立ての審査結果通知
+)
+
+// 申し立ての種類
+var disputeCategories = map[string]bool{
+	"cheating":     true, // 不正の疑い
+	"server_error": true, // サーバーの不具合
+	"other":        true, // その他
+}
+
+// 申し立ての状態
+const (
+	DisputeStatusOpen     = "open"     // 審査待ち
+	DisputeStatusUpheld   = "upheld"   // 申し立てを認めた
+	DisputeStatusRejected = "rejected" // 申し立てを退けた
+)
+
+// Dispute - 異議申し立ての内容
+type Dispute struct {
+	MatchID              string `json:"match_id"`              // 対象のマッチID（監査ログと結果証明書の参照キー）
+	OpenedBy             string `json:"opened_by"`             // 申し立てたユーザーID
+	Category             string `json:"category"`              // 申し立ての種類
+	Description          string `json:"description"`           // 申し立て内容
+	Status               string `json:"status"`                // 状態
+	CertificateAvailable bool   `json:"certificate_available"` // 結果証明書が発行されているかどうか
+	RatingsFrozen        bool   `json:"ratings_frozen"`        // 審査中はこの対局によるレーティング変動を保留する
+	Resolution           string `json:"resolution,omitempty"`  // 運営からの回答
+	OpenedAt             int64  `json:"opened_at"`             // 申し立て時刻（Unix時刻）
+	ResolvedAt           int64  `json:"resolved_at,omitempty"` // 解決時刻（Unix時刻）
+}
+
+// OpenDisputeRequest - open_dispute RPCのリクエスト
+type OpenDisputeRequest struct {
+	MatchID     string `json:"match_id"`
+	Category    string `json:"category"`
+	Description string `json:"description"`
+}
+
+// ResolveDisputeRequest - admin_resolve_dispute RPCのリクエスト
+type ResolveDisputeRequest struct {
+	MatchID    string `json:"match_id"`
+	Status     string `json:"status"`     // "upheld" または "rejected"
+	Resolution string `json:"resolution"` // 申し立てたプレイヤーへの回答
+}
+
+// ListDisputesRequest - admin_list_disputes RPCのリクエスト
+type ListDisputesRequest struct {
+	Status string `json:"status"` // 指定した状態のみ返す（省略時はすべて）
+	Limit  int    `json:"limit"`
+	Cursor string `json:"cursor"`
+}
+
+// readDispute - 異議申し立てを読み込む（存在しない場合は nil）
+func readDispute(ctx context.Context, nk runtime.NakamaModule, matchID string) (*Dispute, string, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: disputeCollection,
+		Key:        matchID,
+	}})
+	if err != nil || len(objects) == 0 {
+		return nil, "", err
+	}
+	dispute := &Dispute{}
+	if err := json.Unmarshal([]byte(objects[0].GetValue()), dispute); err != nil {
+		return nil, "", err
+	}
+	return dispute, objects[0].GetVersion(), nil
+}
+
+// writeDispute - 異議申し立てを保存（version が "*" の場合は新規作成のみ）
+func writeDispute(ctx context.Context, nk runtime.NakamaModule, dispute *Dispute, version string) error {
+	value, err := json.Marshal(dispute)
+	if err != nil {
+		return err
+	}
+	_, err = nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      disputeCollection,
+		Key:             dispute.MatchID,
+		Value:           string(value),
+		Version:         version,
+		PermissionRead:  0,
+		PermissionWrite: 0,
+	}})
+	return err
+}
+
+// readMatchRatingChanges - 対局によるレーティングの変動の記録を取得（なければ nil）
+func readMatchRatingChanges(ctx context.Context, nk runtime.NakamaModule, matchID string) (*MatchRatingChanges, string, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: matchRatingChangeCollection,
+		Key:        matchID,
+	}})
+	if err != nil || len(objects) == 0 {
+		return nil, "", err
+	}
+	record := &MatchRatingChanges{}
+	if err := json.Unmarshal([]byte(objects[0].GetValue()), record); err != nil {
+		return nil, "", err
+	}
+	return record, objects[0].GetVersion(), nil
+}
+
+// holdDisputedRatings - 異議申し立てを受けた対局で反映済みのレーティングの変動を取り消し、審査が終わるまで保留する
+func holdDisputedRatings(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, matchID string) error {
+	record, version, err := readMatchRatingChanges(ctx, nk, matchID)
+	if err != nil || record == nil || record.Status != MatchRatingsApplied {
+		return err
+	}
+	record.Status = MatchRatingsHeld
+	return rewriteMatchRatings(ctx, logger, nk, record, version, -1)
+}
+
+// settleDisputedRatings - 異議申し立ての審査結果に応じて対局によるレーティングの変動を確定する
+// 申し立てを認めた場合は変動を取り消したままにし（保留していなければ取り消し）、退けた場合は保留していた変動を反映し直す
+func settleDisputedRatings(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, matchID string, upheld bool) error {
+	record, version, err := readMatchRatingChanges(ctx, nk, matchID)
+	if err != nil || record == nil {
+		return err // 記録がなければレーティング対象外の対局
+	}
+	switch {
+	case record.Status == MatchRatingsHeld && upheld:
+		record.Status = MatchRatingsReverted
+		return rewriteMatchRatings(ctx, logger, nk, record, version, 0)
+	case record.Status == MatchRatingsHeld:
+		record.Status = MatchRatingsApplied
+		return rewriteMatchRatings(ctx, logger, nk, record, version, 1)
+	case record.Status == MatchRatingsApplied && upheld:
+		record.Status = MatchRatingsReverted
+		return rewriteMatchRatings(ctx, logger, nk, record, version, -1)
+	}
+	return nil
+}
+
+// rewriteMatchRatings - 対局によるレーティングの変動を反映（sign が 1）または取り消し（-1）、記録の状態と一緒に書き込む
+// 対局後（反映）または対局前（取り消し）のレーティング全体を書き戻すため、最高レーティング、配置戦の進み具合、ティアも元に戻る
+// 記録は読み込んだ時点の version で書き込み、二重に反映・取り消しされないようにする
+func rewriteMatchRatings(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, record *MatchRatingChanges, recordVersion string, sign int) error {
+	now := time.Now()
+	writes := make([]*runtime.StorageWrite, 0, len(record.Changes)+1)
+	scores := make(map[string]*PlayerRating, len(record.Changes))
+	for id, change := range record.Changes {
+		// 審査フラグで変動させなかったプレイヤーは対象外
+		if sign == 0 || change.Frozen || change.Previous == nil || change.Updated == nil {
+			continue
+		}
+		_, version, err := readRating(ctx, nk, id)
+		if err != nil {
+			return err
+		}
+		rating := change.Updated.snapshot()
+		if sign < 0 {
+			rating = change.Previous.snapshot()
+		}
+		rating.UpdatedAt = now.Unix()
+		value, err := json.Marshal(rating)
+		if err != nil {
+			return err
+		}
+		if version == "" {
+			version = "*"
+		}
+		writes = append(writes, &runtime.StorageWrite{
+			Collection:      ratingCollection,
+			Key:             id,
+			Value:           string(value),
+			Version:         version,
+			PermissionRead:  2,
+			PermissionWrite: 0,
+		})
+		scores[id] = rating
+	}
+
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	writes = append(writes, &runtime.StorageWrite{
+		Collection:      matchRatingChangeCollection,
+		Key:             record.MatchID,
+		Value:           string(value),
+		Version:         recordVersion,
+		PermissionRead:  0,
+		PermissionWrite: 0,
+	})
+	if _, err := nk.StorageWrite(ctx, writes); err != nil {
+		return err
+	}
+
+	if kidSafeMode(ctx) {
+		return nil // キッズセーフモードではランキングに記録しない
+	}
+	system := ratingSystem(ctx)
+	for id, rating := range scores {
+		score := int64(rating.Rating)
+		if system == RatingSystemGlicko2 {
+			score = int64(rating.Glicko.Rating + 0.5)
+		}
+		if _, err := nk.LeaderboardRecordWrite(ctx, LeaderboardRating, id, "", score, 0, nil, nil); err != nil {
+			logger.Warn("Failed to write settled rating of %s: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// hasResultCertificate - 対局の結果証明書が保存されているかどうかを返す
+func hasResultCertificate(ctx context.Context, nk runtime.NakamaModule, matchID string) bool {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: resultCertificateCollection,
+		Key:        matchID,
+	}})
+	return err == nil && len(objects) > 0
+}
+
+// OpenDispute - 終了した対局の結果に異議を申し立てるRPC（対局者のみ、終了後一定時間内）
+func OpenDispute(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return "", err
+	}
+	req := &OpenDisputeRequest{}
+	if err := json.Unmarshal([]byte(payload), req); err != nil || req.MatchID == "" || len(req.MatchID) > maxMatchIDLength {
+		return "", errInvalidPayload
+	}
+	if !disputeCategories[req.Category] || utf8.RuneCountInString(req.Description) > maxDisputeDescription {
+		return "", errInvalidPayload
+	}
+
+	result, err := readMatchResult(ctx, nk, req.MatchID)
+	if err != nil {
+		logger.Error("open_dispute: failed to read match result: %v", err)
+		return "", runtime.NewError("failed to read match result", errCodeInternal)
+	}
+	if result == nil {
+		return "", runtime.NewError("match result not found", errCodeNotFound)
+	}
+	participant := false
+	for _, id := range result.PlayerIDs {
+		if id == userID {
+			participant = true
+		}
+	}
+	if !participant {
+		return "", runtime.NewError("only players of the match can open a dispute", errCodePermissionDenied)
+	}
+	if time.Since(time.Unix(result.FinishedAt, 0)) > disputeWindow {
+		return "", runtime.NewError("dispute window has closed", errCodeFailedPrecondition)
+	}
+
+	existing, _, err := readDispute(ctx, nk, req.MatchID)
+	if err != nil {
+		logger.Error("open_dispute: failed to read dispute: %v", err)
+		return "", runtime.NewError("failed to read dispute", errCodeInternal)
+	}
+	if existing != nil {
+		return "", runtime.NewError("a dispute is already open for this match", errCodeAlreadyExists)
+	}
+	// レーティング対象の対局は、審査が終わるまで反映済みの変動を取り消して保留する
+	if result.Ranked {
+		if err := holdDisputedRatings(ctx, logger, nk, req.MatchID); err != nil {
+			logger.Error("open_dispute: failed to hold ratings: %v", err)
+			return "", runtime.NewError("failed to hold ratings", errCodeInternal)
+		}
+	}
+
+	dispute := &Dispute{
+		MatchID:              req.MatchID,
+		OpenedBy:             userID,
+		Category:             req.Category,
+		Description:          req.Description,
+		Status:               DisputeStatusOpen,
+		CertificateAvailable: hasResultCertificate(ctx, nk, req.MatchID),
+		RatingsFrozen:        result.Ranked,
+		OpenedAt:             time.Now().Unix(),
+	}
+	// 1つの対局につき申し立ては1件まで
+	if err := writeDispute(ctx, nk, dispute, "*"); err != nil {
+		// 同時に申し立てられた場合、保留の扱いは先に書き込まれた申し立てに任せる
+		return "", runtime.NewError("a dispute is already open for this match", errCodeAlreadyExists)
+	}
+
+	writeAuditEvent(ctx, logger, nk, &AuditEvent{
+		Type:    "dispute_opened",
+		MatchID: req.MatchID,
+		UserID:  userID,
+		Details: map[string]interface{}{
+			"category":       req.Category,
+			"ratings_frozen": dispute.RatingsFrozen,
+		},
+	})
+
+	response, err := json.Marshal(dispute)
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
+
+// AdminListDisputes - 異議申し立ての一覧を返すRPC（サーバー間呼び出しのみ）
+func AdminListDisputes(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	if err := requireServerCaller(ctx); err != nil {
+		return "", err
+	}
+	req := &ListDisputesRequest{}
+	if payload != "" {
+		if err := json.Unmarshal([]byte(payload), req); err != nil {
+			return "", errInvalidPayload
+		}
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultDisputeListLimit
+	}
+	if limit > maxDisputeListLimit {
+		limit = maxDisputeListLimit
+	}
+
+	// 状態で絞り込むとページが埋まらないことがあるため、件数に達するか最後まで読み進める
+	// 残りの件数だけ読み込むので、返すカーソルは最後に読んだ申し立ての次から始まる
+	disputes := make([]*Dispute, 0, limit)
+	cursor := req.Cursor
+	for len(disputes) < limit {
+		objects, next, err := nk.StorageList(ctx, "", "", disputeCollection, limit-len(disputes), cursor)
+		if err != nil {
+			logger.Error("admin_list_disputes: failed to list disputes: %v", err)
+			return "", runtime.NewError("failed to list disputes", errCodeInternal)
+		}
+		for _, object := range objects {
+			dispute := &Dispute{}
+			if err := json.Unmarshal([]byte(object.GetValue()), dispute); err != nil {
+				continue
+			}
+			if req.Status != "" && dispute.Status != req.Status {
+				continue
+			}
+			disputes = append(disputes, dispute)
+		}
+		cursor = next
+		if cursor == "" {
+			break
+		}
+	}
+
+	response, err := json.Marshal(map[string]interface{}{
+		"disputes": disputes,
+		"cursor":   cursor,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
+
+// AdminResolveDispute - 異議申し立てを解決し、申し立てたプレイヤーに通知するRPC（サーバー間呼び出しのみ）
+func AdminResolveDispute(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	if err := requireServerCaller(ctx); err != nil {
+		return "", err
+	}
+	req := &ResolveDisputeRequest{}
+	if err := json.Unmarshal([]byte(payload), req); err != nil || req.MatchID == "" {
+		return "", errInvalidPayload
+	}
+	if req.Status != DisputeStatusUpheld && req.Status != DisputeStatusRejected {
+		return "", errInvalidPayload
+	}
+
+	dispute, version, err := readDispute(ctx, nk, req.MatchID)
+	if err != nil {
+		logger.Error("admin_resolve_dispute: failed to read dispute: %v", err)
+		return "", runtime.NewError("failed to read dispute", errCodeInternal)
+	}
+	if dispute == nil {
+		return "", runtime.NewError("dispute not found", errCodeNotFound)
+	}
+	if dispute.Status != DisputeStatusOpen {
+		return "", runtime.NewError("dispute is already resolved", errCodeFailedPrecondition)
+	}
+
+	// 保留・反映済みのレーティングの変動を審査結果に合わせて確定してから申し立てを閉じる
+	if err := settleDisputedRatings(ctx, logger, nk, dispute.MatchID, req.Status == DisputeStatusUpheld); err != nil {
+		logger.Error("admin_resolve_dispute: failed to settle ratings: %v", err)
+		return "", runtime.NewError("failed to settle ratings", errCodeInternal)
+	}
+
+	dispute.Status = req.Status
+	dispute.Resolution = req.Resolution
+	dispute.RatingsFrozen = false
+	dispute.ResolvedAt = time.Now().Unix()
+	if err := writeDispute(ctx, nk, dispute, version); err != nil {
+		return "", runtime.NewError("dispute was modified concurrently", errCodeFailedPrecondition)
+	}
+
+	writeAuditEvent(ctx, logger, nk, &AuditEvent{
+		Type:    "dispute_resolved",
+		MatchID: dispute.MatchID,
+		UserID:  dispute.OpenedBy,
+		Details: map[string]interface{}{
+			"status":     dispute.Status,
+			"resolution": dispute.Resolution,
+		},
+	})
+
+	content := map[string]interface{}{
+		"match_id":   dispute.MatchID,
+		"status":     dispute.Status,
+		"resolution": dispute.Resolution,
+	}
+	if err := nk.NotificationSend(ctx, dispute.OpenedBy, "Your dispute has been reviewed", content, NotificationCodeDisputeResolved, "", true); err != nil {
+		logger.Warn("Failed to notify %s of dispute resolution: %v", dispute.OpenedBy, err)
+	}
+
+	response, err := json.Marshal(dispute)
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
@@ -0,0 +1,148 @@
+// Quoridor Chess マッチメイキングの辞退対策
+// マッチメイキングで成立したマッチに時間内に参加しなかったプレイヤーに、段階的に長くなる待ち行列の利用停止を科す
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	dodgeCollection = "matchmaking_dodges" // 辞退記録のストレージコレクション（本人が閲覧可能）
+	dodgeKey        = "dodges"             // 辞退記録のストレージキー
+
+	matchmadeReadyTimeout = 30 * time.Second // 成立したマッチに両者が参加するまでの猶予
+	dodgeBaseCooldown     = time.Minute      // 1回目の辞退の利用停止時間（以降は辞退のたびに2倍）
+	dodgeMaxCooldown      = 30 * time.Minute // 利用停止時間の上限
+	dodgeForgetAfter      = 24 * time.Hour   // 辞退がこの期間なければ回数をリセット
+)
+
+// DodgeRecord - プレイヤーの辞退記録
+type DodgeRecord struct {
+	Count         int   `json:"count"`          // 直近の辞退回数
+	LastDodgeAt   int64 `json:"last_dodge_at"`  // 最後に辞退した時刻（Unix時刻）
+	CooldownUntil int64 `json:"cooldown_until"` // 待ち行列を利用できるようになる時刻（Unix時刻）
+}
+
+// readDodgeRecord - 辞退記録を読み込む（未作成の場合は空の記録）
+func readDodgeRecord(ctx context.Context, nk runtime.NakamaModule, userID string) (*DodgeRecord, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: dodgeCollection,
+		Key:        dodgeKey,
+		UserID:     userID,
+	}})
+	if err != nil {
+		return nil, err
+	}
+	record := &DodgeRecord{}
+	if len(objects) == 0 {
+		return record, nil
+	}
+	if err := json.Unmarshal([]byte(objects[0].GetValue()), record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// recordDodge - 辞退を記録し、回数に応じた利用停止時間を設定する
+func recordDodge(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID, matchID string) {
+	record, err := readDodgeRecord(ctx, nk, userID)
+	if err != nil {
+		logger.Error("Failed to read dodge record for %s: %v", userID, err)
+		return
+	}
+
+	now := time.Now()
+	if now.Sub(time.Unix(record.LastDodgeAt, 0)) > dodgeForgetAfter {
+		record.Count = 0
+	}
+	record.Count++
+	record.LastDodgeAt = now.Unix()
+	cooldown := dodgeBaseCooldown
+	for i := 1; i < record.Count && cooldown < dodgeMaxCooldown; i++ {
+		cooldown *= 2
+	}
+	if cooldown > dodgeMaxCooldown {
+		cooldown = dodgeMaxCooldown
+	}
+	record.CooldownUntil = now.Add(cooldown).Unix()
+
+	value, err := json.Marshal(record)
+	if err != nil {
+		logger.Error("Failed to encode dodge record: %v", err)
+		return
+	}
+	if _, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      dodgeCollection,
+		Key:             dodgeKey,
+		UserID:          userID,
+		Value:           string(value),
+		PermissionRead:  1,
+		PermissionWrite: 0,
+	}}); err != nil {
+		logger.Error("Failed to store dodge record for %s: %v", userID, err)
+		return
+	}
+	logger.Info("Player %s dodged match %s (%d recent), queue cooldown %s", userID, matchID, record.Count, cooldown)
+}
+
+// dodgeCooldownUntil - 待ち行列の利用停止中であれば解除時刻（Unix時刻）を返す（停止中でなければ 0）
+func dodgeCooldownUntil(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID string) int64 {
+	record, err := readDodgeRecord(ctx, nk, userID)
+	if err != nil {
+		logger.Warn("Failed to read dodge record for %s: %v", userID, err)
+		return 0
+	}
+	if record.CooldownUntil <= time.Now().Unix() {
+		return 0
+	}
+	return record.CooldownUntil
+}
+
+// checkDodgeCooldown - 待ち行列の利用停止中であればエラーを返す
+func checkDodgeCooldown(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID string) error {
+	until := dodgeCooldownUntil(ctx, logger, nk, userID)
+	if until == 0 {
+		return nil
+	}
+	remaining := until - time.Now().Unix()
+	return runtime.NewError(fmt.Sprintf("matchmaking cooldown for %d more seconds after leaving matched games", remaining), errCodeFailedPrecondition)
+}
+
+// matchmadePlayers - マッチ作成時のパラメータから、マッチメイキングで組み合わせた対局者を返す
+func matchmadePlayers(params map[string]interface{}) []string {
+	value, ok := params["matchmade_players"].(string)
+	if !ok || value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// checkReadyTimeout - マッチメイキングで成立したマッチに猶予時間内に両者が揃わなければ、
+// 参加しなかったプレイヤーを辞退として記録してマッチを終了する。戻り値が nil の場合はマッチを終了する
+func (m *QuoridorChessMatch) checkReadyTimeout(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher) interface{} {
+	if len(m.matchmadePlayers) == 0 || m.gameState.GameStarted || m.gameState.Winner != "" {
+		return m.gameState
+	}
+	if time.Since(time.Unix(m.gameState.CreatedAt, 0)) < matchmadeReadyTimeout {
+		return m.gameState
+	}
+
+	for _, userID := range m.matchmadePlayers {
+		if _, ok := m.presences[userID]; !ok {
+			recordDodge(ctx, logger, nk, userID, m.matchID)
+		}
+	}
+	m.recordEvent("ready_check_failed", EventSourceServer, "", nil)
+	msg := map[string]interface{}{
+		"type": "match_cancelled",
+		"data": &MatchCancelledData{Reason: "opponent_did_not_join"},
+	}
+	m.sendMessage(dispatcher, 1, msg, nil, true)
+	return nil
+}
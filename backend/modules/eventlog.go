@@ -0,0 +1,70 @@
+// Quoridor Chess 対局イベントログ
+// プレイヤーの操作に加えてサーバーが下した判断（自動移動、放置・切断による決着、盤面の訂正など）を
+// 発生順に記録し、対局終了時に保存して観測どおりの対局を再現できるようにする
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const matchEventCollection = "match_events" // 対局イベントログのストレージコレクション（システムが所有、キーはマッチID）
+
+// イベントの発生元
+const (
+	EventSourcePlayer = "player" // プレイヤーの操作
+	EventSourceServer = "server" // サーバーの判断
+)
+
+// MatchEvent - 対局イベントログの1件
+type MatchEvent struct {
+	Seq       int                    `json:"seq"`                 // ログ内の通し番号
+	Tick      int64                  `json:"tick"`                // 発生したティック
+	Timestamp int64                  `json:"timestamp"`           // 発生時刻（Unix時刻、ミリ秒）
+	Kind      string                 `json:"kind"`                // イベントの種類
+	Source    string                 `json:"source"`              // 発生元（"player" / "server"）
+	PlayerID  string                 `json:"player_id,omitempty"` // 関係するプレイヤーID
+	Data      map[string]interface{} `json:"data,omitempty"`      // イベントの詳細
+}
+
+// MatchEventLog - 保存する対局イベントログ
+type MatchEventLog struct {
+	MatchID string        `json:"match_id"`
+	Events  []*MatchEvent `json:"events"`
+	Chunks  int           `json:"chunks,omitempty"` // これより前のイベントを書き出したまとまりの数（match_history_chunks に保存）
+}
+
+// recordEvent - 対局イベントをログに追加
+func (m *QuoridorChessMatch) recordEvent(kind, source, playerID string, data map[string]interface{}) {
+	m.eventSeq++
+	m.events = append(m.events, &MatchEvent{
+		Seq:       m.eventSeq,
+		Tick:      m.tick,
+		Timestamp: time.Now().UnixMilli(),
+		Kind:      kind,
+		Source:    source,
+		PlayerID:  playerID,
+		Data:      data,
+	})
+}
+
+// persistEventLog - 対局終了時にイベントログを保存
+func (m *QuoridorChessMatch) persistEventLog(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule) {
+	value, err := json.Marshal(&MatchEventLog{MatchID: m.matchID, Events: m.events, Chunks: m.historyChunks[HistoryKindEvents]})
+	if err != nil {
+		logger.Error("Failed to encode event log: %v", err)
+		return
+	}
+	if _, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      matchEventCollection,
+		Key:             m.matchID,
+		Value:           string(value),
+		PermissionRead:  2,
+		PermissionWrite: 0,
+	}}); err != nil {
+		logger.Error("Failed to store event log for match %s: %v", m.matchID, err)
+	}
+}
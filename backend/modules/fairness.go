@@ -0,0 +1,39 @@
+// Quoridor Chess 公平性チェック
+// レーティング対象の対局で、カジュアル戦専用の解析・ヒント系メッセージを拒否する
+package main
+
+import (
+	"context"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// カジュアル戦でのみ許可されるメッセージタイプ（解析・ヒント系）
+var casualOnlyMessageTypes = map[string]bool{
+	"set_training_mode": true,
+	"hint":              true,
+	"get_hint":          true,
+	"analysis":          true,
+	"evaluate":          true,
+}
+
+// rejectRankedAssistance - レーティング対象の対局でカジュアル専用メッセージが送られた場合に拒否する
+// 拒否した場合は true を返し、送信者への通知と監査ログの記録を行う
+func (m *QuoridorChessMatch) rejectRankedAssistance(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, msg runtime.MatchData, msgType string) bool {
+	if !m.gameState.Ranked || !casualOnlyMessageTypes[msgType] {
+		return false
+	}
+
+	writeAuditEvent(ctx, logger, nk, &AuditEvent{
+		Type:    "ranked_assistance_attempt",
+		MatchID: m.matchID,
+		UserID:  msg.GetUserId(),
+		Details: map[string]interface{}{
+			"message_type": msgType,
+		},
+	})
+
+	// 送信者本人に拒否を通知
+	m.rejectAction(dispatcher, msg.GetUserId(), msgType, "not allowed in ranked games")
+	return true
+}
@@ -0,0 +1,157 @@
+// Quoridor Chess 機能フラグ
+// リスクのあるゲームプレイの変更を一部のマッチだけで有効にし、問題があればすぐに無効に戻せるようにする
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"hash/fnv"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	featureFlagCollection = "feature_flags" // 機能フラグのストレージコレクション（システムが所有）
+	featureFlagKey        = "flags"         // 機能フラグのストレージキー
+)
+
+// 機能フラグ名
+const (
+	FlagAutoMove = "auto_move" // 指せる手が1つしかないときの自動移動
+)
+
+// defaultFeatureFlags - 設定が保存されていないときの既定値
+var defaultFeatureFlags = map[string]bool{
+	FlagAutoMove: true,
+}
+
+// FeatureFlag - 機能フラグ1件分の設定
+type FeatureFlag struct {
+	Enabled bool `json:"enabled"` // false の場合は全マッチで無効
+	Percent int  `json:"percent"` // 有効にするマッチの割合（0-100）
+}
+
+// SetFeatureFlagRequest - admin_set_feature_flag RPCのリクエスト
+type SetFeatureFlagRequest struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	Percent int    `json:"percent"`
+}
+
+// readFeatureFlags - 保存されている機能フラグを読み込む
+func readFeatureFlags(ctx context.Context, nk runtime.NakamaModule) (map[string]*FeatureFlag, string, error) {
+	flags := make(map[string]*FeatureFlag)
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: featureFlagCollection,
+		Key:        featureFlagKey,
+	}})
+	if err != nil {
+		return nil, "", err
+	}
+	if len(objects) == 0 {
+		return flags, "", nil
+	}
+	if err := json.Unmarshal([]byte(objects[0].GetValue()), &flags); err != nil {
+		return nil, "", err
+	}
+	return flags, objects[0].GetVersion(), nil
+}
+
+// flagBucket - フラグ名と対象（マッチID）から 0-99 のバケットを求める
+// 同じマッチでは常に同じ結果になり、フラグごとに対象の偏りが変わる
+func flagBucket(name, subject string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name + ":" + subject))
+	return int(h.Sum32() % 100)
+}
+
+// resolveFeatureFlags - マッチに適用する機能フラグを決定（読み込みに失敗した場合は既定値）
+func resolveFeatureFlags(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, subject string) map[string]bool {
+	resolved := make(map[string]bool, len(defaultFeatureFlags))
+	for name, enabled := range defaultFeatureFlags {
+		resolved[name] = enabled
+	}
+
+	flags, _, err := readFeatureFlags(ctx, nk)
+	if err != nil {
+		logger.Warn("Failed to read feature flags, using defaults: %v", err)
+		return resolved
+	}
+	for name, flag := range flags {
+		resolved[name] = flag.Enabled && flagBucket(name, subject) < flag.Percent
+	}
+	return resolved
+}
+
+// featureEnabled - このマッチで機能フラグが有効かどうかを返す
+func (m *QuoridorChessMatch) featureEnabled(name string) bool {
+	return m.flags[name]
+}
+
+// AdminSetFeatureFlag - 機能フラグを設定するRPC（サーバー間呼び出しのみ、新しく作成されるマッチから反映）
+func AdminSetFeatureFlag(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	if err := requireServerCaller(ctx); err != nil {
+		return "", err
+	}
+	req := &SetFeatureFlagRequest{}
+	if err := json.Unmarshal([]byte(payload), req); err != nil || req.Name == "" || req.Percent < 0 || req.Percent > 100 {
+		return "", errInvalidPayload
+	}
+
+	flags, version, err := readFeatureFlags(ctx, nk)
+	if err != nil {
+		logger.Error("admin_set_feature_flag: failed to read flags: %v", err)
+		return "", runtime.NewError("failed to read feature flags", errCodeInternal)
+	}
+	flags[req.Name] = &FeatureFlag{Enabled: req.Enabled, Percent: req.Percent}
+
+	value, err := json.Marshal(flags)
+	if err != nil {
+		return "", err
+	}
+	if version == "" {
+		version = "*"
+	}
+	if _, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      featureFlagCollection,
+		Key:             featureFlagKey,
+		Value:           string(value),
+		Version:         version,
+		PermissionRead:  0,
+		PermissionWrite: 0,
+	}}); err != nil {
+		return "", runtime.NewError("feature flags were modified concurrently", errCodeFailedPrecondition)
+	}
+
+	writeAuditEvent(ctx, logger, nk, &AuditEvent{
+		Type: "feature_flag_updated",
+		Details: map[string]interface{}{
+			"name":    req.Name,
+			"enabled": req.Enabled,
+			"percent": req.Percent,
+		},
+	})
+	return string(value), nil
+}
+
+// AdminListFeatureFlags - 保存されている機能フラグを返すRPC（サーバー間呼び出しのみ）
+func AdminListFeatureFlags(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	if err := requireServerCaller(ctx); err != nil {
+		return "", err
+	}
+	flags, _, err := readFeatureFlags(ctx, nk)
+	if err != nil {
+		logger.Error("admin_list_feature_flags: failed to read flags: %v", err)
+		return "", runtime.NewError("failed to read feature flags", errCodeInternal)
+	}
+
+	response, err := json.Marshal(map[string]interface{}{
+		"flags":    flags,
+		"defaults": defaultFeatureFlags,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
@@ -0,0 +1,235 @@
+// Quoridor Chess プレイヤーのフォロー
+// フォローしたプレイヤーが対局を開始したときに、観戦用の通知を送る
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	followersCollection = "followers" // フォロワー一覧（フォローされる側が所有）
+	followingCollection = "following" // フォロー中の一覧（フォローする側が所有）
+	followListKey       = "list"      // 一覧のストレージキー
+	maxFollowing        = 100         // 1人がフォローできる最大人数
+	maxFollowers        = 1000        // 通知対象にするフォロワーの最大人数
+
+	NotificationCodeFollowedMatchStarted = 100 // フォロー中のプレイヤーの対局開始通知
+)
+
+// FollowList - フォロー関係の一覧（ユーザーIDのリスト）
+type FollowList struct {
+	UserIDs []string `json:"user_ids"`
+}
+
+// FollowPlayerRequest - フォロー操作RPCのリクエスト
+type FollowPlayerRequest struct {
+	UserID string `json:"user_id"`
+}
+
+// readFollowList - フォロー関係の一覧を読み込む（未作成の場合は空）
+func readFollowList(ctx context.Context, nk runtime.NakamaModule, collection, userID string) (*FollowList, string, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: collection,
+		Key:        followListKey,
+		UserID:     userID,
+	}})
+	if err != nil {
+		return nil, "", err
+	}
+
+	list := &FollowList{UserIDs: []string{}}
+	if len(objects) == 0 {
+		return list, "", nil
+	}
+	if err := json.Unmarshal([]byte(objects[0].GetValue()), list); err != nil {
+		return nil, "", err
+	}
+	return list, objects[0].GetVersion(), nil
+}
+
+// followListWrite - フォロー関係の一覧の書き込み内容を作成
+func followListWrite(collection, userID, version string, list *FollowList) (*runtime.StorageWrite, error) {
+	value, err := json.Marshal(list)
+	if err != nil {
+		return nil, err
+	}
+	if version == "" {
+		version = "*"
+	}
+	return &runtime.StorageWrite{
+		Collection:      collection,
+		Key:             followListKey,
+		UserID:          userID,
+		Value:           string(value),
+		Version:         version,
+		PermissionRead:  0,
+		PermissionWrite: 0,
+	}, nil
+}
+
+// indexOf - リスト内のユーザーIDの位置を返す（存在しない場合は -1）
+func (l *FollowList) indexOf(userID string) int {
+	for i, id := range l.UserIDs {
+		if id == userID {
+			return i
+		}
+	}
+	return -1
+}
+
+// updateFollow - フォロー関係を追加または削除し、双方の一覧を一度に保存する
+func updateFollow(ctx context.Context, nk runtime.NakamaModule, followerID, targetID string, follow bool) (*FollowList, error) {
+	following, followingVersion, err := readFollowList(ctx, nk, followingCollection, followerID)
+	if err != nil {
+		return nil, err
+	}
+	followers, followersVersion, err := readFollowList(ctx, nk, followersCollection, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	if follow {
+		if following.indexOf(targetID) < 0 {
+			if len(following.UserIDs) >= maxFollowing {
+				return nil, runtime.NewError("following list is full", errCodeResourceExhausted)
+			}
+			following.UserIDs = append(following.UserIDs, targetID)
+		}
+		if followers.indexOf(followerID) < 0 {
+			followers.UserIDs = append(followers.UserIDs, followerID)
+		}
+	} else {
+		if i := following.indexOf(targetID); i >= 0 {
+			following.UserIDs = append(following.UserIDs[:i], following.UserIDs[i+1:]...)
+		}
+		if i := followers.indexOf(followerID); i >= 0 {
+			followers.UserIDs = append(followers.UserIDs[:i], followers.UserIDs[i+1:]...)
+		}
+	}
+
+	followingWrite, err := followListWrite(followingCollection, followerID, followingVersion, following)
+	if err != nil {
+		return nil, err
+	}
+	followersWrite, err := followListWrite(followersCollection, targetID, followersVersion, followers)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{followingWrite, followersWrite}); err != nil {
+		return nil, err
+	}
+	return following, nil
+}
+
+// handleFollowRequest - follow_player / unfollow_player の共通処理
+func handleFollowRequest(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, payload string, follow bool) (string, error) {
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return "", err
+	}
+	req := &FollowPlayerRequest{}
+	if err := json.Unmarshal([]byte(payload), req); err != nil || req.UserID == "" {
+		return "", errInvalidPayload
+	}
+	if req.UserID == userID {
+		return "", runtime.NewError("cannot follow yourself", errCodeInvalidArgument)
+	}
+	if follow {
+		// フォロー先のユーザーが存在することを確認
+		users, err := nk.UsersGetId(ctx, []string{req.UserID}, nil)
+		if err != nil || len(users) == 0 {
+			return "", runtime.NewError("user not found", errCodeNotFound)
+		}
+	}
+
+	following, err := updateFollow(ctx, nk, userID, req.UserID, follow)
+	if err != nil {
+		if runtimeErr, ok := err.(*runtime.Error); ok {
+			return "", runtimeErr
+		}
+		logger.Error("Failed to update follow list: %v", err)
+		return "", runtime.NewError("failed to update follow list", errCodeInternal)
+	}
+
+	response, err := json.Marshal(following)
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
+
+// FollowPlayer - プレイヤーをフォローするRPC
+func FollowPlayer(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	return handleFollowRequest(ctx, logger, nk, payload, true)
+}
+
+// UnfollowPlayer - プレイヤーのフォローを解除するRPC
+func UnfollowPlayer(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	return handleFollowRequest(ctx, logger, nk, payload, false)
+}
+
+// ListFollowing - フォロー中のプレイヤー一覧を返すRPC
+func ListFollowing(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return "", err
+	}
+	following, _, err := readFollowList(ctx, nk, followingCollection, userID)
+	if err != nil {
+		logger.Error("list_following: failed to read follow list: %v", err)
+		return "", runtime.NewError("failed to read follow list", errCodeInternal)
+	}
+
+	response, err := json.Marshal(following)
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
+
+// notifyFollowers - 対局を開始したプレイヤーのフォロワーへ観戦用の通知を送る
+// 通知の送信はマッチループを止めないよう非同期で行う
+func (m *QuoridorChessMatch) notifyFollowers(logger runtime.Logger, nk runtime.NakamaModule) {
+	// キッズセーフモードでは知らない相手からの観戦を許可しない（匿名モードでは誰が対局しているかを知らせない）
+	if m.kidSafe || m.anonymous {
+		return
+	}
+
+	type startedPlayer struct{ id, username string }
+	players := make([]startedPlayer, 0, len(m.gameState.Players))
+	for id, player := range m.gameState.Players {
+		if m.isBot(id) {
+			continue
+		}
+		players = append(players, startedPlayer{id: id, username: player.Username})
+	}
+	matchID := m.matchID
+
+	go func() {
+		ctx := context.Background()
+		for _, player := range players {
+			followers, _, err := readFollowList(ctx, nk, followersCollection, player.id)
+			if err != nil {
+				logger.Warn("Failed to read followers of %s: %v", player.id, err)
+				continue
+			}
+			for i, followerID := range followers.UserIDs {
+				if i >= maxFollowers {
+					break
+				}
+				content := map[string]interface{}{
+					"match_id":  matchID,
+					"player_id": player.id,
+					"username":  player.username,
+				}
+				if err := nk.NotificationSend(ctx, followerID, "Followed player started a match", content, NotificationCodeFollowedMatchStarted, "", false); err != nil {
+					logger.Warn("Failed to notify follower %s: %v", followerID, err)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,205 @@
+// Quoridor Chess 対局終了処理
+// 勝者が決まった時点で呼び出され、結果の外部連携などを行う
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const matchResultCollection = "match_results" // 対局結果のストレージコレクション（システムが所有）
+
+// 決着の理由
+const (
+	ResultReasonGoal        = "goal"         // ゴール到達
+	ResultReasonAFK         = "afk"          // 手番中の放置
+	ResultReasonDisconnect  = "disconnect"   // 対局中の切断
+	ResultReasonTimeout     = "timeout"      // 持ち時間切れ
+	ResultReasonTurnTimeout = "turn_timeout" // 1ターンの制限時間切れ
+	ResultReasonResign      = "resign"       // 投了
+	ResultReasonStaleDraw   = "stale_draw"   // 停滞による引き分け（勝者なし）
+	ResultReasonAborted     = "aborted"      // 停止したマッチをサーバーが打ち切った（勝者なし、レーティングは変動しない）
+)
+
+// MatchResult - 終了した対局の記録（異議申し立ての受付判定などに使う）
+type MatchResult struct {
+	MatchID     string              `json:"match_id"`              // マッチID
+	PlayerIDs   []string            `json:"player_ids"`            // 対局者のユーザーID
+	Usernames   map[string]string   `json:"usernames"`             // 対局者ごとのユーザー名
+	WinnerID    string              `json:"winner_id"`             // 勝者のユーザーID
+	Reason      string              `json:"reason"`                // 決着の理由
+	Ranked      bool                `json:"ranked"`                // レーティング対象の対局かどうか
+	Tournament  string              `json:"tournament,omitempty"`  // トーナメント戦の場合はトーナメントID
+	Variant     string              `json:"variant"`               // バリアント名
+	MoveCount   int                 `json:"move_count"`            // 対局全体の手数
+	Region      string              `json:"region"`                // 対局をホストしたリージョン
+	Platforms   map[string]string   `json:"platforms"`             // 対局者ごとのプラットフォーム
+	Disconnects []*DisconnectRecord `json:"disconnects,omitempty"` // 対局中の切断の記録
+	BytesSent   map[string]int64    `json:"bytes_sent"`            // 対局者ごとに送信したバイト数
+	StartedAt   int64               `json:"started_at"`            // 対局開始時刻（Unix時刻）
+	FinishedAt  int64               `json:"finished_at"`           // 対局終了時刻（Unix時刻）
+	DurationMs  int64               `json:"duration_ms"`           // 対局時間（ミリ秒）
+}
+
+// endGame - 勝者と決着の理由を確定して対局を終了する
+// 状態更新の通知は呼び出し側で行う
+func (m *QuoridorChessMatch) endGame(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, winnerID, reason string) {
+	m.gameState.Winner = winnerID
+	m.gameState.ResultReason = reason
+	m.gameState.GameStarted = false
+	m.recordEvent("game_over", EventSourceServer, winnerID, map[string]interface{}{"reason": reason})
+	m.onGameOver(ctx, logger, nk)
+}
+
+// onGameOver - 対局終了時の後処理
+// endGame から一度だけ呼び出される
+func (m *QuoridorChessMatch) onGameOver(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule) {
+	// 再接続を待っている切断は対局終了の時点で締めくくる
+	m.closeAllDisconnects(nk)
+
+	// 匿名モードでは対局の記録をいっさい残さない
+	if !m.anonymous {
+		// 対局結果と、再現用のイベントログ・再生用の手順を記録
+		m.recordMatchResult(ctx, logger, nk)
+		m.persistEventLog(ctx, logger, nk)
+		m.persistReplay(ctx, logger, nk)
+
+		// 改ざん検証用の結果証明書を発行
+		m.issueResultCertificate(ctx, logger, nk)
+
+		// 勝敗と思考時間をプレイヤー統計に反映
+		m.updatePlayerStats(ctx, logger, nk)
+
+		// 節目に達したプレイヤーに称号を贈る（更新後の統計を使う）
+		m.awardMilestoneTitles(ctx, logger, nk)
+	}
+
+	// 対局時間を今日の利用時間に加算
+	m.recordPlayTime(ctx, logger, nk)
+
+	// わざと負けた疑いがあれば審査対象として記録
+	m.checkSandbagging(ctx, logger, nk)
+
+	// 同じ相手との不自然な勝ち負けのやり取りを検出
+	m.checkCollusion(ctx, logger, nk)
+
+	// レーティング対象の対局は両者のレーティングを更新（審査フラグと対戦履歴の結果を反映するため検出の後に行う）
+	m.updateRatings(ctx, logger, nk)
+
+	// 勝利数・連勝数・レーティングをリーダーボードに記録（統計とレーティングの更新後に行う）
+	if !m.anonymous {
+		m.writeLeaderboards(ctx, logger, nk)
+	}
+
+	// トーナメント戦は結果をトーナメントに記録
+	m.submitTournamentResult(ctx, logger, nk)
+
+	// レーティング対象の対局は外部のレーティングサービスへ結果を送信
+	if m.gameState.Ranked {
+		m.sendResultWebhook(ctx, logger, nk)
+	}
+}
+
+// recordMatchResult - 対局結果をストレージに保存
+func (m *QuoridorChessMatch) recordMatchResult(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule) {
+	now := time.Now()
+	result := &MatchResult{
+		MatchID:     m.matchID,
+		PlayerIDs:   make([]string, 0, len(m.gameState.Players)),
+		Usernames:   make(map[string]string, len(m.gameState.Players)),
+		WinnerID:    m.gameState.Winner,
+		Reason:      m.gameState.ResultReason,
+		Ranked:      m.gameState.Ranked,
+		Tournament:  m.gameState.TournamentID,
+		Variant:     m.ruleset.Name,
+		MoveCount:   m.ply(),
+		Region:      m.label.Region,
+		Platforms:   make(map[string]string, len(m.gameState.Players)),
+		Disconnects: m.disconnects,
+		BytesSent:   m.bytesSent(),
+		FinishedAt:  now.Unix(),
+	}
+	if !m.gameStartedAt.IsZero() {
+		result.StartedAt = m.gameStartedAt.Unix()
+		result.DurationMs = now.Sub(m.gameStartedAt).Milliseconds()
+	}
+	for id, player := range m.gameState.Players {
+		result.PlayerIDs = append(result.PlayerIDs, id)
+		result.Usernames[id] = player.Username
+		result.Platforms[id] = m.platformOf(id)
+	}
+
+	value, err := json.Marshal(result)
+	if err != nil {
+		logger.Error("Failed to encode match result: %v", err)
+		return
+	}
+	writes := []*runtime.StorageWrite{{
+		Collection:      matchResultCollection,
+		Key:             m.matchID,
+		Value:           string(value),
+		PermissionRead:  2,
+		PermissionWrite: 0,
+	}}
+	// 対局者ごとの対局履歴にも同じ結果を書き込み、get_match_history で新しい順に一覧できるようにする
+	if !m.playtest {
+		for _, id := range result.PlayerIDs {
+			if m.isBot(id) {
+				continue
+			}
+			writes = append(writes, &runtime.StorageWrite{
+				Collection:      matchHistoryCollection,
+				Key:             matchHistoryKey(result.FinishedAt, m.matchID),
+				UserID:          id,
+				Value:           string(value),
+				PermissionRead:  1,
+				PermissionWrite: 0,
+			})
+		}
+	}
+	if _, err := nk.StorageWrite(ctx, writes); err != nil {
+		logger.Error("Failed to store result for match %s: %v", m.matchID, err)
+	}
+}
+
+// readMatchResult - 対局結果を読み込む（存在しない場合は nil）
+func readMatchResult(ctx context.Context, nk runtime.NakamaModule, matchID string) (*MatchResult, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: matchResultCollection,
+		Key:        matchID,
+	}})
+	if err != nil || len(objects) == 0 {
+		return nil, err
+	}
+	result := &MatchResult{}
+	if err := json.Unmarshal([]byte(objects[0].GetValue()), result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// handleResign - 投了を受け付けて相手の勝ちで対局を終了し、全員に game_over を通知する
+func (m *QuoridorChessMatch) handleResign(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, userID string) {
+	if !m.gameState.GameStarted || m.gameState.Players[userID] == nil {
+		return
+	}
+	opponentID := m.opponentOf(userID)
+	if opponentID == "" {
+		return
+	}
+
+	logger.Info("Player %s resigned match %s", userID, m.matchID)
+	m.endGame(ctx, logger, nk, opponentID, ResultReasonResign)
+	msg := map[string]interface{}{
+		"type": "game_over",
+		"data": &GameOverData{
+			WinnerID:  opponentID,
+			Reason:    ResultReasonResign,
+			GameState: m.gameState,
+		},
+	}
+	m.sendMessage(dispatcher, 1, msg, nil, true)
+}
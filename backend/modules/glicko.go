@@ -0,0 +1,110 @@
+// Quoridor Chess Glicko-2レーティング
+// レーティングに加えて信頼度（レーティング偏差）と変動性を持ち、しばらく対局していないプレイヤーほど不確かなレーティングとして扱う
+package main
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	glickoInitialRating     = 1500.0             // 初めてレーティング戦を指すプレイヤーのレーティング
+	glickoInitialDeviation  = 350.0              // 初期のレーティング偏差（最大値を兼ねる）
+	glickoInitialVolatility = 0.06               // 初期の変動性
+	glickoTau               = 0.5                // 変動性の変化を抑える定数
+	glickoScale             = 173.7178           // Glicko と Glicko-2 の尺度の変換係数
+	glickoConvergence       = 0.000001           // 変動性の計算の収束判定
+	glickoRatingPeriod      = 7 * 24 * time.Hour // 対局がない期間の偏差の増加に使う1期間の長さ
+)
+
+// GlickoRating - Glicko-2のレーティング
+type GlickoRating struct {
+	Rating       float64 `json:"rating"`         // レーティング
+	Deviation    float64 `json:"deviation"`      // レーティング偏差（大きいほど不確か）
+	Volatility   float64 `json:"volatility"`     // 変動性（成績のぶれの大きさ）
+	LastPlayedAt int64   `json:"last_played_at"` // 最後にレーティング戦を指した時刻（Unix時刻）
+}
+
+// newGlickoRating - 初期値のGlicko-2レーティングを返す
+func newGlickoRating() *GlickoRating {
+	return &GlickoRating{
+		Rating:     glickoInitialRating,
+		Deviation:  glickoInitialDeviation,
+		Volatility: glickoInitialVolatility,
+	}
+}
+
+// currentDeviation - 最後の対局からの経過期間に応じて広げたレーティング偏差を返す
+func (g *GlickoRating) currentDeviation(now time.Time) float64 {
+	if g.LastPlayedAt == 0 {
+		return g.Deviation
+	}
+	periods := now.Sub(time.Unix(g.LastPlayedAt, 0)).Hours() / glickoRatingPeriod.Hours()
+	if periods <= 0 {
+		return g.Deviation
+	}
+	phi := g.Deviation / glickoScale
+	phi = math.Sqrt(phi*phi + periods*g.Volatility*g.Volatility)
+	return math.Min(phi*glickoScale, glickoInitialDeviation)
+}
+
+// glickoG - 相手のレーティング偏差による重み
+func glickoG(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
+
+// glickoUpdate - 1局の結果（勝ち 1、引き分け 0.5、負け 0）から新しいレーティングを計算する
+// 相手の値は対局前のものを使う。どちらも変更せず新しい値を返す
+func glickoUpdate(player, opponent *GlickoRating, score float64, now time.Time) *GlickoRating {
+	mu := (player.Rating - glickoInitialRating) / glickoScale
+	phi := player.currentDeviation(now) / glickoScale
+	muJ := (opponent.Rating - glickoInitialRating) / glickoScale
+	phiJ := opponent.currentDeviation(now) / glickoScale
+
+	g := glickoG(phiJ)
+	expected := 1 / (1 + math.Exp(-g*(mu-muJ)))
+	v := 1 / (g * g * expected * (1 - expected))
+	delta := v * g * (score - expected)
+
+	// 変動性を反復計算で求める（Illinois法）
+	a := math.Log(player.Volatility * player.Volatility)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/(glickoTau*glickoTau)
+	}
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*glickoTau) < 0 {
+			k++
+		}
+		B = a - k*glickoTau
+	}
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > glickoConvergence {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	volatility := math.Exp(A / 2)
+
+	phiStar := math.Sqrt(phi*phi + volatility*volatility)
+	newPhi := 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	newMu := mu + newPhi*newPhi*g*(score-expected)
+
+	return &GlickoRating{
+		Rating:       newMu*glickoScale + glickoInitialRating,
+		Deviation:    math.Min(newPhi*glickoScale, glickoInitialDeviation),
+		Volatility:   volatility,
+		LastPlayedAt: now.Unix(),
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestGlickoG(t *testing.T) {
+	// Glickman「Example of the Glicko-2 system」の相手ごとの重み
+	tests := []struct {
+		deviation float64
+		want      float64
+	}{
+		{30, 0.9955},
+		{100, 0.9531},
+		{300, 0.7242},
+	}
+	for _, tt := range tests {
+		if got := glickoG(tt.deviation / glickoScale); math.Abs(got-tt.want) > 0.0001 {
+			t.Errorf("glickoG(%v) = %.4f, want %.4f", tt.deviation, got, tt.want)
+		}
+	}
+}
+
+func TestGlickoUpdate(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	// Glickman の例と同じプレイヤー（1500, 200, 0.06）が、例の3人の相手とそれぞれ1局だけ指した場合の値
+	player := &GlickoRating{Rating: 1500, Deviation: 200, Volatility: 0.06}
+	tests := []struct {
+		name          string
+		opponent      *GlickoRating
+		score         float64
+		wantRating    float64
+		wantDeviation float64
+	}{
+		{"1400に勝つ", &GlickoRating{Rating: 1400, Deviation: 30, Volatility: 0.06}, 1, 1563.56, 175.40},
+		{"1550に負ける", &GlickoRating{Rating: 1550, Deviation: 100, Volatility: 0.06}, 0, 1426.69, 175.90},
+		{"1700に負ける", &GlickoRating{Rating: 1700, Deviation: 300, Volatility: 0.06}, 0, 1455.86, 186.98},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := glickoUpdate(player, tt.opponent, tt.score, now)
+			if math.Abs(got.Rating-tt.wantRating) > 0.01 {
+				t.Errorf("rating = %.2f, want %.2f", got.Rating, tt.wantRating)
+			}
+			if math.Abs(got.Deviation-tt.wantDeviation) > 0.01 {
+				t.Errorf("deviation = %.2f, want %.2f", got.Deviation, tt.wantDeviation)
+			}
+			if math.Abs(got.Volatility-0.06) > 0.0001 {
+				t.Errorf("volatility = %.5f, want about 0.06", got.Volatility)
+			}
+			if got.LastPlayedAt != now.Unix() {
+				t.Errorf("last played at = %d, want %d", got.LastPlayedAt, now.Unix())
+			}
+		})
+	}
+	if player.Rating != 1500 || player.Deviation != 200 || player.LastPlayedAt != 0 {
+		t.Errorf("glickoUpdate modified the player: %+v", player)
+	}
+}
+
+func TestGlickoCurrentDeviation(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	rating := &GlickoRating{Rating: 1500, Deviation: 50, Volatility: 0.06, LastPlayedAt: now.Unix()}
+	if got := rating.currentDeviation(now); got != 50 {
+		t.Errorf("deviation right after a game = %v, want 50", got)
+	}
+	// 1期間の空白で φ' = sqrt(φ^2 + σ^2) だけ広がる
+	want := math.Sqrt(math.Pow(50/glickoScale, 2)+0.06*0.06) * glickoScale
+	if got := rating.currentDeviation(now.Add(glickoRatingPeriod)); math.Abs(got-want) > 0.01 {
+		t.Errorf("deviation after one period = %.2f, want %.2f", got, want)
+	}
+	// 長い空白でも初期値を超えない
+	if got := rating.currentDeviation(now.Add(10000 * glickoRatingPeriod)); got != glickoInitialDeviation {
+		t.Errorf("deviation after a long break = %v, want %v", got, glickoInitialDeviation)
+	}
+}
@@ -0,0 +1,67 @@
+// Quoridor Chess ヘルスチェック
+// ロードバランサーのプローブやデプロイ確認のため、Goモジュール自体の稼働状況を返す
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// ヘルスチェックで数えるマッチ数の上限
+const healthCheckMatchLimit = 1000
+
+// HealthStatus - ヘルスチェックRPCのレスポンス
+type HealthStatus struct {
+	Status        string `json:"status"`         // "ok" または "degraded"
+	ModuleVersion string `json:"module_version"` // モジュールのバージョン
+	UptimeSeconds int64  `json:"uptime_seconds"` // モジュール起動からの経過秒数
+	ActiveMatches int    `json:"active_matches"` // 稼働中のQuoridorマッチ数（-1 は取得失敗）
+	StorageOK     bool   `json:"storage_ok"`     // データベースへの疎通確認結果
+	StorageError  string `json:"storage_error,omitempty"`
+	CasualOnly    bool   `json:"casual_only"` // 設定の誤りでレーティング戦を無効にしているかどうか
+}
+
+// HealthCheck - サーバーとゲームモジュールの稼働状況を返すRPC
+// 依存先の一部が失敗してもエラーにはせず、status を "degraded" にして返す
+func HealthCheck(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	status := &HealthStatus{
+		Status:        "ok",
+		ModuleVersion: ModuleVersion,
+		UptimeSeconds: int64(time.Since(moduleStartedAt).Seconds()),
+		ActiveMatches: -1,
+		StorageOK:     true,
+		CasualOnly:    casualOnly,
+	}
+	if casualOnly {
+		status.Status = "degraded"
+	}
+
+	// 稼働中の権威マッチ数を取得
+	matches, err := nk.MatchList(ctx, healthCheckMatchLimit, true, "", nil, nil, "")
+	if err != nil {
+		logger.Warn("healthcheck: failed to list matches: %v", err)
+		status.Status = "degraded"
+	} else {
+		status.ActiveMatches = len(matches)
+	}
+
+	// ストレージ（データベース）の疎通確認
+	pingCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if err := db.PingContext(pingCtx); err != nil {
+		logger.Warn("healthcheck: storage ping failed: %v", err)
+		status.Status = "degraded"
+		status.StorageOK = false
+		status.StorageError = err.Error()
+	}
+
+	response, err := json.Marshal(status)
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
@@ -0,0 +1,146 @@
+// Quoridor Chess ヒント
+// カジュアル戦の対局者が自分の手番で、現在の局面で最善と思われる移動か壁をサーバーに尋ねられるようにする
+// 探索はボットの上級と同じ方法で時間を区切って行い、1局で使える回数と間隔を制限する
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	maxHintsPerGame = 5                      // 1局でプレイヤーが使えるヒントの回数
+	hintCooldown    = 15 * time.Second       // 次のヒントを使えるまでの間隔
+	hintSearchTime  = 200 * time.Millisecond // 1回のヒントで探索に使う時間の上限
+)
+
+// GetHintRequest - get_hint RPCのリクエスト
+type GetHintRequest struct {
+	MatchID string `json:"match_id"`
+}
+
+// HintResponse - get_hint RPCのレスポンス
+type HintResponse struct {
+	Kind           string    `json:"kind"`           // "move" または "wall"
+	Move           *Position `json:"move,omitempty"` // 勧める移動先（kind が "move" の場合）
+	Wall           *Wall     `json:"wall,omitempty"` // 勧める壁（kind が "wall" の場合）
+	Score          int       `json:"score"`          // 勧める操作の評価値（相手の最短手数 - 自分の最短手数）
+	HintsRemaining int       `json:"hints_remaining"`
+}
+
+// hintUsage - プレイヤーごとのヒントの使用状況
+type hintUsage struct {
+	count  int
+	lastAt time.Time
+}
+
+// hintError - ヒントのシグナルのエラー応答を作成（RPCのエラーコードを含める）
+func hintError(message string, code int) string {
+	response, _ := json.Marshal(map[string]interface{}{"success": false, "error": message, "code": code})
+	return string(response)
+}
+
+// handleGetHintSignal - 手番のプレイヤーに現在の局面のヒントを返す
+// レーティング対象の対局では拒否して監査ログに記録する
+func (m *QuoridorChessMatch) handleGetHintSignal(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, data json.RawMessage) string {
+	var req struct {
+		UserID string `json:"user_id"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return hintError("invalid get_hint payload", errCodeInvalidArgument)
+	}
+	player := m.gameState.Players[req.UserID]
+	if player == nil || m.isBot(req.UserID) {
+		return hintError("not a player in this match", errCodePermissionDenied)
+	}
+	if m.gameState.Ranked {
+		writeAuditEvent(ctx, logger, nk, &AuditEvent{
+			Type:    "ranked_assistance_attempt",
+			MatchID: m.matchID,
+			UserID:  req.UserID,
+			Details: map[string]interface{}{
+				"message_type": "get_hint",
+			},
+		})
+		return hintError("not allowed in ranked games", errCodePermissionDenied)
+	}
+	if !m.gameState.GameStarted || m.gameState.CurrentTurn != req.UserID {
+		return hintError("hints are only available on your turn", errCodeFailedPrecondition)
+	}
+
+	now := time.Now()
+	usage := m.hints[req.UserID]
+	if usage == nil {
+		usage = &hintUsage{}
+		m.hints[req.UserID] = usage
+	}
+	if usage.count >= maxHintsPerGame {
+		return hintError("no hints remaining in this game", errCodeResourceExhausted)
+	}
+	if now.Sub(usage.lastAt) < hintCooldown {
+		return hintError("hint requested too soon", errCodeResourceExhausted)
+	}
+
+	action := m.searchAction(player, botDifficulties[BotDifficultyHard], now.Add(hintSearchTime))
+	if action == nil {
+		return hintError("no legal action in this position", errCodeFailedPrecondition)
+	}
+	usage.count++
+	usage.lastAt = now
+
+	hint := &HintResponse{Kind: ActionKindMove, Move: action.move, Wall: action.wall, Score: action.score, HintsRemaining: maxHintsPerGame - usage.count}
+	if action.wall != nil {
+		hint.Kind = ActionKindWall
+	}
+	m.recordEvent("hint", EventSourcePlayer, req.UserID, map[string]interface{}{"kind": hint.Kind})
+
+	response, _ := json.Marshal(map[string]interface{}{"success": true, "hint": hint})
+	return string(response)
+}
+
+// GetHint - 対局中のマッチで自分の手番の局面のヒントを返すRPC（カジュアル戦のみ）
+func GetHint(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return "", err
+	}
+	req := &GetHintRequest{}
+	if err := json.Unmarshal([]byte(payload), req); err != nil || req.MatchID == "" || len(req.MatchID) > maxMatchIDLength {
+		return "", errInvalidPayload
+	}
+
+	signal, err := json.Marshal(map[string]interface{}{
+		"type": "get_hint",
+		"data": map[string]string{"user_id": userID},
+	})
+	if err != nil {
+		return "", err
+	}
+	result, err := nk.MatchSignal(ctx, req.MatchID, string(signal))
+	if err != nil {
+		return "", runtime.NewError("match not found", errCodeNotFound)
+	}
+
+	var response struct {
+		Success bool          `json:"success"`
+		Error   string        `json:"error"`
+		Code    int           `json:"code"`
+		Hint    *HintResponse `json:"hint"`
+	}
+	if err := json.Unmarshal([]byte(result), &response); err != nil {
+		return "", runtime.NewError("invalid response from match", errCodeInternal)
+	}
+	if !response.Success {
+		return "", runtime.NewError(response.Error, response.Code)
+	}
+
+	out, err := json.Marshal(response.Hint)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
@@ -0,0 +1,184 @@
+// Quoridor Chess 履歴のメモリ上限
+// 長期戦やチャットの多い対局でもマッチハンドラーのメモリが増え続けないよう、
+// イベントログ・チャット・棋譜が上限を超えたら古い分をストレージに書き出してメモリから外す
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const historyChunkCollection = "match_history_chunks" // 書き出した履歴のストレージコレクション（システムが所有）
+
+// 書き出す履歴の種類
+const (
+	HistoryKindEvents   = "events"   // イベントログ
+	HistoryKindChat     = "chat"     // チャット
+	HistoryKindNotation = "notation" // 棋譜
+	HistoryKindMoves    = "moves"    // 再生用の手順（棋譜と同じ手数ずつ書き出す）
+)
+
+// HistoryChunk - ストレージに書き出した履歴のひとまとまり（古い順に 0 から番号を振る）
+type HistoryChunk struct {
+	MatchID string      `json:"match_id"`
+	Kind    string      `json:"kind"`  // 履歴の種類
+	Index   int         `json:"index"` // 何番目のまとまりか
+	Items   interface{} `json:"items"` // 書き出した項目（古い順）
+}
+
+// historyLimits - マッチのメモリに保持する履歴の上限
+type historyLimits struct {
+	Events   int
+	Chat     int
+	Notation int
+}
+
+// loadHistoryLimits - デプロイ設定から履歴の上限を読み込む
+func loadHistoryLimits(ctx context.Context) historyLimits {
+	return historyLimits{
+		Events:   historyLimit(ctx, EnvMatchMaxEvents, defaultMatchMaxEvents),
+		Chat:     historyLimit(ctx, EnvMatchMaxChat, defaultMatchMaxChat),
+		Notation: historyLimit(ctx, EnvMatchMaxNotation, defaultMatchMaxNotation),
+	}
+}
+
+// historyChunkKey - 書き出した履歴のストレージキー
+func historyChunkKey(matchID, kind string, index int) string {
+	return fmt.Sprintf("%s:%s:%d", matchID, kind, index)
+}
+
+// spillCount - 上限を超えた場合に書き出す件数を返す（毎ティック書き出さないよう半分まで減らす）
+func spillCount(length, limit int) int {
+	if length <= limit {
+		return 0
+	}
+	return length - limit/2
+}
+
+// writeHistoryChunk - 履歴のまとまりをストレージに書き出す
+// 匿名モードでは記録を残さないため書き出さずに捨てる
+func (m *QuoridorChessMatch) writeHistoryChunk(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, kind string, items interface{}) {
+	index := m.historyChunks[kind]
+	m.historyChunks[kind] = index + 1
+	if m.anonymous {
+		return
+	}
+
+	value, err := json.Marshal(&HistoryChunk{MatchID: m.matchID, Kind: kind, Index: index, Items: items})
+	if err != nil {
+		logger.Error("Failed to encode %s history of match %s: %v", kind, m.matchID, err)
+		return
+	}
+	if _, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      historyChunkCollection,
+		Key:             historyChunkKey(m.matchID, kind, index),
+		Value:           string(value),
+		PermissionRead:  2,
+		PermissionWrite: 0,
+	}}); err != nil {
+		logger.Error("Failed to store %s history of match %s: %v", kind, m.matchID, err)
+	}
+}
+
+// spillHistory - 上限を超えたイベントログ・チャット・棋譜の古い分をストレージに書き出す
+// 書き出しに失敗してもメモリの上限を優先して古い分は外す
+func (m *QuoridorChessMatch) spillHistory(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule) {
+	if n := spillCount(len(m.events), m.historyLimits.Events); n > 0 {
+		m.writeHistoryChunk(ctx, logger, nk, HistoryKindEvents, m.events[:n])
+		m.events = append([]*MatchEvent(nil), m.events[n:]...)
+	}
+	if n := spillCount(len(m.chatHistory), m.historyLimits.Chat); n > 0 {
+		m.writeHistoryChunk(ctx, logger, nk, HistoryKindChat, m.chatHistory[:n])
+		m.chatHistory = append([]*ChatData(nil), m.chatHistory[n:]...)
+	}
+	if n := spillCount(len(m.gameState.Notation), m.historyLimits.Notation); n > 0 {
+		m.writeHistoryChunk(ctx, logger, nk, HistoryKindNotation, m.gameState.Notation[:n])
+		m.gameState.Notation = append([]string(nil), m.gameState.Notation[n:]...)
+		m.gameState.NotationOffset += n
+		// 手順は棋譜と1手ずつ対応するため、同じ手数だけ書き出して NotationOffset を共有する
+		if n > len(m.gameState.MoveLog) {
+			n = len(m.gameState.MoveLog)
+		}
+		m.writeHistoryChunk(ctx, logger, nk, HistoryKindMoves, m.gameState.MoveLog[:n])
+		m.gameState.MoveLog = append([]*MoveLogEntry(nil), m.gameState.MoveLog[n:]...)
+	}
+}
+
+// ply - 対局開始からの手数（書き出した棋譜を含む）
+func (m *QuoridorChessMatch) ply() int {
+	return m.gameState.NotationOffset + len(m.gameState.Notation)
+}
+
+// readHistoryChunks - 書き出した履歴のまとまりを古い順に読み込み、それぞれの項目を返す
+func (m *QuoridorChessMatch) readHistoryChunks(ctx context.Context, nk runtime.NakamaModule, kind string) ([]json.RawMessage, error) {
+	count := m.historyChunks[kind]
+	if count == 0 {
+		return nil, nil
+	}
+
+	reads := make([]*runtime.StorageRead, 0, count)
+	for i := 0; i < count; i++ {
+		reads = append(reads, &runtime.StorageRead{
+			Collection: historyChunkCollection,
+			Key:        historyChunkKey(m.matchID, kind, i),
+		})
+	}
+	objects, err := nk.StorageRead(ctx, reads)
+	if err != nil {
+		return nil, err
+	}
+	chunks := make([]json.RawMessage, count)
+	for _, object := range objects {
+		var chunk struct {
+			Index int             `json:"index"`
+			Items json.RawMessage `json:"items"`
+		}
+		if err := json.Unmarshal([]byte(object.GetValue()), &chunk); err != nil || chunk.Index < 0 || chunk.Index >= count {
+			continue
+		}
+		chunks[chunk.Index] = chunk.Items
+	}
+	for i, chunk := range chunks {
+		if chunk == nil {
+			return nil, fmt.Errorf("%s chunk %d of match %s is missing", kind, i, m.matchID)
+		}
+	}
+	return chunks, nil
+}
+
+// fullNotation - 書き出した分を含む対局全体の棋譜を返す
+func (m *QuoridorChessMatch) fullNotation(ctx context.Context, nk runtime.NakamaModule) ([]string, error) {
+	chunks, err := m.readHistoryChunks(ctx, nk, HistoryKindNotation)
+	if err != nil {
+		return nil, err
+	}
+	notation := make([]string, 0, m.ply())
+	for _, chunk := range chunks {
+		var items []string
+		if err := json.Unmarshal(chunk, &items); err != nil {
+			return nil, err
+		}
+		notation = append(notation, items...)
+	}
+	return append(notation, m.gameState.Notation...), nil
+}
+
+// fullMoveLog - 書き出した分を含む対局全体の手順を返す
+func (m *QuoridorChessMatch) fullMoveLog(ctx context.Context, nk runtime.NakamaModule) ([]*MoveLogEntry, error) {
+	chunks, err := m.readHistoryChunks(ctx, nk, HistoryKindMoves)
+	if err != nil {
+		return nil, err
+	}
+	moves := make([]*MoveLogEntry, 0, m.ply())
+	for _, chunk := range chunks {
+		var items []*MoveLogEntry
+		if err := json.Unmarshal(chunk, &items); err != nil {
+			return nil, err
+		}
+		moves = append(moves, items...)
+	}
+	return append(moves, m.gameState.MoveLog...), nil
+}
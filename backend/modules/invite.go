@@ -0,0 +1,206 @@
+// Quoridor Chess 招待コード
+// 参加待ちのマッチに短い招待コードとQRコード用のURLを発行し、同じ場所にいるプレイヤー同士がすぐに参加できるようにする
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	inviteCodeCollection = "invite_codes"   // 招待コードのストレージコレクション（システムが所有）
+	inviteCodeLength     = 6                // 招待コードの文字数
+	inviteCodeTTL        = 15 * time.Minute // 招待コードの有効期間
+	inviteCodeRetries    = 5                // コードが重複したときの再生成回数
+	// 読み間違えやすい文字（0/O、1/I/L）を除いた文字
+	inviteCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+	defaultInviteURLBase = "quoridor://join?code=" // QRコードに埋め込むURLの既定値
+)
+
+// InviteCode - 保存する招待コードの情報
+type InviteCode struct {
+	Code      string `json:"code"`       // 招待コード
+	MatchID   string `json:"match_id"`   // 参加先のマッチID
+	CreatedBy string `json:"created_by"` // 発行したユーザーID
+	ExpiresAt int64  `json:"expires_at"` // 有効期限（Unix時刻）
+}
+
+// InviteCodeResponse - 招待コード発行RPCのレスポンス
+type InviteCodeResponse struct {
+	Code      string `json:"code"`       // 招待コード
+	MatchID   string `json:"match_id"`   // 参加先のマッチID
+	ExpiresAt int64  `json:"expires_at"` // 有効期限（Unix時刻）
+	QRPayload string `json:"qr_payload"` // QRコードにする文字列
+}
+
+// CreateInviteCodeRequest - create_invite_code RPCのリクエスト
+type CreateInviteCodeRequest struct {
+	MatchID string `json:"match_id"`
+}
+
+// ResolveInviteCodeRequest - resolve_invite_code RPCのリクエスト
+type ResolveInviteCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// generateInviteCode - ランダムな招待コードを生成
+func generateInviteCode() (string, error) {
+	var sb strings.Builder
+	max := big.NewInt(int64(len(inviteCodeAlphabet)))
+	for i := 0; i < inviteCodeLength; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteByte(inviteCodeAlphabet[n.Int64()])
+	}
+	return sb.String(), nil
+}
+
+// normalizeInviteCode - 入力された招待コードを大文字に揃え、空白を取り除く
+func normalizeInviteCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// storeInviteCode - 未使用の招待コードを生成してマッチIDと紐付けて保存
+func storeInviteCode(ctx context.Context, nk runtime.NakamaModule, matchID, userID string) (*InviteCode, error) {
+	for attempt := 0; attempt < inviteCodeRetries; attempt++ {
+		code, err := generateInviteCode()
+		if err != nil {
+			return nil, err
+		}
+		invite := &InviteCode{
+			Code:      code,
+			MatchID:   matchID,
+			CreatedBy: userID,
+			ExpiresAt: time.Now().Add(inviteCodeTTL).Unix(),
+		}
+		value, err := json.Marshal(invite)
+		if err != nil {
+			return nil, err
+		}
+
+		// 期限切れのコードは上書きして再利用する
+		version := "*"
+		if existing, existingVersion, err := readInviteCode(ctx, nk, code); err == nil && existing != nil {
+			if existing.ExpiresAt > time.Now().Unix() {
+				continue
+			}
+			version = existingVersion
+		}
+
+		if _, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+			Collection:      inviteCodeCollection,
+			Key:             code,
+			Value:           string(value),
+			Version:         version,
+			PermissionRead:  0,
+			PermissionWrite: 0,
+		}}); err != nil {
+			continue // 同じコードが同時に発行された場合は作り直す
+		}
+		return invite, nil
+	}
+	return nil, runtime.NewError("failed to allocate invite code", errCodeResourceExhausted)
+}
+
+// readInviteCode - 招待コードを読み込む（存在しない場合は nil）
+func readInviteCode(ctx context.Context, nk runtime.NakamaModule, code string) (*InviteCode, string, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: inviteCodeCollection,
+		Key:        code,
+	}})
+	if err != nil || len(objects) == 0 {
+		return nil, "", err
+	}
+	invite := &InviteCode{}
+	if err := json.Unmarshal([]byte(objects[0].GetValue()), invite); err != nil {
+		return nil, "", err
+	}
+	return invite, objects[0].GetVersion(), nil
+}
+
+// isMatchJoinable - マッチが存在し、まだ参加者を受け付けているかどうかを返す
+func isMatchJoinable(ctx context.Context, nk runtime.NakamaModule, matchID string) bool {
+	match, err := nk.MatchGet(ctx, matchID)
+	if err != nil || match == nil {
+		return false
+	}
+	label := &MatchLabel{}
+	if err := json.Unmarshal([]byte(match.GetLabel().GetValue()), label); err != nil {
+		return false
+	}
+	return label.Open && int(match.GetSize()) < MaxPlayers
+}
+
+// CreateInviteCode - 参加待ちのマッチに招待コードを発行するRPC
+func CreateInviteCode(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return "", err
+	}
+	req := &CreateInviteCodeRequest{}
+	if err := json.Unmarshal([]byte(payload), req); err != nil || req.MatchID == "" || len(req.MatchID) > maxMatchIDLength {
+		return "", errInvalidPayload
+	}
+	if !isMatchJoinable(ctx, nk, req.MatchID) {
+		return "", runtime.NewError("match is not open for joining", errCodeFailedPrecondition)
+	}
+
+	invite, err := storeInviteCode(ctx, nk, req.MatchID, userID)
+	if err != nil {
+		if runtimeErr, ok := err.(*runtime.Error); ok {
+			return "", runtimeErr
+		}
+		logger.Error("create_invite_code: failed to store invite code: %v", err)
+		return "", runtime.NewError("failed to store invite code", errCodeInternal)
+	}
+
+	response, err := json.Marshal(&InviteCodeResponse{
+		Code:      invite.Code,
+		MatchID:   invite.MatchID,
+		ExpiresAt: invite.ExpiresAt,
+		QRPayload: envValue(ctx, EnvInviteURLBase, defaultInviteURLBase) + invite.Code,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
+
+// ResolveInviteCode - 招待コードから参加先のマッチIDを返すRPC
+func ResolveInviteCode(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	if _, err := contextUserID(ctx); err != nil {
+		return "", err
+	}
+	req := &ResolveInviteCodeRequest{}
+	if err := json.Unmarshal([]byte(payload), req); err != nil || req.Code == "" {
+		return "", errInvalidPayload
+	}
+
+	invite, _, err := readInviteCode(ctx, nk, normalizeInviteCode(req.Code))
+	if err != nil {
+		logger.Error("resolve_invite_code: failed to read invite code: %v", err)
+		return "", runtime.NewError("failed to read invite code", errCodeInternal)
+	}
+	if invite == nil || invite.ExpiresAt <= time.Now().Unix() {
+		return "", runtime.NewError("invite code not found or expired", errCodeNotFound)
+	}
+	if !isMatchJoinable(ctx, nk, invite.MatchID) {
+		return "", runtime.NewError("match is no longer open", errCodeFailedPrecondition)
+	}
+
+	response, err := json.Marshal(map[string]interface{}{"match_id": invite.MatchID})
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
@@ -0,0 +1,51 @@
+// Quoridor Chess キッズセーフモード
+// チャットのエモート制限と、ユーザー名の代わりに表示する別名の生成を担当
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// 送信可能なエモートの一覧
+var allowedEmotes = map[string]bool{
+	"hello":     true,
+	"good_luck": true,
+	"nice_move": true,
+	"oops":      true,
+	"thinking":  true,
+	"good_game": true,
+}
+
+// 別名の生成に使う単語
+var (
+	aliasAdjectives = []string{"Brave", "Calm", "Clever", "Happy", "Lucky", "Quick", "Sunny", "Swift"}
+	aliasAnimals    = []string{"Fox", "Owl", "Panda", "Rabbit", "Otter", "Koala", "Penguin", "Turtle"}
+)
+
+// isValidEmote - 定義済みのエモートかどうかを返す
+func isValidEmote(emote string) bool {
+	return allowedEmotes[emote]
+}
+
+// generateAlias - ユーザーIDから決定的に別名を生成（同じユーザーは常に同じ別名になる）
+func generateAlias(userID string) string {
+	h := fnv.New32a()
+	h.Write([]byte(userID))
+	sum := h.Sum32()
+	adjective := aliasAdjectives[sum%uint32(len(aliasAdjectives))]
+	animal := aliasAnimals[(sum/uint32(len(aliasAdjectives)))%uint32(len(aliasAnimals))]
+	return fmt.Sprintf("%s%s%02d", adjective, animal, sum%100)
+}
+
+// displayName - マッチ内で他のプレイヤーに見せる名前を返す
+// キッズセーフモードではユーザー名を隠して別名を使用し、匿名モードではマッチごとに変わる別名を使用する
+func (m *QuoridorChessMatch) displayName(userID, username string) string {
+	if m.anonymous {
+		return m.anonymousAlias(userID)
+	}
+	if m.kidSafe {
+		return generateAlias(userID)
+	}
+	return username
+}
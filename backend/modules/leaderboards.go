@@ -0,0 +1,161 @@
+// Quoridor Chess リーダーボード
+// レーティング・今週の勝利数・連勝数のリーダーボードを作成し、対局終了時に記録する
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// リーダーボードID
+const (
+	LeaderboardRating     = "quoridor_rating"      // レーティング（表示に使うレーティング方式の値）
+	LeaderboardWeeklyWins = "quoridor_weekly_wins" // 今週の勝利数（毎週月曜 0:00 UTC にリセット）
+	LeaderboardWinStreak  = "quoridor_win_streak"  // 連勝数（これまでの最高記録）
+)
+
+const (
+	defaultLeaderboardAroundLimit = 10 // 周辺の順位の既定の取得件数
+	maxLeaderboardAroundLimit     = 50 // 周辺の順位の最大の取得件数
+)
+
+// leaderboardDefinitions - 起動時に作成するリーダーボード
+var leaderboardDefinitions = []struct {
+	id       string
+	operator string
+	reset    string
+}{
+	{LeaderboardRating, "set", ""},
+	{LeaderboardWeeklyWins, "incr", "0 0 * * 1"},
+	{LeaderboardWinStreak, "best", ""},
+}
+
+// リクエストで指定するリーダーボード名
+var leaderboardNames = map[string]string{
+	"rating":      LeaderboardRating,
+	"weekly_wins": LeaderboardWeeklyWins,
+	"win_streak":  LeaderboardWinStreak,
+}
+
+// GetLeaderboardAroundMeRequest - get_leaderboard_around_me RPCのリクエスト
+type GetLeaderboardAroundMeRequest struct {
+	Leaderboard string `json:"leaderboard"` // "rating" / "weekly_wins" / "win_streak"
+	Limit       int    `json:"limit"`       // 取得件数（自分を含む）
+}
+
+// LeaderboardEntry - リーダーボードの1行
+type LeaderboardEntry struct {
+	Rank     int64  `json:"rank"`
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	Score    int64  `json:"score"`
+	Own      bool   `json:"own"`             // 自分の記録かどうか
+	Flair    *Flair `json:"flair,omitempty"` // 名前の横に表示する称号
+}
+
+// createLeaderboards - リーダーボードを作成する（作成済みの場合は何もしない）
+func createLeaderboards(ctx context.Context, nk runtime.NakamaModule) error {
+	for _, definition := range leaderboardDefinitions {
+		if err := nk.LeaderboardCreate(ctx, definition.id, true, "desc", definition.operator, definition.reset, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeLeaderboards - 対局結果をリーダーボードに記録する
+// 勝者は今週の勝利数と連勝数、レーティング対象の対局では両者のレーティングを記録する
+func (m *QuoridorChessMatch) writeLeaderboards(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule) {
+	// キッズセーフモードのプレイヤーは公開のランキングに載せない
+	if m.playtest || m.vsBot() || m.kidSafe {
+		return
+	}
+	for id, player := range m.gameState.Players {
+		if id == m.gameState.Winner {
+			if _, err := nk.LeaderboardRecordWrite(ctx, LeaderboardWeeklyWins, id, player.Username, 1, 0, nil, nil); err != nil {
+				logger.Error("Failed to write weekly wins of %s: %v", id, err)
+			}
+			if stats, _, err := readPlayerStats(ctx, nk, id); err != nil {
+				logger.Error("Failed to read stats of %s for leaderboards: %v", id, err)
+			} else if _, err := nk.LeaderboardRecordWrite(ctx, LeaderboardWinStreak, id, player.Username, int64(stats.WinStreak), 0, nil, nil); err != nil {
+				logger.Error("Failed to write win streak of %s: %v", id, err)
+			}
+		}
+
+		// 審査フラグで変動させなかったレーティングは記録しない
+		change := m.gameState.Ratings[id]
+		if change == nil || change.Frozen {
+			continue
+		}
+		score := int64(change.After)
+		if m.ratingSystem == RatingSystemGlicko2 {
+			score = int64(change.Glicko.After + 0.5)
+		}
+		if _, err := nk.LeaderboardRecordWrite(ctx, LeaderboardRating, id, player.Username, score, 0, nil, nil); err != nil {
+			logger.Error("Failed to write rating of %s: %v", id, err)
+		}
+	}
+}
+
+// GetLeaderboardAroundMe - 自分の順位の前後を返すRPC
+func GetLeaderboardAroundMe(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return "", err
+	}
+	req := &GetLeaderboardAroundMeRequest{}
+	if err := json.Unmarshal([]byte(payload), req); err != nil {
+		return "", errInvalidPayload
+	}
+	leaderboardID, ok := leaderboardNames[req.Leaderboard]
+	if !ok {
+		return "", runtime.NewError("unknown leaderboard", errCodeInvalidArgument)
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultLeaderboardAroundLimit
+	}
+	if limit > maxLeaderboardAroundLimit {
+		limit = maxLeaderboardAroundLimit
+	}
+
+	records, err := nk.LeaderboardRecordsHaystack(ctx, leaderboardID, userID, limit, "", 0)
+	if err != nil {
+		logger.Error("get_leaderboard_around_me: failed to list records: %v", err)
+		return "", runtime.NewError("failed to list leaderboard records", errCodeInternal)
+	}
+
+	ownerIDs := make([]string, 0, len(records.GetRecords()))
+	for _, record := range records.GetRecords() {
+		ownerIDs = append(ownerIDs, record.GetOwnerId())
+	}
+	// フレアが読めなくても順位は返す
+	flairs, err := readFlairs(ctx, nk, ownerIDs)
+	if err != nil {
+		logger.Warn("get_leaderboard_around_me: failed to read flairs: %v", err)
+	}
+
+	entries := make([]*LeaderboardEntry, 0, len(records.GetRecords()))
+	for _, record := range records.GetRecords() {
+		entries = append(entries, &LeaderboardEntry{
+			Rank:     record.GetRank(),
+			UserID:   record.GetOwnerId(),
+			Username: record.GetUsername().GetValue(),
+			Score:    record.GetScore(),
+			Own:      record.GetOwnerId() == userID,
+			Flair:    flairs[record.GetOwnerId()],
+		})
+	}
+
+	response, err := json.Marshal(map[string]interface{}{
+		"leaderboard": req.Leaderboard,
+		"records":     entries,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
@@ -0,0 +1,125 @@
+// Quoridor Chess 手番待ちRPC
+// リアルタイムソケットを使わないHTTPクライアントやボットが、状態の変化をロングポーリングで待てるようにする
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	waitForTurnDefaultTimeout = 20 * time.Second       // 待機時間の既定値
+	waitForTurnMaxTimeout     = 25 * time.Second       // 待機時間の上限（HTTPのタイムアウトより短くする）
+	waitForTurnPollInterval   = 500 * time.Millisecond // マッチに状態を問い合わせる間隔
+)
+
+// WaitForTurnRequest - wait_for_turn RPCのリクエスト
+type WaitForTurnRequest struct {
+	MatchID        string `json:"match_id"`
+	LastSeq        int64  `json:"last_seq"`        // クライアントが最後に受け取ったイベントの通し番号
+	TimeoutSeconds int    `json:"timeout_seconds"` // 最大待機秒数（省略時は既定値）
+}
+
+// WaitForTurnResponse - wait_for_turn RPCのレスポンス
+type WaitForTurnResponse struct {
+	Seq       int64      `json:"seq"`        // 最新のイベントの通し番号
+	Changed   bool       `json:"changed"`    // last_seq 以降に新しいイベントがあったかどうか
+	YourTurn  bool       `json:"your_turn"`  // 呼び出し元の手番かどうか
+	GameState *GameState `json:"game_state"` // 最新のゲーム状態
+}
+
+// handleGetStateSignal - get_state シグナルに対して最新のゲーム状態を返す（対局者のみ）
+func (m *QuoridorChessMatch) handleGetStateSignal(data json.RawMessage) string {
+	var req struct {
+		UserID string `json:"user_id"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return signalError("invalid get_state payload")
+	}
+	if m.gameState.Players[req.UserID] == nil {
+		return signalError("not a player in this match")
+	}
+
+	response, _ := json.Marshal(map[string]interface{}{"success": true, "game_state": m.gameState})
+	return string(response)
+}
+
+// fetchMatchState - シグナルでマッチの最新状態を取得
+func fetchMatchState(ctx context.Context, nk runtime.NakamaModule, matchID, userID string) (*GameState, error) {
+	signal, err := json.Marshal(map[string]interface{}{
+		"type": "get_state",
+		"data": map[string]string{"user_id": userID},
+	})
+	if err != nil {
+		return nil, err
+	}
+	result, err := nk.MatchSignal(ctx, matchID, string(signal))
+	if err != nil {
+		return nil, runtime.NewError("match not found", errCodeNotFound)
+	}
+
+	var response struct {
+		Success   bool       `json:"success"`
+		Error     string     `json:"error"`
+		GameState *GameState `json:"game_state"`
+	}
+	if err := json.Unmarshal([]byte(result), &response); err != nil || (response.Success && response.GameState == nil) {
+		return nil, runtime.NewError("invalid response from match", errCodeInternal)
+	}
+	if !response.Success {
+		return nil, runtime.NewError(response.Error, errCodePermissionDenied)
+	}
+	return response.GameState, nil
+}
+
+// WaitForTurn - last_seq より新しいイベントが発生するか、タイムアウトするまで待ってから状態を返すRPC
+func WaitForTurn(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return "", err
+	}
+	req := &WaitForTurnRequest{}
+	if err := json.Unmarshal([]byte(payload), req); err != nil || req.MatchID == "" || len(req.MatchID) > maxMatchIDLength {
+		return "", errInvalidPayload
+	}
+	timeout := waitForTurnDefaultTimeout
+	if req.TimeoutSeconds > 0 {
+		timeout = time.Duration(req.TimeoutSeconds) * time.Second
+	}
+	if timeout > waitForTurnMaxTimeout {
+		timeout = waitForTurnMaxTimeout
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		state, err := fetchMatchState(ctx, nk, req.MatchID, userID)
+		if err != nil {
+			return "", err
+		}
+
+		// 新しいイベントがあるか、待機時間を使い切ったら返す
+		changed := state.Seq > req.LastSeq
+		if changed || !time.Now().Before(deadline) {
+			response, err := json.Marshal(&WaitForTurnResponse{
+				Seq:       state.Seq,
+				Changed:   changed,
+				YourTurn:  state.GameStarted && state.CurrentTurn == userID,
+				GameState: state,
+			})
+			if err != nil {
+				return "", err
+			}
+			return string(response), nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", runtime.NewError("request cancelled", errCodeFailedPrecondition)
+		case <-time.After(waitForTurnPollInterval):
+		}
+	}
+}
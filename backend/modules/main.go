@@ -16,12 +16,34 @@ const (
 	MatchmakingTicket = "quoridor_chess" // マッチメイキングのチケット名
 	MinPlayers        = 2               // 最小プレイヤー数（2人対戦）
 	MaxPlayers        = 2               // 最大プレイヤー数（2人対戦）
+	BlunderThreshold  = 2               // トレーニングモードで警告する、最善の移動と比べた評価値の悪化幅（最短経路の手数）
+	ModuleVersion     = "0.2.0"         // このGoモジュールのバージョン
+	InitialWalls      = 10              // 各プレイヤーの壁の初期数
 )
 
+// モジュールの起動時刻（ヘルスチェックの稼働時間計算に使用）
+var moduleStartedAt = time.Now()
+
 // モジュール初期化関数 - Nakamaサーバー起動時に呼び出される
 // マッチハンドラーとRPCハンドラーを登録
 func InitModule(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, initializer runtime.Initializer) error {
 	logger.Info("Quoridor Chess module loaded!")
+	moduleStartedAt = time.Now()
+
+	// 設定を検証（必須の設定に誤りがあれば起動しない、任意の連携の誤りはカジュアル戦のみで起動）
+	if err := applyModuleConfig(ctx, logger); err != nil {
+		return err
+	}
+	// 登録したハンドラーをログに出力
+	initializer = &loggingInitializer{Initializer: initializer, logger: logger}
+
+	// 対局結果Webhookの再送ワーカーを起動（Webhook未設定、または縮退モードの場合は何もしない）
+	if webhookConfig := webhookConfigFromContext(ctx); webhookConfig.URL != "" && !casualOnly {
+		go runWebhookRetryWorker(webhookConfig, logger, nk)
+	}
+
+	// 停止したマッチの監視を起動
+	go runMatchWatchdog(logger, nk)
 
 	// マッチハンドラーの登録 - ゲームマッチの作成と管理
 	if err := initializer.RegisterMatch("quoridor_chess", func(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule) (runtime.Match, error) {
@@ -30,6 +52,29 @@ func InitModule(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runti
 		return err
 	}
 
+	// 終了した対局の再生マッチ
+	if err := initializer.RegisterMatch(ReplayMatchName, func(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule) (runtime.Match, error) {
+		return &ReplayMatch{}, nil
+	}); err != nil {
+		return err
+	}
+
+	// 組み込みのマッチメイカーで成立した組み合わせから権威マッチを作成
+	if err := initializer.RegisterMatchmakerMatched(OnMatchmakerMatched); err != nil {
+		return err
+	}
+
+	// リーダーボードの作成（レーティング、今週の勝利数、連勝数）
+	if err := createLeaderboards(ctx, nk); err != nil {
+		return err
+	}
+
+	// 注目の結果のお知らせ（配信先が設定されている場合のみ）とトーナメントの優勝の称号
+	newsPublisher = newNewsPublisher(ctx)
+	if err := initializer.RegisterTournamentEnd(onTournamentEnd); err != nil {
+		return err
+	}
+
 	// RPCハンドラーの登録 - クライアントから呼び出される機能
 	// マッチメイキング参加
 	if err := initializer.RegisterRpc("join_matchmaking", JoinMatchmaking); err != nil {
@@ -41,45 +86,338 @@ func InitModule(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runti
 		return err
 	}
 
+	// マッチメイキングのチケット状態確認
+	if err := initializer.RegisterRpc("matchmaking_status", MatchmakingStatus); err != nil {
+		return err
+	}
+
 	// チャット送信
 	if err := initializer.RegisterRpc("send_chat", SendChat); err != nil {
 		return err
 	}
 
+	// 参加可能なマッチの一覧（リージョン指定可）
+	if err := initializer.RegisterRpc("list_open_matches", ListOpenMatches); err != nil {
+		return err
+	}
+
+	// 対戦回避リストの管理
+	if err := initializer.RegisterRpc("add_avoid_player", AddAvoidPlayer); err != nil {
+		return err
+	}
+	if err := initializer.RegisterRpc("remove_avoid_player", RemoveAvoidPlayer); err != nil {
+		return err
+	}
+	if err := initializer.RegisterRpc("list_avoid_players", ListAvoidPlayers); err != nil {
+		return err
+	}
+
+	// プレイヤーのフォロー（対局開始の通知を受け取る）
+	if err := initializer.RegisterRpc("follow_player", FollowPlayer); err != nil {
+		return err
+	}
+	if err := initializer.RegisterRpc("unfollow_player", UnfollowPlayer); err != nil {
+		return err
+	}
+	if err := initializer.RegisterRpc("list_following", ListFollowing); err != nil {
+		return err
+	}
+
+	// 対局結果証明書の取得
+	if err := initializer.RegisterRpc("get_result_certificate", GetResultCertificate); err != nil {
+		return err
+	}
+
+	// プレイヤー統計（対局ペース）の取得
+	if err := initializer.RegisterRpc("get_player_stats", GetPlayerStats); err != nil {
+		return err
+	}
+
+	// 対局のブックマーク
+	if err := initializer.RegisterRpc("bookmark_game", BookmarkGame); err != nil {
+		return err
+	}
+	if err := initializer.RegisterRpc("unbookmark_game", UnbookmarkGame); err != nil {
+		return err
+	}
+	if err := initializer.RegisterRpc("list_bookmarks", ListBookmarks); err != nil {
+		return err
+	}
+
+	// 通信プロトコルのスキーマ（クライアントのコード生成・契約テスト用）
+	if err := initializer.RegisterRpc("get_protocol_schema", GetProtocolSchema); err != nil {
+		return err
+	}
+
+	// 招待コード（同じ場所にいるプレイヤー同士の参加用）
+	if err := initializer.RegisterRpc("create_invite_code", CreateInviteCode); err != nil {
+		return err
+	}
+	if err := initializer.RegisterRpc("resolve_invite_code", ResolveInviteCode); err != nil {
+		return err
+	}
+
+	// 盤面の訂正（紛争解決用、サーバー間呼び出しのみ）
+	if err := initializer.RegisterRpc("admin_correct_position", AdminCorrectPosition); err != nil {
+		return err
+	}
+
+	// 手番待ち（ソケットを使わないクライアント向けのロングポーリング）
+	if err := initializer.RegisterRpc("wait_for_turn", WaitForTurn); err != nil {
+		return err
+	}
+
+	// 対局結果への異議申し立て（一覧と解決はサーバー間呼び出しのみ）
+	if err := initializer.RegisterRpc("open_dispute", OpenDispute); err != nil {
+		return err
+	}
+	if err := initializer.RegisterRpc("admin_list_disputes", AdminListDisputes); err != nil {
+		return err
+	}
+	if err := initializer.RegisterRpc("admin_resolve_dispute", AdminResolveDispute); err != nil {
+		return err
+	}
+
+	// ゲームプレイ設定（自動移動など、サーバーが適用する設定）
+	if err := initializer.RegisterRpc("get_gameplay_settings", GetGameplaySettings); err != nil {
+		return err
+	}
+	if err := initializer.RegisterRpc("update_gameplay_settings", UpdateGameplaySettings); err != nil {
+		return err
+	}
+
+	// チャット履歴（途中から観戦を始めた人向け）
+	if err := initializer.RegisterRpc("get_match_chat", GetMatchChat); err != nil {
+		return err
+	}
+
+	// 保護者による利用時間の制限
+	if err := initializer.RegisterRpc("set_play_time_limit", SetPlayTimeLimit); err != nil {
+		return err
+	}
+	if err := initializer.RegisterRpc("get_play_time_status", GetPlayTimeStatus); err != nil {
+		return err
+	}
+
+	// 機能フラグ（サーバー間呼び出しのみ）
+	if err := initializer.RegisterRpc("admin_set_feature_flag", AdminSetFeatureFlag); err != nil {
+		return err
+	}
+	if err := initializer.RegisterRpc("admin_list_feature_flags", AdminListFeatureFlags); err != nil {
+		return err
+	}
+
+	// 中断した対局の再開
+	if err := initializer.RegisterRpc("resume_adjourned", ResumeAdjourned); err != nil {
+		return err
+	}
+
+	// 匿名クイックプレイ
+	if err := initializer.RegisterRpc("anonymous_quick_play", AnonymousQuickPlay); err != nil {
+		return err
+	}
+
+	// プレイテスト用コマンド（管理用、サーバー間呼び出しのみ）
+	if err := initializer.RegisterRpc("admin_playtest_command", AdminPlaytestCommand); err != nil {
+		return err
+	}
+
+	// 接続品質のリージョン・プラットフォーム別集計（管理用、サーバー間呼び出しのみ）
+	if err := initializer.RegisterRpc("admin_connectivity_report", AdminConnectivityReport); err != nil {
+		return err
+	}
+
+	// プライベートマッチ（参加コードで招待）
+	if err := initializer.RegisterRpc("create_private_match", CreatePrivateMatch); err != nil {
+		return err
+	}
+	if err := initializer.RegisterRpc("join_by_code", JoinByCode); err != nil {
+		return err
+	}
+
+	// 参加待ちのマッチを探すか作成してマッチIDを返す
+	if err := initializer.RegisterRpc("quick_match", QuickMatch); err != nil {
+		return err
+	}
+
+	// レーティングの取得（プロフィール画面用）
+	if err := initializer.RegisterRpc("get_rating", GetRating); err != nil {
+		return err
+	}
+
+	// リーダーボードの自分の順位の前後
+	if err := initializer.RegisterRpc("get_leaderboard_around_me", GetLeaderboardAroundMe); err != nil {
+		return err
+	}
+
+	// 自分の過去の対局結果の一覧
+	if err := initializer.RegisterRpc("get_match_history", GetMatchHistory); err != nil {
+		return err
+	}
+
+	// 終了した対局の再生用の手順
+	if err := initializer.RegisterRpc("get_replay", GetReplay); err != nil {
+		return err
+	}
+
+	// 終了した対局の再生マッチの作成
+	if err := initializer.RegisterRpc("watch_replay", WatchReplay); err != nil {
+		return err
+	}
+
+	// ボットと対戦する練習用のマッチの作成
+	if err := initializer.RegisterRpc("play_vs_bot", PlayVsBot); err != nil {
+		return err
+	}
+
+	// 注目の対局の中継（開始・停止はサーバー間呼び出しのみ）
+	if err := initializer.RegisterRpc("admin_set_broadcast", AdminSetBroadcast); err != nil {
+		return err
+	}
+	if err := initializer.RegisterRpc("watch_broadcast", WatchBroadcast); err != nil {
+		return err
+	}
+	if err := initializer.RegisterRpc("leave_broadcast", LeaveBroadcast); err != nil {
+		return err
+	}
+
+	// カジュアル戦の手番のヒント
+	if err := initializer.RegisterRpc("get_hint", GetHint); err != nil {
+		return err
+	}
+
+	// 称号の一覧とフレアの選択
+	if err := initializer.RegisterRpc("get_titles", GetTitles); err != nil {
+		return err
+	}
+	if err := initializer.RegisterRpc("set_flair", SetFlair); err != nil {
+		return err
+	}
+
+	// ヘルスチェック（ロードバランサーやデプロイ確認用）
+	if err := initializer.RegisterRpc("healthcheck", HealthCheck); err != nil {
+		return err
+	}
+
+	logger.Info("Quoridor Chess module %s initialized (protocol %d, casual only: %v)", ModuleVersion, ProtocolVersion, casualOnly)
 	return nil
 }
 
 // QuoridorChessMatch - Matchインターフェースを実装するゲームマッチ構造体
 // リアルタイムゲームセッションの状態とロジックを管理
 type QuoridorChessMatch struct {
-	presences  map[string]runtime.Presence // 接続中のプレイヤー一覧
-	gameState  *GameState                  // ゲーム状態（盤面、プレイヤー情報など）
-	tickRate   int                         // サーバーの更新頻度（Hz）
-	label      *MatchLabel                 // マッチのメタデータ
+	presences         map[string]runtime.Presence  // 接続中のプレイヤー一覧
+	gameState         *GameState                   // ゲーム状態（盤面、プレイヤー情報など）
+	tickRate          int                          // サーバーの更新頻度（Hz）
+	label             *MatchLabel                  // マッチのメタデータ
+	kidSafe           bool                         // キッズセーフモード（エモートのみのチャット、別名表示）
+	matchID           string                       // このマッチのID
+	turnStartedAt     time.Time                    // 現在の手番が始まった時刻
+	moveTimings       map[string]*moveTiming       // プレイヤーごとの思考時間の集計
+	lagging           map[string]bool              // 送信に失敗して遅延中と判断したプレイヤー
+	lastActivity      map[string]time.Time         // プレイヤーごとの最後の入力時刻
+	afkWarned         bool                         // 現在の手番で放置警告を送ったかどうか
+	regressiveMoves   map[string]int               // プレイヤーごとのゴールから遠ざかった移動の回数
+	settings          map[string]*GameplaySettings // プレイヤーごとのゲームプレイ設定
+	chatHistory       []*ChatData                  // 送信したチャットの履歴（古い順、上限あり）
+	chatSeq           int64                        // チャットの通し番号
+	ruleset           *Ruleset                     // 適用中のルール（バリアントと勝利条件）
+	gameStartedAt     time.Time                    // 対局が始まった時刻
+	playTimeBudgets   map[string]*playTimeBudget   // 利用時間の上限が設定されたプレイヤーの残り時間
+	events            []*MatchEvent                // プレイヤーの操作とサーバーの判断のログ（発生順）
+	tick              int64                        // 処理中のティック（イベントログ用）
+	flags             map[string]bool              // このマッチに適用する機能フラグ（作成時に決定）
+	adjournOffers     map[string]bool              // 対局の中断を提案したプレイヤー
+	adjourned         bool                         // 対局を中断して局面を保存したかどうか
+	resumedFrom       string                       // 中断した対局を再開したマッチの場合は元の対局ID
+	anonymous         bool                         // 匿名モード（使い捨ての別名、エモートのみのチャット、記録を残さない）
+	tutorialTips      map[string]map[string]bool   // ヒントの対象プレイヤーごとの送信済みヒント
+	bufferedActions   map[string]*bufferedAction   // 相手のターン中に送られた次の操作（プレイヤーごとに1つ）
+	clockUpdatedAt    time.Time                    // 手番のプレイヤーの持ち時間を最後に減らした時刻
+	turnChangedAt     time.Time                    // 手番が相手に移った時刻（1ターンの制限時間用）
+	reconnectGrace    time.Duration                // 対局中に切断したプレイヤーの再接続を待つ時間
+	disconnectedAt    map[string]time.Time         // 切断して再接続を待っているプレイヤーの切断時刻
+	matchmadePlayers  []string                     // マッチメイキングで組み合わせた対局者（それ以外のマッチでは空）
+	playtest          bool                         // プレイテスト用のマッチ（局面を書き換えるコマンドを受け付ける、カジュアル戦のみ）
+	platforms         map[string]string            // 対局者ごとのプラットフォーム（参加時のメタデータから取得）
+	disconnects       []*DisconnectRecord          // 対局中の切断の記録（対局結果に保存する）
+	privateOwner      string                       // プライベートマッチの作成者（公開マッチでは空）
+	eventSeq          int                          // イベントログの通し番号
+	historyLimits     historyLimits                // イベントログ・チャット・棋譜をメモリに保持する上限
+	historyChunks     map[string]int               // ストレージに書き出した履歴のまとまりの数（履歴の種類ごと）
+	ratingSystem      string                       // クライアントに表示させるレーティング方式（"elo" / "glicko2"）
+	staleBest         map[string]int               // 停滞が始まってからの各プレイヤーのゴールまでの最短手数の最小値
+	staleHalfTurns    int                          // 壁の配置も最短手数の更新もないまま手番が移った回数
+	staleDrawOffered  bool                         // 停滞による引き分けを提案中かどうか
+	drawAccepts       map[string]bool              // 停滞による引き分けの提案に合意したプレイヤー
+	stormPausedAt     time.Time                    // 切断の嵐によりマッチを一時停止した時刻（停止していなければゼロ値）
+	stormShiftedAt    time.Time                    // 一時停止中に最後に時刻の起点をずらした時刻
+	startSeats        []*SeatAssignment            // 対局開始時の席の割り当て（再生用に保存する）
+	bandwidth         map[string]*bandwidthUsage   // プレゼンスごとの送信量
+	lowBandwidth      map[string]bool              // 全体の状態の代わりに差分だけを送る低帯域モードのプレイヤー
+	spectators        map[string]runtime.Presence  // 観戦者（ユーザーID -> Presence、対局者とは別に管理）
+	spectatorRequests map[string]bool              // 観戦者として参加を許可し、参加を待っているユーザー
+	spectatorCap      int                          // 観戦者の上限
+	botOwner          string                       // ボット対戦の作成者（ボット対戦でなければ空）
+	tiers             map[string]*PlayerTier       // 対局者の今シーズンのティア（対局開始の通知に含める）
+	botDifficulty     string                       // ボットの難易度（"easy" / "medium" / "hard"）
+	relaying          bool                         // 中継ストリームに中継しているかどうか
+	relayDelay        time.Duration                // 中継ストリームに流すまで遅らせる時間
+	relayQueue        []*relayEntry                // 遅延が明けるのを待っている中継（古い順）
+	relaySnapshot     []byte                       // 直近に中継したゲーム状態全体（途中から見始めた人向け）
+	hints             map[string]*hintUsage        // プレイヤーごとのヒントの使用状況
+	flairs            map[string]*Flair            // 対局者の選んでいるフレア（対局開始の通知に含める）
+	wallsPlaced       map[string]int               // この対局でプレイヤーが置いた壁の枚数（称号の判定用）
 }
 
 // MatchLabel - マッチのメタデータ構造体
 type MatchLabel struct {
-	Open bool `json:"open"` // マッチが新規参加可能かどうか
+	Open             bool   `json:"open"`               // マッチが新規参加可能かどうか
+	Region           string `json:"region"`             // マッチをホストしているリージョン（ルーティングのヒント）
+	Node             string `json:"node"`               // マッチをホストしているNakamaノード名
+	Pace             string `json:"pace"`               // 作成者の対局ペース（"fast" / "normal" / "slow" / "unknown"）
+	Variant          string `json:"variant"`            // バリアント名（"standard" / "flag" / "decay" / "push"）
+	Anonymous        bool   `json:"anonymous"`          // 匿名モードのマッチかどうか
+	Private          bool   `json:"private"`            // 参加コードが必要なプライベートマッチかどうか
+	Mode             string `json:"mode"`               // 対局の種別（"casual" / "ranked" / "tournament"）
+	Speed            string `json:"speed"`              // 持ち時間の区分（"untimed" / "bullet" / "blitz" / "rapid" / "classical"）
+	ClockInitialMs   int64  `json:"clock_initial_ms"`   // 持ち時間（ミリ秒、0 は持ち時間なし）
+	ClockIncrementMs int64  `json:"clock_increment_ms"` // 1手ごとの加算時間（ミリ秒）
+	BoardSize        int    `json:"board_size"`         // 盤の大きさ
+	Placement        bool   `json:"placement"`          // 配置戦中のプレイヤーが参加しているレーティング戦かどうか
+	Broadcast        bool   `json:"broadcast"`          // 中継ストリームに中継しているかどうか
 }
 
 // GameState - ゲーム全体の状態を管理する構造体
 type GameState struct {
-	Players      map[string]*Player `json:"players"`      // プレイヤー情報（ユーザーID -> Player）
-	Board        *Board            `json:"board"`        // ゲームボード（壁の配置など）
-	CurrentTurn  string            `json:"current_turn"`  // 現在のターンのプレイヤーID
-	Winner       string            `json:"winner"`        // 勝者のプレイヤーID（ゲーム終了時）
-	GameStarted  bool              `json:"game_started"`  // ゲームが開始されているかどうか
-	CreatedAt    int64             `json:"created_at"`    // マッチ作成時刻（Unix時刻）
+	Players        map[string]*Player       `json:"players"`                 // プレイヤー情報（ユーザーID -> Player）
+	Board          *Board                   `json:"board"`                   // ゲームボード（壁の配置など）
+	CurrentTurn    string                   `json:"current_turn"`            // 現在のターンのプレイヤーID
+	Winner         string                   `json:"winner"`                  // 勝者のプレイヤーID（ゲーム終了時）
+	GameStarted    bool                     `json:"game_started"`            // ゲームが開始されているかどうか
+	Ranked         bool                     `json:"ranked"`                  // レーティング対象の対局かどうか（false の場合はカジュアル戦）
+	CreatedAt      int64                    `json:"created_at"`              // マッチ作成時刻（Unix時刻）
+	Notation       []string                 `json:"notation"`                // 棋譜（例: "e8", 手番順）
+	NotationOffset int                      `json:"notation_offset"`         // メモリから外した棋譜の手数（Notation の先頭が何手目の次か）
+	LastAction     *ActionHint              `json:"last_action,omitempty"`   // 直前に受理した操作（クライアントのアニメーション用）
+	ResultReason   string                   `json:"result_reason"`           // 決着の理由（"goal" / "afk" / "disconnect" / "timeout" / "turn_timeout" / "resign" / "stale_draw"）
+	Seq            int64                    `json:"seq"`                     // 全員に送ったイベントの通し番号（wait_for_turn 用）
+	TournamentID   string                   `json:"tournament_id,omitempty"` // 結果を記録するトーナメントのID（トーナメント戦のみ）
+	TurnActions    []string                 `json:"turn_actions"`            // 現在のターンで行った操作の種類（2回行動バリアント用）
+	Ratings        map[string]*RatingChange `json:"ratings,omitempty"`       // 対局によるレーティングの変動（レーティング対象の対局の終了時のみ）
+	MoveLog        []*MoveLogEntry          `json:"move_log"`                // 再生用の手順（受理した移動と壁の配置、NotationOffset 以降の分）
 }
 
 // Player - プレイヤー情報を保持する構造体
 type Player struct {
-	ID       string    `json:"id"`       // プレイヤーのユーザーID
-	Username string    `json:"username"` // プレイヤーの表示名
-	Position *Position `json:"position"` // 現在のボード上の位置
-	Walls    int       `json:"walls"`    // 残り壁数（初期値10）
-	Color    string    `json:"color"`    // プレイヤーの色（"white" または "black"）
+	ID           string    `json:"id"`              // プレイヤーのユーザーID
+	Username     string    `json:"username"`        // プレイヤーの表示名
+	Position     *Position `json:"position"`        // 現在のボード上の位置
+	Walls        int       `json:"walls"`           // 残り壁数（初期値10）
+	Color        string    `json:"color"`           // プレイヤーの色（"white" または "black"）
+	TrainingMode bool      `json:"training_mode"`   // トレーニングモード（悪手警告）が有効かどうか
+	Clock        *Clock    `json:"clock,omitempty"` // 持ち時間（時間制限のある対局のみ）
+	Disconnected bool      `json:"disconnected"`    // 対局中に切断し、再接続を待っているかどうか
 }
 
 // Position - ボード上の座標を表す構造体
@@ -88,6 +426,16 @@ type Position struct {
 	Y int `json:"y"` // Y座標（0-8、白プレイヤーは8から開始、黒プレイヤーは0から開始）
 }
 
+// opponentOf - 指定プレイヤーの対戦相手のIDを返す（いない場合は空文字）
+func (m *QuoridorChessMatch) opponentOf(userID string) string {
+	for id := range m.gameState.Players {
+		if id != userID {
+			return id
+		}
+	}
+	return ""
+}
+
 // abs - 整数の絶対値を返す（ヘルパー関数）
 func abs(x int) int {
 	if x < 0 {
@@ -96,6 +444,24 @@ func abs(x int) int {
 	return x
 }
 
+// ActionHint - サーバーが受理した操作の要約（クライアントはこれをもとにアニメーションする）
+type ActionHint struct {
+	Kind     string    `json:"kind"`             // 操作の種類（"move" または "wall"）
+	PlayerID string    `json:"player_id"`        // 操作したプレイヤーID
+	From     *Position `json:"from,omitempty"`   // 移動元（コマ移動のみ）
+	To       *Position `json:"to,omitempty"`     // 移動先（コマ移動のみ）
+	Jump     bool      `json:"jump"`             // 相手コマを飛び越えたかどうか
+	Pushed   *Position `json:"pushed,omitempty"` // 押し出した相手のコマの移動先（押し出しバリアントのみ）
+	Wall     *Wall     `json:"wall,omitempty"`   // 配置した壁（壁配置のみ、向きを含む）
+	Auto     bool      `json:"auto"`             // サーバーが自動で指した手かどうか（自動移動の設定による）
+}
+
+// 操作の種類
+const (
+	ActionKindMove = "move"
+	ActionKindWall = "wall"
+)
+
 // Board - ゲームボードの状態を管理する構造体
 type Board struct {
 	Size      int    `json:"size"`  // ボードのサイズ（9x9）
@@ -107,6 +473,7 @@ type Wall struct {
 	Start      *Position `json:"start"`      // 壁の開始座標
 	End        *Position `json:"end"`        // 壁の終了座標
 	Horizontal bool      `json:"horizontal"` // 水平壁かどうか（false の場合は垂直壁）
+	PlacedPly  int       `json:"placed_ply"` // 配置した手数（棋譜の長さ、壁の風化バリアントで使用）
 }
 
 // =============================================================================
@@ -116,21 +483,126 @@ type Wall struct {
 // MatchInit - マッチ初期化時に呼び出される
 // ゲーム状態、プレイヤー管理、ボード設定を初期化
 func (m *QuoridorChessMatch) MatchInit(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, params map[string]interface{}) (interface{}, int, string) {
+	// マッチIDを記録
+	m.matchID, _ = ctx.Value(runtime.RUNTIME_CTX_MATCH_ID).(string)
 	// プレイヤーの接続状態を管理するマップを初期化
 	m.presences = make(map[string]runtime.Presence)
+	// 思考時間の集計を初期化
+	m.moveTimings = make(map[string]*moveTiming)
+	// 送信遅延中のプレイヤーを管理するマップを初期化
+	m.lagging = make(map[string]bool)
+	// 送信量の集計と低帯域モードのプレイヤーを管理するマップを初期化
+	m.bandwidth = make(map[string]*bandwidthUsage)
+	m.lowBandwidth = make(map[string]bool)
+	// 観戦者を管理するマップを初期化
+	m.spectators = make(map[string]runtime.Presence)
+	m.spectatorRequests = make(map[string]bool)
+	// 放置検出用の最終入力時刻を初期化
+	m.lastActivity = make(map[string]time.Time)
+	// ゴールから遠ざかる移動の集計を初期化
+	m.regressiveMoves = make(map[string]int)
+	// プレイヤーごとのゲームプレイ設定を初期化
+	m.settings = make(map[string]*GameplaySettings)
+	// 利用時間の上限を管理するマップを初期化
+	m.playTimeBudgets = make(map[string]*playTimeBudget)
+	// 中断の提案を管理するマップを初期化
+	m.adjournOffers = make(map[string]bool)
+	// チュートリアルのヒントの対象を管理するマップを初期化
+	m.tutorialTips = make(map[string]map[string]bool)
+	// 保留中の操作を管理するマップを初期化
+	m.bufferedActions = make(map[string]*bufferedAction)
+	// 再接続を待っているプレイヤーを管理するマップを初期化
+	m.disconnectedAt = make(map[string]time.Time)
+	// 対局者ごとのプラットフォーム（接続品質の集計用）
+	m.platforms = make(map[string]string)
+	// ストレージに書き出した履歴のまとまりの数を管理するマップを初期化
+	m.historyChunks = make(map[string]int)
+	// 停滞の検出と引き分けの合意を管理するマップを初期化
+	m.staleBest = make(map[string]int)
+	m.drawAccepts = make(map[string]bool)
+	// 対局開始の通知に含める対局者のティアを初期化
+	m.tiers = make(map[string]*PlayerTier)
+	// ヒントの使用状況を管理するマップを初期化
+	m.hints = make(map[string]*hintUsage)
+	// 対局開始の通知に含めるフレアと、称号のための壁の枚数を管理するマップを初期化
+	m.flairs = make(map[string]*Flair)
+	m.wallsPlaced = make(map[string]int)
 	// サーバーの更新頻度を設定（10Hz）
 	m.tickRate = 10
 	// ゲーム状態を初期化
 	m.gameState = &GameState{
 		Players:     make(map[string]*Player),          // プレイヤー情報を空で初期化
 		Board:       &Board{Size: 9, Walls: []Wall{}}, // 9x9ボード、壁なしで初期化
+		Notation:    []string{},                      // 棋譜は空で初期化
+		MoveLog:     []*MoveLogEntry{},               // 再生用の手順は空で初期化
+		TurnActions: []string{},                      // ターン内の操作は空で初期化
 		GameStarted: false,                           // ゲーム未開始状態
 		CreatedAt:   time.Now().Unix(),               // 現在時刻を記録
 	}
+	// 匿名モードかどうか（匿名の対局はレーティングやトーナメントの対象にしない）
+	m.anonymous, _ = params["anonymous"].(bool)
+	// プライベートマッチの作成者（参加コードを知っている相手だけが参加できる）
+	m.privateOwner, _ = params["private_owner"].(string)
+	// プレイテスト用のマッチかどうか（局面を書き換えられるため常にカジュアル戦）
+	m.playtest, _ = params["playtest"].(bool)
+	// ボット対戦の作成者（2人目の席はボット、練習用のため常にカジュアル戦）
+	m.botOwner, _ = params["bot_owner"].(string)
+	m.botDifficulty = botDifficultyParam(params)
+	// レーティング対象かどうか（指定がなければカジュアル戦、縮退モードでは常にカジュアル戦）
+	if ranked, ok := params["ranked"].(bool); ok && !casualOnly && !m.anonymous && !m.playtest && !m.vsBot() {
+		m.gameState.Ranked = ranked
+	}
+	// バリアントに応じたルールセットを決定
+	m.ruleset = newRuleset(params, m.gameState.Ranked)
+	m.gameState.Board.Size = m.ruleset.BoardSize
+	
+	// トーナメント戦の場合は結果の記録先を保持
+	if tournamentID, ok := params["tournament_id"].(string); ok && !m.anonymous {
+		m.gameState.TournamentID = tournamentID
+	}
 	
-	// マッチラベルを設定（新規参加可能）
-	labelJSON, _ := json.Marshal(&MatchLabel{Open: true})
-	m.label = &MatchLabel{Open: true}
+	// 段階的に公開する機能をマッチ単位で決定
+	m.flags = resolveFeatureFlags(ctx, logger, nk, m.matchID)
+	
+	// キッズセーフモードはデプロイ設定で決まる
+	m.kidSafe = kidSafeMode(ctx)
+	// 切断したプレイヤーの再接続を待つ時間もデプロイ設定で決まる
+	m.reconnectGrace = reconnectGrace(ctx)
+	// イベントログ・チャット・棋譜をメモリに保持する上限もデプロイ設定で決まる
+	m.historyLimits = loadHistoryLimits(ctx)
+	// クライアントに表示させるレーティング方式もデプロイ設定で決まる
+	m.ratingSystem = ratingSystem(ctx)
+	// 観戦者の上限もデプロイ設定で決まる
+	m.spectatorCap = spectatorCap(ctx)
+	// 中継を遅らせる時間もデプロイ設定で決まる
+	m.relayDelay = broadcastDelay(ctx)
+	
+	// マッチメイキングで成立したマッチは、組み合わせた2人が揃うまでの時間を計る
+	m.matchmadePlayers = matchmadePlayers(params)
+	
+	// 中断した対局の再開では保存済みの局面を復元（元の対局者以外は参加できない）
+	if gameID, ok := params["resume_game_id"].(string); ok {
+		if err := m.restoreAdjourned(ctx, nk, gameID); err != nil {
+			logger.Error("Failed to restore adjourned game %s: %v", gameID, err)
+			return nil, m.tickRate, ""
+		}
+	}
+	
+	// マッチラベルを設定（新規参加可能、ホストしているリージョンとノード、一覧で絞り込む対局条件を記録）
+	m.label = &MatchLabel{
+		Open:             m.resumedFrom == "" && !m.vsBot(),
+		Region:           hostRegion(ctx),
+		Node:             hostNode(ctx),
+		Variant:          m.ruleset.Name,
+		Anonymous:        m.anonymous,
+		Private:          m.privateOwner != "",
+		Mode:             m.matchMode(),
+		Speed:            speedCategory(m.ruleset),
+		ClockInitialMs:   m.ruleset.ClockInitialMs,
+		ClockIncrementMs: m.ruleset.ClockIncrementMs,
+		BoardSize:        m.ruleset.BoardSize,
+	}
+	labelJSON, _ := json.Marshal(m.label)
 	
 	return m.gameState, m.tickRate, string(labelJSON)
 }
@@ -138,10 +610,56 @@ func (m *QuoridorChessMatch) MatchInit(ctx context.Context, logger runtime.Logge
 // MatchJoinAttempt - プレイヤーがマッチに参加しようとした時の処理
 // 参加可能かどうかを判定（最大2人まで）
 func (m *QuoridorChessMatch) MatchJoinAttempt(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, tick int64, state interface{}, presence runtime.Presence, metadata map[string]string) (interface{}, bool, string) {
-	// プレイヤー数が上限に達している場合は参加拒否
-	if len(m.presences) >= MaxPlayers {
+	// 観戦者は対局者とは別に上限まで受け入れる
+	if isSpectatorRequest(metadata) {
+		if reason := m.spectatorJoinAttempt(ctx, logger, nk, presence, metadata); reason != "" {
+			return state, false, reason
+		}
+		return state, true, ""
+	}
+	// 観戦中のユーザーは対局者として参加できない
+	if m.isSpectator(presence.GetUserId()) {
+		return state, false, "Already spectating this match"
+	}
+	// ボット対戦には作成者だけが参加できる
+	if reason := m.checkBotJoin(presence.GetUserId()); reason != "" {
+		return state, false, reason
+	}
+	// プレイヤー数が上限に達している場合は参加拒否（ボットの席を含む）
+	if m.seatedPlayers() >= MaxPlayers {
 		return state, false, "Match is full"
 	}
+	// 中断した対局の再開では元の対局者のみ参加できる
+	if m.resumedFrom != "" && m.gameState.Players[presence.GetUserId()] == nil {
+		return state, false, "Not a player of this adjourned game"
+	}
+	// 対局中は切断した対局者の再接続のみ受け付ける
+	if m.gameState.GameStarted && m.gameState.Players[presence.GetUserId()] == nil {
+		return state, false, "Game is already in progress"
+	}
+	// 対戦回避リストに登録し合っている相手とは同じマッチに参加させない
+	for userID := range m.presences {
+		avoided, err := isAvoidedPair(ctx, nk, userID, presence.GetUserId())
+		if err != nil {
+			logger.Warn("Failed to check avoid lists: %v", err)
+			continue
+		}
+		if avoided {
+			return state, false, "Opponent is on an avoid list"
+		}
+	}
+	// 今日の利用時間の上限に達したプレイヤーはレーティング戦に参加できない
+	if m.gameState.Ranked && checkPlayTimeAvailable(ctx, logger, nk, presence.GetUserId()) != nil {
+		return state, false, "Daily play time limit reached"
+	}
+	// プライベートマッチは参加コードを確認する
+	if reason := m.checkPrivateJoin(ctx, nk, presence.GetUserId(), metadata); reason != "" {
+		return state, false, reason
+	}
+	// 接続品質の集計用にプラットフォームを記録
+	m.platforms[presence.GetUserId()] = normalizePlatform(metadata["platform"])
+	// 低帯域モードを希望するプレイヤーには差分だけを送る
+	m.setLowBandwidthRequest(presence.GetUserId(), metadata)
 	// 参加許可
 	return state, true, ""
 }
@@ -150,27 +668,64 @@ func (m *QuoridorChessMatch) MatchJoinAttempt(ctx context.Context, logger runtim
 // プレイヤー情報の設定、ゲーム開始判定を行う
 func (m *QuoridorChessMatch) MatchJoin(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, tick int64, state interface{}, presences []runtime.Presence) interface{} {
 	for _, presence := range presences {
+		// 観戦者は対局者として扱わない
+		if m.joinSpectator(dispatcher, presence) {
+			continue
+		}
+		
 		// プレイヤーの接続情報を記録
 		m.presences[presence.GetUserId()] = presence
 		
-		// ゲーム状態にプレイヤーを追加
+		// 対局中に切断したプレイヤーの再接続では席を戻して全体の状態を送り直す
+		if m.reconnectPlayer(nk, dispatcher, presence) {
+			m.sendServerInfo(dispatcher, presence)
+			continue
+		}
+		
+		// ゲーム状態にプレイヤーを追加（中断した対局の再開では保存済みの席をそのまま使う）
 		playerNum := len(m.gameState.Players) + 1
-		color := "white"  // 1人目は白
-		startY := 8       // 白プレイヤーの開始位置（下端）
-		if playerNum == 2 {
-			color = "black" // 2人目は黒
-			startY = 0      // 黒プレイヤーの開始位置（上端）
+		if m.gameState.Players[presence.GetUserId()] == nil {
+			color := "white"  // 1人目は白
+			startY := 8       // 白プレイヤーの開始位置（下端）
+			if playerNum == 2 {
+				color = "black" // 2人目は黒
+				startY = 0      // 黒プレイヤーの開始位置（上端）
+			}
+			
+			// プレイヤー情報を作成（中央のX=4、各プレイヤーの開始Y座標、壁10個）
+			m.gameState.Players[presence.GetUserId()] = &Player{
+				ID:       presence.GetUserId(),
+				Username: m.displayName(presence.GetUserId(), presence.GetUsername()),
+				Position: &Position{X: 4, Y: startY}, // ボード中央から開始
+				Walls:    InitialWalls,               // 壁の初期数
+				Color:    color,
+				Clock:    m.ruleset.newClock(),       // 持ち時間（時間制限なしの場合は nil）
+			}
 		}
+		// ボット対戦では作成者の向かいの席にボットをつける
+		m.addBotPlayer()
 		
-		// プレイヤー情報を作成（中央のX=4、各プレイヤーの開始Y座標、壁10個）
-		m.gameState.Players[presence.GetUserId()] = &Player{
-			ID:       presence.GetUserId(),
-			Username: presence.GetUsername(),
-			Position: &Position{X: 4, Y: startY}, // ボード中央から開始
-			Walls:    10,                         // 壁の初期数
-			Color:    color,
+		// 対局中に適用するゲームプレイ設定を読み込む
+		m.loadGameplaySettings(ctx, logger, nk, presence.GetUserId())
+		m.loadPlayTimeBudget(ctx, logger, nk, presence.GetUserId())
+		m.loadTutorial(ctx, logger, nk, presence.GetUserId())
+		
+		// 参加したクライアントにサーバー情報を送信（UIの機能切り替え用）
+		m.sendServerInfo(dispatcher, presence)
+		
+		// 最初の参加者の対局ペースをラベルに記録（ペースの近い相手を探しやすくする、匿名モードを除く）
+		if playerNum == 1 && !m.anonymous {
+			if stats, _, err := readPlayerStats(ctx, nk, presence.GetUserId()); err == nil {
+				m.label.Pace = stats.Pace()
+				labelJSON, _ := json.Marshal(m.label)
+				dispatcher.MatchLabelUpdate(string(labelJSON))
+			}
 		}
 		
+		// 対局開始の通知で相手に見せるティアを読み込む（配置戦中のプレイヤーが参加したレーティング戦はラベルに配置戦として記録）
+		m.loadTier(ctx, logger, nk, dispatcher, presence.GetUserId())
+		m.loadFlair(ctx, logger, nk, presence.GetUserId())
+		
 		// 他のプレイヤーにプレイヤー参加を通知
 		msg := map[string]interface{}{
 			"type": "player_joined",
@@ -179,30 +734,37 @@ func (m *QuoridorChessMatch) MatchJoin(ctx context.Context, logger runtime.Logge
 				"game_state": m.gameState,
 			},
 		}
-		msgBytes, _ := json.Marshal(msg)
-		dispatcher.BroadcastMessage(1, msgBytes, nil, nil, true)
+		m.sendMessage(dispatcher, 1, msg, nil, true)
 		
-		// 2人揃ったらゲーム開始
-		if len(m.presences) == MaxPlayers && !m.gameState.GameStarted {
+		// 2人揃ったらゲーム開始（ボット対戦ではボットを含めて数える）
+		if m.seatedPlayers() == MaxPlayers && !m.gameState.GameStarted {
 			m.gameState.GameStarted = true
+			m.gameStartedAt = time.Now()
+			m.recordEvent("game_started", EventSourceServer, "", nil)
 			// 最初のプレイヤーのターンに設定
 			for id := range m.gameState.Players {
 				m.gameState.CurrentTurn = id
 				break
 			}
+			m.startTurnTimer()
 			
 			// マッチラベルを更新（新規参加不可に変更）
 			m.label.Open = false
 			labelJSON, _ := json.Marshal(m.label)
 			dispatcher.MatchLabelUpdate(string(labelJSON))
 			
-			// ゲーム開始をすべてのプレイヤーに通知
+			// ゲーム開始をすべてのプレイヤーに通知（確定したルールと席の割り当てを含む）
+			startData := m.gameStartedData()
+			m.startSeats = startData.Seats // 再生用に開始時の席の割り当てを残す
 			startMsg := map[string]interface{}{
 				"type": "game_started",
-				"data": m.gameState,
+				"data": startData,
 			}
-			startMsgBytes, _ := json.Marshal(startMsg)
-			dispatcher.BroadcastMessage(1, startMsgBytes, nil, nil, true)
+			m.sendMessage(dispatcher, 1, startMsg, nil, true)
+			m.sendTurnTips(dispatcher)
+			
+			// フォロワーに対局開始を通知
+			m.notifyFollowers(logger, nk)
 		}
 	}
 	
@@ -210,11 +772,26 @@ func (m *QuoridorChessMatch) MatchJoin(ctx context.Context, logger runtime.Logge
 }
 
 // MatchLeave - プレイヤーがマッチから退出した時の処理
-// プレイヤー情報の削除、他プレイヤーへの通知を行う
+// 対局前・対局後はプレイヤー情報を削除し、対局中は席を残して再接続を待つ
 func (m *QuoridorChessMatch) MatchLeave(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, tick int64, state interface{}, presences []runtime.Presence) interface{} {
 	for _, presence := range presences {
-		// プレイヤーの接続情報とゲーム状態から削除
+		// 観戦者の退出は人数の通知のみ
+		if m.leaveSpectator(dispatcher, presence) {
+			continue
+		}
+		
+		// プレイヤーの接続情報を削除
 		delete(m.presences, presence.GetUserId())
+		delete(m.lagging, presence.GetUserId())
+		delete(m.lastActivity, presence.GetUserId())
+		
+		// 対局中の切断は席を残して再接続を待つ（猶予時間を過ぎたら相手の勝ち）
+		if m.gameState.GameStarted && m.gameState.Players[presence.GetUserId()] != nil {
+			m.markDisconnected(logger, nk, dispatcher, presence.GetUserId())
+			continue
+		}
+		
+		// 対局前・対局後はゲーム状態からも削除
 		delete(m.gameState.Players, presence.GetUserId())
 		
 		// 他のプレイヤーに退出を通知
@@ -224,12 +801,11 @@ func (m *QuoridorChessMatch) MatchLeave(ctx context.Context, logger runtime.Logg
 				"player_id": presence.GetUserId(),
 			},
 		}
-		msgBytes, _ := json.Marshal(msg)
-		dispatcher.BroadcastMessage(1, msgBytes, nil, nil, true)
+		m.sendMessage(dispatcher, 1, msg, nil, true)
 	}
 	
-	// プレイヤーが全員いなくなったらマッチ終了
-	if len(m.presences) == 0 {
+	// プレイヤーが全員いなくなったらマッチ終了（対局中は再接続を待つ）
+	if len(m.presences) == 0 && !m.gameState.GameStarted {
 		return nil
 	}
 	
@@ -239,6 +815,8 @@ func (m *QuoridorChessMatch) MatchLeave(ctx context.Context, logger runtime.Logg
 // MatchLoop - メインゲームループ、定期的に呼び出される
 // プレイヤーからのメッセージ処理、ゲーム状態更新を行う
 func (m *QuoridorChessMatch) MatchLoop(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, tick int64, state interface{}, messages []runtime.MatchData) interface{} {
+	m.tick = tick
+	
 	// プレイヤーからのメッセージを処理
 	for _, msg := range messages {
 		var data map[string]interface{}
@@ -246,21 +824,54 @@ func (m *QuoridorChessMatch) MatchLoop(ctx context.Context, logger runtime.Logge
 			continue // JSON解析エラーは無視
 		}
 		
+		// 観戦者からの操作は受け付けない
+		if m.isSpectator(msg.GetUserId()) {
+			continue
+		}
+		
+		// 入力があったことを記録（放置検出用）
+		m.recordActivity(msg.GetUserId())
+		
+		// レーティング対象の対局では解析・ヒント系のメッセージを拒否
+		msgType, _ := data["type"].(string)
+		if m.rejectRankedAssistance(ctx, logger, nk, dispatcher, msg, msgType) {
+			continue
+		}
+		
 		// メッセージタイプによって処理を分岐
-		switch data["type"] {
+		switch msgType {
 		case "chat":
+			// エモートは定義済みのもののみ許可
+			emote, _ := data["emote"].(string)
+			if emote != "" && !isValidEmote(emote) {
+				continue
+			}
+			
+			// キッズセーフモードと匿名モードでは自由入力のメッセージを破棄し、エモートのみ許可
+			message, _ := data["message"].(string)
+			if m.emoteOnlyChat() {
+				if emote == "" {
+					continue
+				}
+				message = ""
+			}
+			
+			// 途中から参加した人向けに履歴へ記録
+			entry := &ChatData{
+				SenderID:  msg.GetUserId(),                                  // 送信者ID
+				Username:  m.displayName(msg.GetUserId(), msg.GetUsername()), // 送信者名
+				Message:   message,                                          // メッセージ内容
+				Emote:     emote,                                            // エモート（空の場合はなし）
+				Timestamp: time.Now().Unix(),                                // 送信時刻
+			}
+			m.recordChat(entry)
+			
 			// チャットメッセージをすべてのプレイヤーにブロードキャスト
 			chatMsg := map[string]interface{}{
 				"type": "chat",
-				"data": map[string]interface{}{
-					"sender_id": msg.GetUserId(),         // 送信者ID
-					"username":  msg.GetUsername(),       // 送信者名
-					"message":   data["message"],         // メッセージ内容
-					"timestamp": time.Now().Unix(),       // 送信時刻
-				},
+				"data": entry,
 			}
-			chatMsgBytes, _ := json.Marshal(chatMsg)
-			dispatcher.BroadcastMessage(2, chatMsgBytes, nil, nil, true)
+			m.sendMessage(dispatcher, 2, chatMsg, nil, emote == "")
 			
 		case "move":
 			// コマ移動処理
@@ -268,20 +879,9 @@ func (m *QuoridorChessMatch) MatchLoop(ctx context.Context, logger runtime.Logge
 				continue // ゲームが開始されていない場合は無視
 			}
 			
-			// 自分のターンかチェック
+			// 相手のターン中に送られた手は、自分のターンが来るまで保留
 			if msg.GetUserId() != m.gameState.CurrentTurn {
-				continue // 自分のターンでない場合は無視
-			}
-			
-			// 移動先の座標を取得
-			position, ok := data["position"].(map[string]interface{})
-			if !ok {
-				continue
-			}
-			
-			x, xOk := position["x"].(float64)
-			y, yOk := position["y"].(float64)
-			if !xOk || !yOk {
+				m.bufferAction(dispatcher, msg.GetUserId(), "move", data)
 				continue
 			}
 			
@@ -291,55 +891,112 @@ func (m *QuoridorChessMatch) MatchLoop(ctx context.Context, logger runtime.Logge
 				continue
 			}
 			
-			// 移動の妥当性をチェック（基本的な移動のみ）
-			newX := int(x)
-			newY := int(y)
-			
-			// ボード範囲内チェック
-			if newX < 0 || newX > 8 || newY < 0 || newY > 8 {
-				continue
+			// 移動を検証して適用（不正な移動は理由を本人に返す）
+			if reason := m.handleMove(ctx, logger, nk, dispatcher, player, data); reason != "" {
+				m.rejectAction(dispatcher, msg.GetUserId(), "move", reason)
 			}
 			
-			// 基本的な隣接移動チェック（1マスのみ）
-			dx := newX - player.Position.X
-			dy := newY - player.Position.Y
+		case "heartbeat":
+			// 接続確認への応答（入力として記録済み）
+			ackMsg := map[string]interface{}{
+				"type": "heartbeat_ack",
+				"data": map[string]interface{}{
+					"timestamp": time.Now().Unix(),
+				},
+			}
+			if presence, ok := m.presences[msg.GetUserId()]; ok {
+				m.sendMessage(dispatcher, 1, ackMsg, []runtime.Presence{presence}, false)
+			}
 			
-			// 斜め移動は不可、1マスのみ移動可能
-			if (dx != 0 && dy != 0) || (abs(dx) + abs(dy) != 1) {
-				continue
+		case "adjourn":
+			// 対局の中断（両プレイヤーが合意したら局面を保存してマッチを終了）
+			if m.handleAdjourn(ctx, logger, nk, dispatcher, msg.GetUserId()) {
+				m.adjourned = true
 			}
 			
-			// 移動実行
-			player.Position.X = newX
-			player.Position.Y = newY
+		case "resign":
+			// 投了（相手の勝ちで対局を終了）
+			m.handleResign(ctx, logger, nk, dispatcher, msg.GetUserId())
 			
-			// 勝利判定
-			if (player.Color == "white" && newY == 0) || (player.Color == "black" && newY == 8) {
-				m.gameState.Winner = msg.GetUserId()
-				m.gameState.GameStarted = false
-			}
+		case "accept_draw":
+			// 停滞による引き分けの提案への合意（両者が合意したら引き分け）
+			m.handleAcceptDraw(ctx, logger, nk, dispatcher, msg.GetUserId())
 			
-			// ターンを切り替え
-			for id := range m.gameState.Players {
-				if id != m.gameState.CurrentTurn {
-					m.gameState.CurrentTurn = id
-					break
-				}
-			}
+		case "set_training_mode":
+			// トレーニングモードの切り替え（カジュアル戦のみ）
+			m.handleSetTrainingMode(dispatcher, msg.GetUserId(), data)
 			
-			// ゲーム状態更新を全プレイヤーに通知
-			updateMsg := map[string]interface{}{
-				"type": "game_state_update",
-				"data": m.gameState,
+		case "place_wall":
+			// 相手のターン中に送られた壁は、自分のターンが来るまで保留
+			if m.gameState.GameStarted && msg.GetUserId() != m.gameState.CurrentTurn {
+				m.bufferAction(dispatcher, msg.GetUserId(), "place_wall", data)
+				continue
 			}
-			updateMsgBytes, _ := json.Marshal(updateMsg)
-			dispatcher.BroadcastMessage(1, updateMsgBytes, nil, nil, true)
 			
-		case "place_wall":
-			// TODO: 壁配置ロジックの実装
+			// 壁の配置（残り壁数と溝の位置を検証）
+			m.handlePlaceWall(dispatcher, msg.GetUserId(), data)
 		}
 	}
 	
+	// 対局を中断した場合はマッチを終了
+	if m.adjourned {
+		return nil
+	}
+	
+	// 上限を超えたイベントログ・チャット・棋譜の古い分をストレージに書き出す
+	m.spillHistory(ctx, logger, nk)
+	
+	// 遅延が明けた中継を中継ストリームに流す
+	m.flushRelay(logger, nk, false)
+	
+	// MatchLeave が呼ばれずに残った接続を取り除く（全員いなくなった場合はマッチ終了）
+	if m.reconcilePresences(ctx, logger, nk, dispatcher, tick, state) == nil {
+		return nil
+	}
+	
+	// 利用時間の上限が近いプレイヤーに警告
+	m.checkPlayTime(dispatcher, tick)
+	
+	// 多数のプレイヤーが一斉に切断している間は、持ち時間と切断・時間切れ・放置による負けを止める
+	stormPaused := m.checkDisconnectStorm(dispatcher)
+	
+	// 手番のプレイヤーの持ち時間を減らす（切れた場合は時間切れ負け）
+	if !stormPaused {
+		m.tickClock(ctx, logger, nk, dispatcher)
+	}
+	
+	// マッチメイキングで成立したマッチに相手が来なければ辞退として記録して終了
+	if m.checkReadyTimeout(ctx, logger, nk, dispatcher) == nil {
+		return nil
+	}
+	
+	// 再接続の猶予時間を過ぎたプレイヤーは切断による負け
+	if !stormPaused && m.checkReconnectGrace(ctx, logger, nk, dispatcher) == nil {
+		return nil
+	}
+	
+	// 1ターンの制限時間を過ぎた場合は相手の勝ち
+	if !stormPaused {
+		m.checkTurnTimeout(ctx, logger, nk, dispatcher)
+	}
+	
+	// 停滞が続いた場合は引き分けを提案するか、引き分けで終了
+	m.checkStaleDraw(ctx, logger, nk, dispatcher)
+	
+	// 手番が来たプレイヤーの保留中の操作を適用
+	m.playBufferedAction(ctx, logger, nk, dispatcher)
+	
+	// 指せる手が1つしかない場合は自動で指す（本人が設定で有効にしている場合のみ）
+	m.playForcedMove(ctx, logger, nk, dispatcher)
+	
+	// ボットの手番であればボットが指す
+	m.playBotTurn(ctx, logger, nk, dispatcher)
+	
+	// 手番のプレイヤーが放置していないか確認
+	if !stormPaused {
+		m.checkAFK(ctx, logger, nk, dispatcher)
+	}
+	
 	return m.gameState
 }
 
@@ -353,35 +1010,69 @@ func (m *QuoridorChessMatch) MatchTerminate(ctx context.Context, logger runtime.
 			"reason": "Match ended",
 		},
 	}
-	msgBytes, _ := json.Marshal(msg)
-	dispatcher.BroadcastMessage(1, msgBytes, nil, nil, true)
+	m.sendMessage(dispatcher, 1, msg, nil, true)
+	
+	// 中継中であれば遅延を待たずに残りを流す
+	m.flushRelay(logger, nk, true)
 	
 	return state
 }
 
-// MatchSignal - 外部からのシグナル処理（現在未使用）
+// MatchSignal - 外部からのシグナル処理
+// 管理用RPCなどから {"type": ..., "data": ...} 形式のシグナルを受け取り、結果をJSONで返す
 func (m *QuoridorChessMatch) MatchSignal(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, tick int64, state interface{}, data string) (interface{}, string) {
-	return state, ""
+	var signal struct {
+		Type string          `json:"type"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(data), &signal); err != nil {
+		return state, signalError("invalid signal")
+	}
+	
+	switch signal.Type {
+	case "correct_position":
+		// 管理者による盤面の訂正
+		return state, m.handlePositionCorrection(ctx, logger, nk, dispatcher, signal.Data)
+	case "playtest":
+		// プレイテスト用のマッチでの局面の書き換え
+		return state, m.handlePlaytestSignal(dispatcher, signal.Data)
+	case "get_state":
+		// ソケットを使わないクライアント向けの状態取得
+		return state, m.handleGetStateSignal(signal.Data)
+	case "get_chat":
+		// 途中から観戦を始めた人向けのチャット履歴
+		return state, m.handleGetChatSignal(signal.Data)
+	case "watchdog_probe":
+		// 停止したマッチの監視からの応答確認
+		return state, m.handleWatchdogProbe(tick)
+	case "watchdog_terminate":
+		// 停止したマッチの強制終了（対局中であれば勝者なしで結果を記録）
+		m.handleWatchdogTerminate(ctx, logger, nk, dispatcher)
+		return nil, ""
+	case "set_broadcast":
+		// 中継ストリームへの中継の開始・停止（管理用RPCから）
+		return state, m.handleSetBroadcastSignal(dispatcher, signal.Data)
+	case "get_broadcast":
+		// 中継の視聴を始める人向けの遅延と最初の局面
+		return state, m.handleGetBroadcastSignal()
+	case "get_hint":
+		// カジュアル戦の手番のプレイヤーへのヒント（1局で使える回数と間隔を制限）
+		return state, m.handleGetHintSignal(ctx, logger, nk, signal.Data)
+	case "close_if_empty":
+		// 使われなかったマッチの片付け（誰も参加していなければマッチを終了）
+		if len(m.presences) == 0 && !m.gameState.GameStarted {
+			return nil, ""
+		}
+		return state, signalError("match is in use")
+	}
+	
+	return state, signalError("unknown signal type")
 }
 
 // =============================================================================
 // RPCハンドラー - クライアントから直接呼び出される機能
 // =============================================================================
 
-// JoinMatchmaking - マッチメイキングに参加するRPC
-// クライアントがマッチメイキングプールに参加要求を送信
-func JoinMatchmaking(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
-	// この機能は現在無効化されており、クライアント側で直接マッチ作成・参加を行います
-	return "{\"message\": \"matchmaking disabled, use create/join match directly\"}", nil
-}
-
-// LeaveMatchmaking - マッチメイキングから退出するRPC
-// クライアントが指定したチケットでマッチメイキングプールから退出
-func LeaveMatchmaking(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
-	// この機能は現在無効化されており、クライアント側で直接マッチを退出します
-	return "{\"success\": true}", nil
-}
-
 // SendChat - チャットメッセージ送信RPC
 // 実際の処理はMatchLoopで行われるため、ここでは成功レスポンスのみ返却
 func SendChat(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
@@ -0,0 +1,77 @@
+// Quoridor Chess 対局履歴
+// 終了した対局の結果を対局者ごとに保存し、プレイヤーが自分の過去の対局を新しい順に振り返れるようにする
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const matchHistoryCollection = "match_history" // 対局者ごとの対局履歴のストレージコレクション（対局者が所有、書き込みはサーバーのみ）
+
+const (
+	defaultMatchHistoryLimit = 20         // 対局履歴の既定の取得件数
+	maxMatchHistoryLimit     = 100        // 対局履歴の最大の取得件数
+	matchHistoryKeyBase      = 9999999999 // 対局履歴のキーで終了時刻を反転させる基準値
+)
+
+// GetMatchHistoryRequest - get_match_history RPCのリクエスト
+type GetMatchHistoryRequest struct {
+	Limit  int    `json:"limit"`  // 取得件数
+	Cursor string `json:"cursor"` // 前回のレスポンスの cursor（続きを取得する場合）
+}
+
+// matchHistoryKey - 対局履歴のストレージキー
+// キーの昇順で一覧されるため、終了時刻を反転させて新しい対局ほど先に並ぶようにする
+func matchHistoryKey(finishedAt int64, matchID string) string {
+	return fmt.Sprintf("%010d-%s", matchHistoryKeyBase-finishedAt, matchID)
+}
+
+// GetMatchHistory - 自分の過去の対局結果を新しい順に返すRPC
+func GetMatchHistory(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return "", err
+	}
+	req := &GetMatchHistoryRequest{}
+	if payload != "" {
+		if err := json.Unmarshal([]byte(payload), req); err != nil {
+			return "", errInvalidPayload
+		}
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultMatchHistoryLimit
+	}
+	if limit > maxMatchHistoryLimit {
+		limit = maxMatchHistoryLimit
+	}
+
+	objects, cursor, err := nk.StorageList(ctx, "", userID, matchHistoryCollection, limit, req.Cursor)
+	if err != nil {
+		logger.Error("get_match_history: failed to list history: %v", err)
+		return "", runtime.NewError("failed to list match history", errCodeInternal)
+	}
+
+	matches := make([]*MatchResult, 0, len(objects))
+	for _, object := range objects {
+		result := &MatchResult{}
+		if err := json.Unmarshal([]byte(object.GetValue()), result); err != nil {
+			continue
+		}
+		matches = append(matches, result)
+	}
+
+	response, err := json.Marshal(map[string]interface{}{
+		"matches": matches,
+		"cursor":  cursor,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
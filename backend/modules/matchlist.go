@@ -0,0 +1,160 @@
+// Quoridor Chess マッチ一覧
+// 参加可能な権威マッチをラベルで絞り込んでクライアントに返す
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"regexp"
+	"strconv"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	defaultMatchListLimit = 20  // 一覧の既定件数
+	maxMatchListLimit     = 100 // 一覧の最大件数
+)
+
+// ラベルの検索クエリに埋め込めるリージョン名の形式
+var regionPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// 対局の種別（マッチラベルの mode）
+const (
+	MatchModeCasual     = "casual"     // カジュアル戦
+	MatchModeRanked     = "ranked"     // レーティング戦
+	MatchModeTournament = "tournament" // トーナメント戦
+)
+
+// ListOpenMatchesRequest - list_open_matches RPCのリクエスト
+type ListOpenMatchesRequest struct {
+	Region    string `json:"region"`     // 絞り込むリージョン（空の場合は全リージョン）
+	Limit     int    `json:"limit"`      // 取得件数
+	Pace      string `json:"pace"`       // 優先したい対局ペース（一致するマッチを上位に並べる）
+	Mode      string `json:"mode"`       // 絞り込む対局の種別（空の場合はすべて）
+	Variant   string `json:"variant"`    // 絞り込むバリアント（空の場合はすべて）
+	Speed     string `json:"speed"`      // 絞り込む持ち時間の区分（空の場合はすべて）
+	BoardSize int    `json:"board_size"` // 絞り込む盤の大きさ（0 の場合はすべて）
+}
+
+// OpenMatch - 一覧に含まれるマッチ情報
+type OpenMatch struct {
+	MatchID string      `json:"match_id"` // マッチID
+	Size    int32       `json:"size"`     // 現在の参加人数
+	Label   *MatchLabel `json:"label"`    // マッチラベル
+}
+
+// matchMode - マッチラベルに載せる対局の種別を返す
+func (m *QuoridorChessMatch) matchMode() string {
+	switch {
+	case m.gameState.TournamentID != "":
+		return MatchModeTournament
+	case m.gameState.Ranked:
+		return MatchModeRanked
+	default:
+		return MatchModeCasual
+	}
+}
+
+// isValidMatchMode - 対局の種別として有効な値かどうかを返す
+func isValidMatchMode(mode string) bool {
+	return mode == MatchModeCasual || mode == MatchModeRanked || mode == MatchModeTournament
+}
+
+// isValidSpeed - 持ち時間の区分として有効な値かどうかを返す
+func isValidSpeed(speed string) bool {
+	switch speed {
+	case SpeedUntimed, SpeedBullet, SpeedBlitz, SpeedRapid, SpeedClassical:
+		return true
+	}
+	return false
+}
+
+// ListOpenMatches - 参加可能なマッチの一覧を返すRPC
+// region を指定すると、そのリージョンでホストされているマッチのみを返す
+// mode / variant / speed / board_size を指定すると、ラベルが一致するマッチのみを返す
+func ListOpenMatches(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	req := &ListOpenMatchesRequest{}
+	if payload != "" {
+		if err := json.Unmarshal([]byte(payload), req); err != nil {
+			return "", errInvalidPayload
+		}
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultMatchListLimit
+	}
+	if limit > maxMatchListLimit {
+		limit = maxMatchListLimit
+	}
+
+	// ラベルの検索クエリを組み立て
+	// 匿名マッチは anonymous_quick_play から、プライベートマッチは参加コードからのみ参加できる
+	query := "+label.open:true -label.anonymous:true -label.private:true"
+	if req.Region != "" {
+		if !regionPattern.MatchString(req.Region) {
+			return "", runtime.NewError("invalid region", errCodeInvalidArgument)
+		}
+		query += " +label.region:" + req.Region
+	}
+	if req.Pace != "" {
+		if !isValidPace(req.Pace) {
+			return "", runtime.NewError("invalid pace", errCodeInvalidArgument)
+		}
+		// 必須条件にはせず、ペースが一致するマッチのスコアを上げる
+		query += " label.pace:" + req.Pace + "^3"
+	}
+	if req.Mode != "" {
+		if !isValidMatchMode(req.Mode) {
+			return "", runtime.NewError("invalid mode", errCodeInvalidArgument)
+		}
+		query += " +label.mode:" + req.Mode
+	}
+	if req.Variant != "" {
+		if _, ok := victoryConditions[req.Variant]; !ok {
+			return "", runtime.NewError("invalid variant", errCodeInvalidArgument)
+		}
+		query += " +label.variant:" + req.Variant
+	}
+	if req.Speed != "" {
+		if !isValidSpeed(req.Speed) {
+			return "", runtime.NewError("invalid speed", errCodeInvalidArgument)
+		}
+		query += " +label.speed:" + req.Speed
+	}
+	if req.BoardSize != 0 {
+		if req.BoardSize < 0 {
+			return "", runtime.NewError("invalid board size", errCodeInvalidArgument)
+		}
+		query += " +label.board_size:" + strconv.Itoa(req.BoardSize)
+	}
+
+	minSize := 0
+	maxSize := MaxPlayers - 1
+	matches, err := nk.MatchList(ctx, limit, true, "", &minSize, &maxSize, query)
+	if err != nil {
+		logger.Error("list_open_matches: failed to list matches: %v", err)
+		return "", runtime.NewError("failed to list matches", errCodeInternal)
+	}
+
+	result := make([]*OpenMatch, 0, len(matches))
+	for _, match := range matches {
+		label := &MatchLabel{}
+		if err := json.Unmarshal([]byte(match.GetLabel().GetValue()), label); err != nil {
+			continue
+		}
+		result = append(result, &OpenMatch{
+			MatchID: match.GetMatchId(),
+			Size:    match.GetSize(),
+			Label:   label,
+		})
+	}
+
+	response, err := json.Marshal(map[string]interface{}{"matches": result})
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
@@ -0,0 +1,588 @@
+// Quoridor Chess マッチメイキング
+// ストレージ上の待ち行列でチケットを管理し、2人揃った時点でサーバー側でマッチを作成する
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	matchmakingTicketCollection = "matchmaking_tickets" // チケットのストレージコレクション（本人が所有）
+	matchmakingQueueCollection  = "matchmaking"         // 待ち行列のストレージコレクション（システムが所有）
+	matchmakingQueueKey         = "queue"               // 待ち行列のストレージキー
+	matchmakingTicketTTL        = 5 * time.Minute       // 相手が見つからないチケットの有効期間
+	matchmakingWriteRetries     = 3                     // 待ち行列の同時更新が衝突したときの再試行回数
+
+	newcomerGames            = 10               // この対局数に達するまでは初心者用の待ち行列で対戦相手を探す
+	newcomerBotFallbackAfter = 60 * time.Second // 初心者用の待ち行列で相手が見つからない場合に弱いボットと対戦させるまでの時間
+)
+
+// 組み合わせるレーティング差の上限（待ち時間が長いほど広げる）
+const (
+	matchRatingWindow      = 100              // 待ち始めの上限
+	matchRatingWindowStep  = 50               // 一定時間待つごとに広げる幅
+	matchRatingWindowEvery = 30 * time.Second // 上限を広げる間隔
+	maxMatchRatingWindow   = 400              // 広げる上限
+)
+
+// 待ち行列の区分
+const (
+	PoolMain     = "main"     // 通常の待ち行列
+	PoolNewcomer = "newcomer" // 始めたばかりのプレイヤー同士の待ち行列
+)
+
+// チケットの状態
+const (
+	TicketSearching = "searching" // 対戦相手を検索中
+	TicketMatched   = "matched"   // マッチが作成された
+	TicketCancelled = "cancelled" // プレイヤーが取り消した
+	TicketExpired   = "expired"   // 有効期限切れ
+)
+
+// QueueTicket - マッチメイキングのチケット
+type QueueTicket struct {
+	TicketID  string `json:"ticket_id"`          // チケットID
+	Status    string `json:"status"`             // チケットの状態
+	MatchID   string `json:"match_id,omitempty"` // 作成されたマッチのID（matched の場合）
+	VsBot     bool   `json:"vs_bot,omitempty"`   // 相手が見つからずボットとの対局を作成したかどうか
+	Variant   string `json:"variant"`            // 検索しているバリアント
+	Pool      string `json:"pool"`               // 検索している待ち行列の区分
+	CreatedAt int64  `json:"created_at"`         // 作成時刻（Unix時刻）
+	UpdatedAt int64  `json:"updated_at"`         // 更新時刻（Unix時刻）
+	// 成立したマッチを辞退したことによる待ち行列の利用停止の解除時刻（matchmaking_status の応答のみ）
+	CooldownUntil int64 `json:"cooldown_until,omitempty"`
+}
+
+// queueEntry - 待ち行列の1件
+type queueEntry struct {
+	TicketID  string `json:"ticket_id"`
+	UserID    string `json:"user_id"`
+	Variant   string `json:"variant,omitempty"` // 空の場合は標準ルール
+	Pool      string `json:"pool,omitempty"`    // 空の場合は通常の待ち行列
+	Rating    int    `json:"rating,omitempty"`  // 参加時のレーティング（0 の場合はストレージから読み直す）
+	CreatedAt int64  `json:"created_at"`
+}
+
+// matchmakingQueue - 検索中のチケットの待ち行列（古い順）
+type matchmakingQueue struct {
+	Entries []*queueEntry `json:"entries"`
+}
+
+// queueVariants - マッチメイキングで選べるバリアント（いずれもカジュアル戦）
+var queueVariants = map[string]bool{
+	VariantStandard: true,
+	VariantDecay:    true,
+}
+
+// JoinMatchmakingRequest - join_matchmaking RPCのリクエスト
+type JoinMatchmakingRequest struct {
+	Variant string `json:"variant"` // 対戦したいバリアント（空の場合は標準ルール）
+}
+
+// MatchmakingTicketRequest - チケットを指定するRPCのリクエスト
+type MatchmakingTicketRequest struct {
+	TicketID string `json:"ticket"`
+}
+
+// newTicketID - ランダムなチケットIDを生成
+func newTicketID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// readQueue - 待ち行列を読み込み、期限切れのエントリを取り除く
+func readQueue(ctx context.Context, nk runtime.NakamaModule) (*matchmakingQueue, string, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: matchmakingQueueCollection,
+		Key:        matchmakingQueueKey,
+	}})
+	if err != nil {
+		return nil, "", err
+	}
+
+	queue := &matchmakingQueue{Entries: []*queueEntry{}}
+	version := ""
+	if len(objects) > 0 {
+		if err := json.Unmarshal([]byte(objects[0].GetValue()), queue); err != nil {
+			return nil, "", err
+		}
+		version = objects[0].GetVersion()
+	}
+
+	cutoff := time.Now().Add(-matchmakingTicketTTL).Unix()
+	active := queue.Entries[:0]
+	for _, entry := range queue.Entries {
+		if entry.CreatedAt > cutoff {
+			active = append(active, entry)
+		}
+	}
+	queue.Entries = active
+	return queue, version, nil
+}
+
+// queueWrite - 待ち行列の書き込み内容を作成（version による楽観的ロック付き）
+func queueWrite(queue *matchmakingQueue, version string) (*runtime.StorageWrite, error) {
+	value, err := json.Marshal(queue)
+	if err != nil {
+		return nil, err
+	}
+	if version == "" {
+		version = "*"
+	}
+	return &runtime.StorageWrite{
+		Collection:      matchmakingQueueCollection,
+		Key:             matchmakingQueueKey,
+		Value:           string(value),
+		Version:         version,
+		PermissionRead:  0,
+		PermissionWrite: 0,
+	}, nil
+}
+
+// ticketWrite - チケットの書き込み内容を作成（本人のみ閲覧可能）
+func ticketWrite(userID string, ticket *QueueTicket) (*runtime.StorageWrite, error) {
+	value, err := json.Marshal(ticket)
+	if err != nil {
+		return nil, err
+	}
+	return &runtime.StorageWrite{
+		Collection:      matchmakingTicketCollection,
+		Key:             ticket.TicketID,
+		UserID:          userID,
+		Value:           string(value),
+		PermissionRead:  1,
+		PermissionWrite: 0,
+	}, nil
+}
+
+// readTicket - チケットを読み込む（存在しない場合は nil）
+// 検索中のまま有効期限を過ぎたチケットは expired として返す
+func readTicket(ctx context.Context, nk runtime.NakamaModule, userID, ticketID string) (*QueueTicket, string, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: matchmakingTicketCollection,
+		Key:        ticketID,
+		UserID:     userID,
+	}})
+	if err != nil || len(objects) == 0 {
+		return nil, "", err
+	}
+
+	ticket := &QueueTicket{}
+	if err := json.Unmarshal([]byte(objects[0].GetValue()), ticket); err != nil {
+		return nil, "", err
+	}
+	if ticket.Status == TicketSearching && time.Unix(ticket.CreatedAt, 0).Add(matchmakingTicketTTL).Before(time.Now()) {
+		ticket.Status = TicketExpired
+	}
+	return ticket, objects[0].GetVersion(), nil
+}
+
+// matchmakingPool - プレイヤーの対局数から待ち行列の区分を決める（一度卒業したら通常の待ち行列のまま）
+func matchmakingPool(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID string) string {
+	stats, _, err := readPlayerStats(ctx, nk, userID)
+	if err != nil {
+		logger.Warn("Failed to read stats for matchmaking pool of %s: %v", userID, err)
+		return PoolMain
+	}
+	if stats.GraduatedAt == 0 && stats.GamesPlayed < newcomerGames {
+		return PoolNewcomer
+	}
+	return PoolMain
+}
+
+// matchmakingRating - 組み合わせに使うプレイヤーのレーティング（表示に使うレーティング方式の値）を返す
+func matchmakingRating(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID string) int {
+	rating, _, err := readRating(ctx, nk, userID)
+	if err != nil {
+		logger.Warn("Failed to read rating for matchmaking of %s: %v", userID, err)
+		return initialRating
+	}
+	return rating.displayRating(ratingSystem(ctx))
+}
+
+// ratingWindow - 待ち時間に応じた、組み合わせるレーティング差の上限を返す
+func ratingWindow(waited time.Duration) int {
+	window := matchRatingWindow + matchRatingWindowStep*int(waited/matchRatingWindowEvery)
+	if window > maxMatchRatingWindow {
+		return maxMatchRatingWindow
+	}
+	return window
+}
+
+// findOpponent - 待ち行列から同じバリアント・同じ区分の対戦相手を探す（自分自身と回避リストの相手は除く）
+// レーティング差が相手の待ち時間に応じた上限を超える相手とは組み合わせない
+func findOpponent(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, queue *matchmakingQueue, userID, variant, pool string, rating int) int {
+	now := time.Now()
+	for i, entry := range queue.Entries {
+		if entry.UserID == userID {
+			continue
+		}
+		entryPool := entry.Pool
+		if entryPool == "" {
+			entryPool = PoolMain
+		}
+		if entryPool != pool {
+			continue
+		}
+		entryVariant := entry.Variant
+		if entryVariant == "" {
+			entryVariant = VariantStandard // バリアント導入前のエントリは標準ルール
+		}
+		if entryVariant != variant {
+			continue
+		}
+		entryRating := entry.Rating
+		if entryRating == 0 {
+			entryRating = matchmakingRating(ctx, logger, nk, entry.UserID)
+		}
+		if abs(entryRating-rating) > ratingWindow(now.Sub(time.Unix(entry.CreatedAt, 0))) {
+			continue
+		}
+		avoided, err := isAvoidedPair(ctx, nk, userID, entry.UserID)
+		if err != nil {
+			logger.Warn("Failed to check avoid lists: %v", err)
+		}
+		if avoided {
+			continue
+		}
+		return i
+	}
+	return -1
+}
+
+// JoinMatchmaking - マッチメイキングに参加するRPC
+// 検索中の相手がいればその場でマッチを作成し、いなければ待ち行列に加えてチケットを返す
+// Nakama 組み込みのマッチメイカーのチケットへの移行は未実装（Goランタイムにサーバーからチケットを追加・削除する呼び出しがないため）
+func JoinMatchmaking(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return "", err
+	}
+	// 今日の利用時間の上限に達している場合は待ち行列に入れない
+	if err := checkPlayTimeAvailable(ctx, logger, nk, userID); err != nil {
+		return "", err
+	}
+	// 成立したマッチを辞退して利用停止中の場合も待ち行列に入れない
+	if err := checkDodgeCooldown(ctx, logger, nk, userID); err != nil {
+		return "", err
+	}
+
+	req := &JoinMatchmakingRequest{}
+	if payload != "" {
+		if err := json.Unmarshal([]byte(payload), req); err != nil {
+			return "", errInvalidPayload
+		}
+	}
+	if req.Variant == "" {
+		req.Variant = VariantStandard
+	}
+	if !queueVariants[req.Variant] {
+		return "", runtime.NewError("variant is not offered in matchmaking", errCodeInvalidArgument)
+	}
+
+	pool := matchmakingPool(ctx, logger, nk, userID)
+	rating := matchmakingRating(ctx, logger, nk, userID)
+
+	ticketID, err := newTicketID()
+	if err != nil {
+		return "", runtime.NewError("failed to create ticket", errCodeInternal)
+	}
+	now := time.Now().Unix()
+	ticket := &QueueTicket{
+		TicketID:  ticketID,
+		Status:    TicketSearching,
+		Variant:   req.Variant,
+		Pool:      pool,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	for attempt := 0; attempt < matchmakingWriteRetries; attempt++ {
+		queue, version, err := readQueue(ctx, nk)
+		if err != nil {
+			logger.Error("join_matchmaking: failed to read queue: %v", err)
+			return "", runtime.NewError("failed to read matchmaking queue", errCodeInternal)
+		}
+
+		// すでに検索中のチケットがあればそれを返す
+		for _, entry := range queue.Entries {
+			if entry.UserID == userID {
+				existing, _, err := readTicket(ctx, nk, userID, entry.TicketID)
+				if err == nil && existing != nil && existing.Status == TicketSearching {
+					return marshalTicket(existing)
+				}
+			}
+		}
+
+		opponentIndex := findOpponent(ctx, logger, nk, queue, userID, req.Variant, pool, rating)
+		if opponentIndex < 0 {
+			// 相手がいなければ待ち行列に追加
+			queue.Entries = append(queue.Entries, &queueEntry{TicketID: ticketID, UserID: userID, Variant: req.Variant, Pool: pool, Rating: rating, CreatedAt: now})
+			writes, err := joinWrites(queue, version, userID, ticket)
+			if err != nil {
+				return "", runtime.NewError("failed to encode ticket", errCodeInternal)
+			}
+			if _, err := nk.StorageWrite(ctx, writes); err != nil {
+				continue // 同時更新の衝突時は読み直して再試行
+			}
+			return marshalTicket(ticket)
+		}
+
+		// 先にマッチを作成し、相手を待ち行列から取り出す書き込みと双方のチケットの更新をまとめて行う
+		// 途中で失敗しても、相手が待ち行列から外れたまま検索中で取り残されることはない
+		opponent := queue.Entries[opponentIndex]
+		matchID, err := nk.MatchCreate(ctx, "quoridor_chess", map[string]interface{}{
+			"variant":           req.Variant,
+			"matchmade_players": userID + "," + opponent.UserID,
+		})
+		if err != nil {
+			logger.Error("join_matchmaking: failed to create match: %v", err)
+			return "", runtime.NewError("failed to create match", errCodeInternal)
+		}
+		queue.Entries = append(queue.Entries[:opponentIndex], queue.Entries[opponentIndex+1:]...)
+		ticket.Status = TicketMatched
+		ticket.MatchID = matchID
+		opponentTicket := &QueueTicket{
+			TicketID:  opponent.TicketID,
+			Status:    TicketMatched,
+			MatchID:   matchID,
+			Variant:   req.Variant,
+			Pool:      pool,
+			CreatedAt: opponent.CreatedAt,
+			UpdatedAt: now,
+		}
+		writes, err := joinWrites(queue, version, userID, ticket)
+		if err != nil {
+			return "", runtime.NewError("failed to encode ticket", errCodeInternal)
+		}
+		opponentWrite, err := ticketWrite(opponent.UserID, opponentTicket)
+		if err != nil {
+			return "", runtime.NewError("failed to encode ticket", errCodeInternal)
+		}
+		if _, err := nk.StorageWrite(ctx, append(writes, opponentWrite)); err != nil {
+			// 使われないマッチは辞退の記録が残る前に閉じ、待ち行列を読み直して再試行する
+			if _, err := nk.MatchSignal(ctx, matchID, watchdogStopSignal); err != nil {
+				logger.Warn("join_matchmaking: failed to close unused match %s: %v", matchID, err)
+			}
+			ticket.Status, ticket.MatchID = TicketSearching, ""
+			continue
+		}
+		return marshalTicket(ticket)
+	}
+
+	return "", runtime.NewError("matchmaking queue is busy, try again", errCodeResourceExhausted)
+}
+
+// OnMatchmakerMatched - Nakama 組み込みのマッチメイカー（クライアントがソケットで追加したチケット）で
+// 組み合わせが成立したときに呼ばれ、権威マッチを作成してそのIDを返す
+// チケットの文字列プロパティ "variant" でバリアントを指定できる（両者が同じ場合のみ適用、それ以外は標準ルール）
+func OnMatchmakerMatched(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, entries []runtime.MatchmakerEntry) (string, error) {
+	variant := ""
+	userIDs := make([]string, 0, len(entries))
+	for i, entry := range entries {
+		userIDs = append(userIDs, entry.GetPresence().GetUserId())
+		entryVariant, _ := entry.GetProperties()["variant"].(string)
+		if i == 0 {
+			variant = entryVariant
+		} else if entryVariant != variant {
+			variant = ""
+		}
+	}
+	if !queueVariants[variant] {
+		variant = VariantStandard
+	}
+
+	matchID, err := nk.MatchCreate(ctx, "quoridor_chess", map[string]interface{}{
+		"variant":           variant,
+		"matchmade_players": strings.Join(userIDs, ","),
+	})
+	if err != nil {
+		logger.Error("Failed to create match for matchmaker entries: %v", err)
+		return "", err
+	}
+	return matchID, nil
+}
+
+// joinWrites - 待ち行列への追加とチケット作成をまとめた書き込み内容
+func joinWrites(queue *matchmakingQueue, version, userID string, ticket *QueueTicket) ([]*runtime.StorageWrite, error) {
+	write, err := queueWrite(queue, version)
+	if err != nil {
+		return nil, err
+	}
+	own, err := ticketWrite(userID, ticket)
+	if err != nil {
+		return nil, err
+	}
+	return []*runtime.StorageWrite{write, own}, nil
+}
+
+// MatchmakingStatus - チケットの状態（searching / matched / cancelled / expired）と、辞退による利用停止の解除時刻を返すRPC
+// 初心者用の待ち行列で一定時間相手が見つからない場合は、弱いボットとの対局を作成して matched にする
+func MatchmakingStatus(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return "", err
+	}
+	req := &MatchmakingTicketRequest{}
+	if err := json.Unmarshal([]byte(payload), req); err != nil || req.TicketID == "" {
+		return "", errInvalidPayload
+	}
+
+	ticket, _, err := readTicket(ctx, nk, userID, req.TicketID)
+	if err != nil {
+		logger.Error("matchmaking_status: failed to read ticket: %v", err)
+		return "", runtime.NewError("failed to read ticket", errCodeInternal)
+	}
+	if ticket == nil {
+		return "", runtime.NewError("ticket not found", errCodeNotFound)
+	}
+	if ticket.Status == TicketSearching && ticket.Pool == PoolNewcomer && time.Since(time.Unix(ticket.CreatedAt, 0)) >= newcomerBotFallbackAfter {
+		if ticket, err = newcomerBotFallback(ctx, logger, nk, userID, ticket); err != nil {
+			return "", err
+		}
+	}
+	ticket.CooldownUntil = dodgeCooldownUntil(ctx, logger, nk, userID)
+	return marshalTicket(ticket)
+}
+
+// newcomerBotFallback - 初心者用の待ち行列で相手が見つからないチケットを待ち行列から外し、弱いボットとの対局を作成する
+// 同時に相手が見つかって待ち行列から取り出されていた場合は、その時点のチケットを返す
+func newcomerBotFallback(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID string, ticket *QueueTicket) (*QueueTicket, error) {
+	for attempt := 0; attempt < matchmakingWriteRetries; attempt++ {
+		queue, version, err := readQueue(ctx, nk)
+		if err != nil {
+			logger.Error("matchmaking_status: failed to read queue: %v", err)
+			return nil, runtime.NewError("failed to read matchmaking queue", errCodeInternal)
+		}
+		remaining := queue.Entries[:0]
+		for _, entry := range queue.Entries {
+			if entry.TicketID != ticket.TicketID {
+				remaining = append(remaining, entry)
+			}
+		}
+		if len(remaining) == len(queue.Entries) {
+			current, _, err := readTicket(ctx, nk, userID, ticket.TicketID)
+			if err != nil || current == nil {
+				logger.Error("matchmaking_status: failed to re-read ticket: %v", err)
+				return nil, runtime.NewError("failed to read ticket", errCodeInternal)
+			}
+			return current, nil
+		}
+		queue.Entries = remaining
+
+		// 先にマッチを作成し、待ち行列からの取り出しとチケットの更新をまとめて書き込む
+		matchID, err := nk.MatchCreate(ctx, "quoridor_chess", map[string]interface{}{
+			"bot_owner":      userID,
+			"bot_difficulty": BotDifficultyEasy,
+			"variant":        ticket.Variant,
+		})
+		if err != nil {
+			logger.Error("matchmaking_status: failed to create bot match: %v", err)
+			return nil, runtime.NewError("failed to create match", errCodeInternal)
+		}
+		matched := *ticket
+		matched.Status = TicketMatched
+		matched.MatchID = matchID
+		matched.VsBot = true
+		matched.UpdatedAt = time.Now().Unix()
+		writes, err := joinWrites(queue, version, userID, &matched)
+		if err != nil {
+			return nil, runtime.NewError("failed to encode ticket", errCodeInternal)
+		}
+		if _, err := nk.StorageWrite(ctx, writes); err != nil {
+			// 使われないマッチを閉じ、待ち行列を読み直して再試行する
+			if _, err := nk.MatchSignal(ctx, matchID, watchdogStopSignal); err != nil {
+				logger.Warn("matchmaking_status: failed to close unused match %s: %v", matchID, err)
+			}
+			continue
+		}
+		return &matched, nil
+	}
+
+	return nil, runtime.NewError("matchmaking queue is busy, try again", errCodeResourceExhausted)
+}
+
+// LeaveMatchmaking - マッチメイキングから退出するRPC
+// 検索中のチケットを待ち行列から取り除き、cancelled にする
+func LeaveMatchmaking(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return "", err
+	}
+	req := &MatchmakingTicketRequest{}
+	if err := json.Unmarshal([]byte(payload), req); err != nil || req.TicketID == "" {
+		return "", errInvalidPayload
+	}
+
+	for attempt := 0; attempt < matchmakingWriteRetries; attempt++ {
+		ticket, ticketVersion, err := readTicket(ctx, nk, userID, req.TicketID)
+		if err != nil {
+			logger.Error("leave_matchmaking: failed to read ticket: %v", err)
+			return "", runtime.NewError("failed to read ticket", errCodeInternal)
+		}
+		if ticket == nil {
+			return "", runtime.NewError("ticket not found", errCodeNotFound)
+		}
+		if ticket.Status != TicketSearching {
+			// すでにマッチ済み・取り消し済みのチケットはそのまま返す
+			return marshalTicket(ticket)
+		}
+
+		queue, version, err := readQueue(ctx, nk)
+		if err != nil {
+			logger.Error("leave_matchmaking: failed to read queue: %v", err)
+			return "", runtime.NewError("failed to read matchmaking queue", errCodeInternal)
+		}
+		remaining := queue.Entries[:0]
+		for _, entry := range queue.Entries {
+			if entry.TicketID != req.TicketID {
+				remaining = append(remaining, entry)
+			}
+		}
+		if len(remaining) == len(queue.Entries) {
+			// 待ち行列にない場合は同時に相手が見つかって取り出されたため、取り消さずにチケットの状態を返す
+			ticket, _, err := readTicket(ctx, nk, userID, req.TicketID)
+			if err != nil || ticket == nil {
+				logger.Error("leave_matchmaking: failed to re-read ticket: %v", err)
+				return "", runtime.NewError("failed to read ticket", errCodeInternal)
+			}
+			return marshalTicket(ticket)
+		}
+		queue.Entries = remaining
+
+		ticket.Status = TicketCancelled
+		ticket.UpdatedAt = time.Now().Unix()
+		writes, err := joinWrites(queue, version, userID, ticket)
+		if err != nil {
+			return "", runtime.NewError("failed to encode ticket", errCodeInternal)
+		}
+		// 読み込んだ時点のチケットに対してのみ取り消しを書き込む
+		writes[1].Version = ticketVersion
+		if _, err := nk.StorageWrite(ctx, writes); err != nil {
+			continue // 同時にマッチした可能性があるため読み直す
+		}
+		return marshalTicket(ticket)
+	}
+
+	return "", runtime.NewError("matchmaking queue is busy, try again", errCodeResourceExhausted)
+}
+
+// marshalTicket - チケットをRPCのレスポンスに変換
+func marshalTicket(ticket *QueueTicket) (string, error) {
+	response, err := json.Marshal(ticket)
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
@@ -0,0 +1,236 @@
+// Quoridor Chess コマ移動
+// 合法手の生成と、受理した移動の適用（棋譜・手番の更新と通知）を担当
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// legalMoves - プレイヤーが現在の局面で移動できるマスの一覧を返す
+// 隣接する4方向のうち、ボード内で壁に塞がれておらず、相手のコマがいないマス
+// 隣に相手のコマがいる場合はその向こうへ飛び越えられ、向こう側が壁か盤端で塞がれていれば斜め横に回り込める
+// 押し出しバリアントでは、飛び越える代わりに相手のマスへ進んで相手を向こう側へ押し出す
+func (m *QuoridorChessMatch) legalMoves(player *Player) []Position {
+	board := m.gameState.Board
+	occupied := make(map[Position]bool, len(m.gameState.Players))
+	for _, other := range m.gameState.Players {
+		if other.ID != player.ID && other.Position != nil {
+			occupied[*other.Position] = true
+		}
+	}
+	// 壁に塞がれておらず、コマもいない移動先かどうか
+	open := func(fromX, fromY, toX, toY int) bool {
+		return board.InBounds(toX, toY) && !board.IsBlocked(fromX, fromY, toX, toY) && !occupied[Position{X: toX, Y: toY}]
+	}
+
+	moves := make([]Position, 0, len(directions))
+	for _, dir := range directions {
+		nx, ny := player.Position.X+dir.X, player.Position.Y+dir.Y
+		if !board.InBounds(nx, ny) || board.IsBlocked(player.Position.X, player.Position.Y, nx, ny) {
+			continue
+		}
+		if !occupied[Position{X: nx, Y: ny}] {
+			moves = append(moves, Position{X: nx, Y: ny})
+			continue
+		}
+
+		// 相手のコマを真っすぐ飛び越える（押し出しバリアントでは相手のマスに進んで相手を1マス押し出す）
+		jx, jy := nx+dir.X, ny+dir.Y
+		if board.InBounds(jx, jy) && !board.IsBlocked(nx, ny, jx, jy) {
+			if !occupied[Position{X: jx, Y: jy}] {
+				if m.ruleset.Push {
+					moves = append(moves, Position{X: nx, Y: ny})
+				} else {
+					moves = append(moves, Position{X: jx, Y: jy})
+				}
+			}
+			continue
+		}
+
+		// 向こう側が塞がれている場合は、相手のコマの左右（斜め）に回り込む
+		side := []Position{{X: dir.Y, Y: dir.X}, {X: -dir.Y, Y: -dir.X}}
+		for _, s := range side {
+			if open(nx, ny, nx+s.X, ny+s.Y) {
+				moves = append(moves, Position{X: nx + s.X, Y: ny + s.Y})
+			}
+		}
+	}
+	return moves
+}
+
+// isLegalMove - 指定したマスへの移動が合法かどうかを返す
+func (m *QuoridorChessMatch) isLegalMove(player *Player, x, y int) bool {
+	for _, move := range m.legalMoves(player) {
+		if move.X == x && move.Y == y {
+			return true
+		}
+	}
+	return false
+}
+
+// handleMove - 移動要求を検証して適用する
+// 適用しなかった場合はその理由を返す（トレーニングモードで警告して保留した場合は空文字）
+func (m *QuoridorChessMatch) handleMove(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, player *Player, data map[string]interface{}) string {
+	// 移動先の座標を取得
+	position := positionFromData(data["position"])
+	if position == nil {
+		return "invalid position"
+	}
+
+	// 移動の妥当性をチェック（隣接マスへの移動と相手のコマの飛び越えのみ、壁は通れない）
+	if !m.isLegalMove(player, position.X, position.Y) {
+		return "illegal move"
+	}
+
+	// トレーニングモードでは悪手を警告し、確認付きの再送信があるまで移動を保留
+	if player.TrainingMode {
+		if confirmed, _ := data["confirm"].(bool); !confirmed {
+			if loss := m.evaluateMoveLoss(player, position.X, position.Y); loss >= BlunderThreshold {
+				m.sendBlunderWarning(dispatcher, player.ID, position.X, position.Y, loss)
+				return ""
+			}
+		}
+	}
+
+	// 移動を適用して全員に通知
+	m.applyMove(ctx, logger, nk, dispatcher, player, position.X, position.Y, false)
+	return ""
+}
+
+// applyMove - 合法と確認済みの移動を適用し、勝利判定と手番の交代を行って全員に通知
+// auto が true の場合はサーバーが代わりに指した手として扱い、思考時間などの集計には含めない
+func (m *QuoridorChessMatch) applyMove(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, player *Player, newX, newY int, auto bool) {
+	// 思考時間と、ゴールから遠ざかる移動を記録
+	if !auto {
+		m.recordMoveTime(player.ID)
+		m.recordRegressiveMove(player, newX, newY)
+	}
+
+	// 移動実行（アニメーション用に移動元を記録）
+	from := &Position{X: player.Position.X, Y: player.Position.Y}
+	pushed := m.pushOpponent(player, newX, newY)
+	player.Position.X = newX
+	player.Position.Y = newY
+	m.gameState.LastAction = &ActionHint{
+		Kind:     ActionKindMove,
+		PlayerID: player.ID,
+		From:     from,
+		To:       &Position{X: newX, Y: newY},
+		Jump:     abs(newX-from.X)+abs(newY-from.Y) > 1,
+		Pushed:   pushed,
+		Auto:     auto,
+	}
+	m.gameState.Notation = append(m.gameState.Notation, squareName(newX, newY))
+	m.recordMoveLog()
+
+	// 自動移動はサーバーの判断として記録
+	kind, source := "move", EventSourcePlayer
+	if auto {
+		kind, source = "auto_move", EventSourceServer
+	}
+	eventData := map[string]interface{}{
+		"from": from,
+		"to":   &Position{X: newX, Y: newY},
+	}
+	if pushed != nil {
+		eventData["pushed"] = pushed
+	}
+	m.recordEvent(kind, source, player.ID, eventData)
+
+	// 勝利判定（バリアントの勝利条件で評価）
+	m.checkVictory(ctx, logger, nk)
+
+	m.finishTurn(dispatcher)
+}
+
+// continueTurn - 直前の操作をターン内の操作として数え、まだ操作が残っていれば差分を通知して true を返す
+func (m *QuoridorChessMatch) continueTurn(dispatcher runtime.MatchDispatcher) bool {
+	if m.gameState.LastAction != nil {
+		m.gameState.TurnActions = append(m.gameState.TurnActions, m.gameState.LastAction.Kind)
+	}
+	remaining := m.ruleset.ActionsPerTurn - len(m.gameState.TurnActions)
+	if !m.gameState.GameStarted || remaining <= 0 {
+		return false
+	}
+
+	// 次の操作の思考時間は直前の操作から数える
+	m.startTurnTimer()
+	msg := map[string]interface{}{
+		"type": "turn_action",
+		"data": &TurnActionData{Action: m.gameState.LastAction, ActionsRemaining: remaining},
+	}
+	m.sendMessage(dispatcher, 1, msg, nil, true)
+	return true
+}
+
+// wallPlacedThisTurn - 現在のターンですでに壁を置いたかどうかを返す
+func (m *QuoridorChessMatch) wallPlacedThisTurn() bool {
+	for _, kind := range m.gameState.TurnActions {
+		if kind == ActionKindWall {
+			return true
+		}
+	}
+	return false
+}
+
+// pushOpponent - 移動先に相手のコマがいれば、移動と同じ向きに1マス押し出す（押し出しバリアントのみ）
+// 押し出した場合は相手のコマの移動先を返す
+func (m *QuoridorChessMatch) pushOpponent(player *Player, newX, newY int) *Position {
+	dx, dy := newX-player.Position.X, newY-player.Position.Y
+	for _, other := range m.gameState.Players {
+		if other.ID == player.ID || other.Position == nil {
+			continue
+		}
+		if other.Position.X == newX && other.Position.Y == newY {
+			other.Position.X += dx
+			other.Position.Y += dy
+			return &Position{X: other.Position.X, Y: other.Position.Y}
+		}
+	}
+	return nil
+}
+
+// finishTurn - 操作を終えたプレイヤーのターンを進め、ターンが終わっていれば手番を相手に渡して全プレイヤーに通知する
+func (m *QuoridorChessMatch) finishTurn(dispatcher runtime.MatchDispatcher) {
+	// 1ターンに複数回行動するバリアントでは、操作が残っていれば手番を渡さずに差分だけ通知
+	if m.continueTurn(dispatcher) {
+		return
+	}
+
+	// 指し終えたプレイヤーの持ち時間を精算して加算時間を足す
+	m.completeClockTurn()
+	wallPlaced := m.wallPlacedThisTurn()
+
+	// ターンを切り替え
+	for id := range m.gameState.Players {
+		if id != m.gameState.CurrentTurn {
+			m.gameState.CurrentTurn = id
+			break
+		}
+	}
+	m.gameState.TurnActions = []string{}
+	m.turnChangedAt = time.Now()
+	m.startTurnTimer()
+
+	// 風化した壁を取り除く（以降の経路計算は壁を除いたボードで行われる）
+	m.expireWalls(dispatcher)
+
+	// 壁の配置も最短手数の更新もないターンを数える（停滞による引き分けの検出用）
+	m.trackStaleness(dispatcher, wallPlaced)
+
+	// ゲーム状態更新を全プレイヤーに通知
+	updateMsg := map[string]interface{}{
+		"type": "game_state_update",
+		"data": m.gameState,
+	}
+	m.sendMessage(dispatcher, 1, updateMsg, nil, true)
+
+	// 一定の手数ごとに局面のスナップショットを送る（クライアントの自己照合用）
+	m.sendPositionSnapshot(dispatcher)
+
+	// 手番が来たプレイヤーにヒントを送る
+	m.sendTurnTips(dispatcher)
+}
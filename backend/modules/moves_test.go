@@ -0,0 +1,131 @@
+package main
+
+import (
+	"sort"
+	"testing"
+)
+
+// newTestMatch - 白と黒のコマと壁だけを置いた対局を作る
+func newTestMatch(push bool, white, black Position, walls ...Wall) *QuoridorChessMatch {
+	return &QuoridorChessMatch{
+		gameState: &GameState{
+			Players: map[string]*Player{
+				"white": {ID: "white", Color: "white", Position: &white, Walls: InitialWalls},
+				"black": {ID: "black", Color: "black", Position: &black, Walls: InitialWalls},
+			},
+			Board: &Board{Size: 9, Walls: walls},
+		},
+		ruleset: &Ruleset{Name: VariantStandard, BoardSize: 9, ActionsPerTurn: 1, Push: push},
+	}
+}
+
+// sortedPositions - 比較しやすいよう行、列の順に並べ替えた座標の一覧を返す
+func sortedPositions(positions []Position) []Position {
+	sorted := append([]Position(nil), positions...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Y != sorted[j].Y {
+			return sorted[i].Y < sorted[j].Y
+		}
+		return sorted[i].X < sorted[j].X
+	})
+	return sorted
+}
+
+func TestLegalMoves(t *testing.T) {
+	tests := []struct {
+		name  string
+		push  bool
+		white Position
+		black Position
+		walls []Wall
+		want  []Position
+	}{
+		{
+			name:  "周囲4方向",
+			white: Position{X: 4, Y: 4}, black: Position{X: 0, Y: 0},
+			want: []Position{{X: 4, Y: 3}, {X: 3, Y: 4}, {X: 5, Y: 4}, {X: 4, Y: 5}},
+		},
+		{
+			name:  "盤端では3方向",
+			white: Position{X: 0, Y: 8}, black: Position{X: 8, Y: 0},
+			want: []Position{{X: 0, Y: 7}, {X: 1, Y: 8}},
+		},
+		{
+			name:  "壁で塞がれた方向には進めない",
+			white: Position{X: 4, Y: 4}, black: Position{X: 0, Y: 0},
+			walls: []Wall{hWall(4, 3), vWall(4, 4)},
+			want:  []Position{{X: 3, Y: 4}, {X: 4, Y: 5}},
+		},
+		{
+			name:  "相手のコマを飛び越える",
+			white: Position{X: 4, Y: 4}, black: Position{X: 4, Y: 3},
+			want: []Position{{X: 4, Y: 2}, {X: 3, Y: 4}, {X: 5, Y: 4}, {X: 4, Y: 5}},
+		},
+		{
+			name:  "向こう側が壁なら斜め横に回り込む",
+			white: Position{X: 4, Y: 4}, black: Position{X: 4, Y: 3},
+			walls: []Wall{hWall(4, 2)},
+			want:  []Position{{X: 3, Y: 3}, {X: 5, Y: 3}, {X: 3, Y: 4}, {X: 5, Y: 4}, {X: 4, Y: 5}},
+		},
+		{
+			name:  "向こう側が盤端なら斜め横に回り込む",
+			white: Position{X: 4, Y: 1}, black: Position{X: 4, Y: 0},
+			want: []Position{{X: 3, Y: 0}, {X: 5, Y: 0}, {X: 3, Y: 1}, {X: 5, Y: 1}, {X: 4, Y: 2}},
+		},
+		{
+			name:  "壁のある側には回り込めない",
+			white: Position{X: 4, Y: 4}, black: Position{X: 4, Y: 3},
+			walls: []Wall{hWall(4, 2), vWall(3, 2)},
+			want:  []Position{{X: 5, Y: 3}, {X: 3, Y: 4}, {X: 5, Y: 4}, {X: 4, Y: 5}},
+		},
+		{
+			name:  "間に壁があれば飛び越えられない",
+			white: Position{X: 4, Y: 4}, black: Position{X: 4, Y: 3},
+			walls: []Wall{hWall(4, 3)},
+			want:  []Position{{X: 3, Y: 4}, {X: 5, Y: 4}, {X: 4, Y: 5}},
+		},
+		{
+			name:  "押し出しバリアントでは相手のマスへ進む",
+			push:  true,
+			white: Position{X: 4, Y: 4}, black: Position{X: 4, Y: 3},
+			want: []Position{{X: 4, Y: 3}, {X: 3, Y: 4}, {X: 5, Y: 4}, {X: 4, Y: 5}},
+		},
+		{
+			name:  "押し出せない場合は斜め横に回り込む",
+			push:  true,
+			white: Position{X: 4, Y: 4}, black: Position{X: 4, Y: 3},
+			walls: []Wall{hWall(4, 2)},
+			want:  []Position{{X: 3, Y: 3}, {X: 5, Y: 3}, {X: 3, Y: 4}, {X: 5, Y: 4}, {X: 4, Y: 5}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestMatch(tt.push, tt.white, tt.black, tt.walls...)
+			got := sortedPositions(m.legalMoves(m.gameState.Players["white"]))
+			want := sortedPositions(tt.want)
+			if len(got) != len(want) {
+				t.Fatalf("legalMoves = %v, want %v", got, want)
+			}
+			for i := range got {
+				if got[i] != want[i] {
+					t.Fatalf("legalMoves = %v, want %v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPushOpponent(t *testing.T) {
+	m := newTestMatch(true, Position{X: 4, Y: 4}, Position{X: 4, Y: 3})
+	pushed := m.pushOpponent(m.gameState.Players["white"], 4, 3)
+	if pushed == nil || *pushed != (Position{X: 4, Y: 2}) {
+		t.Fatalf("pushOpponent = %v, want {4 2}", pushed)
+	}
+	if black := m.gameState.Players["black"].Position; *black != (Position{X: 4, Y: 2}) {
+		t.Errorf("black position = %v, want {4 2}", *black)
+	}
+
+	if pushed := m.pushOpponent(m.gameState.Players["white"], 3, 4); pushed != nil {
+		t.Errorf("pushOpponent to an empty square = %v, want nil", pushed)
+	}
+}
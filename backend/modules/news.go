@@ -0,0 +1,128 @@
+// Quoridor Chess お知らせ
+// トーナメントの終了などの注目の結果を、ロビーのチャンネルに参加しているプレイヤーに通知で配信する（受け取らない設定にしたプレイヤーを除く）
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	NotificationCodeNews = 104 // 注目の結果のお知らせ
+
+	// Nakama のチャンネル（ルーム）のストリームモード（サーバー側の StreamModeChannel）
+	streamModeChannel = 2
+)
+
+// お知らせの種類
+const (
+	NewsTournamentConcluded = "tournament_concluded" // トーナメントの終了と優勝者
+)
+
+// NewsItem - 配信するお知らせ
+type NewsItem struct {
+	Kind    string                 `json:"kind"`    // お知らせの種類
+	Subject string                 `json:"subject"` // 通知の件名
+	Data    map[string]interface{} `json:"data"`    // クライアントでの表示用の詳細
+}
+
+// NewsPublisher - お知らせの配信先
+type NewsPublisher interface {
+	Publish(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, item *NewsItem)
+}
+
+// newsPublisher - 起動時の設定で決まるお知らせの配信先（未設定の場合は nil で配信しない）
+var newsPublisher NewsPublisher
+
+// newNewsPublisher - runtime.env の設定からお知らせの配信先を作成
+func newNewsPublisher(ctx context.Context) NewsPublisher {
+	room := envValue(ctx, EnvNewsLobbyRoom, "")
+	if room == "" {
+		return nil
+	}
+	return &lobbyNewsPublisher{room: room}
+}
+
+// lobbyNewsPublisher - ロビーのチャンネル（ルーム）に参加中のプレイヤーに通知を送る
+type lobbyNewsPublisher struct {
+	room string
+}
+
+func (p *lobbyNewsPublisher) Publish(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, item *NewsItem) {
+	presences, err := nk.StreamUserList(streamModeChannel, "", "", p.room, false, true)
+	if err != nil {
+		logger.Warn("Failed to list lobby %s for news: %v", p.room, err)
+		return
+	}
+
+	content := map[string]interface{}{
+		"kind": item.Kind,
+		"data": item.Data,
+	}
+	notifications := make([]*runtime.NotificationSend, 0, len(presences))
+	seen := make(map[string]bool, len(presences))
+	for _, presence := range presences {
+		userID := presence.GetUserId()
+		if seen[userID] {
+			continue
+		}
+		seen[userID] = true
+		// お知らせを受け取らない設定のプレイヤーには送らない
+		if settings, _, err := readGameplaySettings(ctx, nk, userID); err == nil && settings.DisableNews {
+			continue
+		}
+		notifications = append(notifications, &runtime.NotificationSend{
+			UserID:     userID,
+			Subject:    item.Subject,
+			Content:    content,
+			Code:       NotificationCodeNews,
+			Persistent: false,
+		})
+	}
+	if len(notifications) == 0 {
+		return
+	}
+	if err := nk.NotificationsSend(ctx, notifications); err != nil {
+		logger.Warn("Failed to send %s news: %v", item.Kind, err)
+	}
+}
+
+// publishNews - お知らせの配信先が設定されていれば配信する
+func publishNews(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, item *NewsItem) {
+	if newsPublisher == nil {
+		return
+	}
+	newsPublisher.Publish(ctx, logger, nk, item)
+}
+
+// announceTournamentEnd - トーナメントの終了時に優勝者をお知らせとして配信する
+func announceTournamentEnd(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, tournament *api.Tournament, end, reset int64) error {
+	if newsPublisher == nil {
+		return nil
+	}
+	records, _, _, _, err := nk.TournamentRecordsList(ctx, tournament.GetId(), nil, 1, "", 0)
+	if err != nil {
+		logger.Warn("Failed to list records of tournament %s: %v", tournament.GetId(), err)
+		return nil
+	}
+
+	data := map[string]interface{}{
+		"tournament_id": tournament.GetId(),
+		"title":         tournament.GetTitle(),
+	}
+	if len(records) > 0 {
+		data["winner_id"] = records[0].GetOwnerId()
+		data["winner_username"] = records[0].GetUsername().GetValue()
+		data["score"] = records[0].GetScore()
+	}
+	publishNews(ctx, logger, nk, &NewsItem{
+		Kind:    NewsTournamentConcluded,
+		Subject: fmt.Sprintf("Tournament %s has concluded", tournament.GetTitle()),
+		Data:    data,
+	})
+	return nil
+}
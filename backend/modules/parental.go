@@ -0,0 +1,317 @@
+// Quoridor Chess 保護者による利用時間の制限
+// アカウントごとに1日の対局時間の上限を設定し、上限を超えたらマッチメイキングとレーティング戦への参加を止める
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	parentalControlsCollection = "parental_controls" // 保護者設定のストレージコレクション（本人も読めない）
+	parentalControlsKey        = "play_time_limit"   // 保護者設定のストレージキー
+	playTimeUsageCollection    = "play_time_usage"   // 1日の対局時間のストレージコレクション
+	playTimeUsageKey           = "daily"             // 1日の対局時間のストレージキー
+
+	minParentalPINLength  = 4               // 暗証番号の最小桁数
+	maxDailyLimitMinutes  = 24 * 60         // 1日の上限として設定できる最大値
+	playTimeWarningBefore = 5 * time.Minute // 上限のこの時間前に対局中の警告を送る
+	playTimeCheckInterval = 10              // 対局中に上限を確認する間隔（ティック数、10Hzで1秒）
+)
+
+var errPlayTimeExceeded = runtime.NewError("daily play time limit reached", errCodeResourceExhausted)
+
+// ParentalControls - 保護者が設定した利用制限
+type ParentalControls struct {
+	DailyLimitMinutes int    `json:"daily_limit_minutes"` // 1日の対局時間の上限（分、0 は無制限）
+	PINSalt           string `json:"pin_salt"`            // 暗証番号のソルト
+	PINHash           string `json:"pin_hash"`            // 暗証番号のハッシュ
+}
+
+// PlayTimeUsage - 1日の対局時間の集計（UTCの日付単位）
+type PlayTimeUsage struct {
+	Date    string `json:"date"`    // 集計している日付（YYYY-MM-DD）
+	Seconds int64  `json:"seconds"` // 対局した秒数
+}
+
+// SetPlayTimeLimitRequest - set_play_time_limit RPCのリクエスト
+type SetPlayTimeLimitRequest struct {
+	DailyLimitMinutes int    `json:"daily_limit_minutes"`
+	PIN               string `json:"pin"`     // 設定済みの暗証番号（初回は新しく設定する暗証番号）
+	NewPIN            string `json:"new_pin"` // 暗証番号を変更する場合のみ指定
+}
+
+// PlayTimeStatus - get_play_time_status RPCのレスポンス
+type PlayTimeStatus struct {
+	DailyLimitMinutes int   `json:"daily_limit_minutes"` // 1日の上限（分、0 は無制限）
+	UsedSeconds       int64 `json:"used_seconds"`        // 今日の対局時間（秒）
+	RemainingSeconds  int64 `json:"remaining_seconds"`   // 残り時間（秒、無制限の場合は -1）
+}
+
+// playTimeBudget - 対局中のプレイヤーの利用時間の上限と警告状態
+type playTimeBudget struct {
+	limit       time.Duration // 1日の上限
+	usedAtStart time.Duration // 対局開始前までの今日の対局時間
+	warned      bool          // 上限が近いことを警告したかどうか
+	exceeded    bool          // 上限を超えたことを通知したかどうか
+}
+
+// hashPIN - 暗証番号をソルト付きでハッシュ化
+func hashPIN(salt, pin string) string {
+	sum := sha256.Sum256([]byte(salt + ":" + pin))
+	return hex.EncodeToString(sum[:])
+}
+
+// readParentalControls - 保護者設定を読み込む（未設定の場合は nil）
+func readParentalControls(ctx context.Context, nk runtime.NakamaModule, userID string) (*ParentalControls, string, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: parentalControlsCollection,
+		Key:        parentalControlsKey,
+		UserID:     userID,
+	}})
+	if err != nil || len(objects) == 0 {
+		return nil, "", err
+	}
+	controls := &ParentalControls{}
+	if err := json.Unmarshal([]byte(objects[0].GetValue()), controls); err != nil {
+		return nil, "", err
+	}
+	return controls, objects[0].GetVersion(), nil
+}
+
+// readPlayTimeUsage - 今日の対局時間を読み込む（日付が変わっていれば0から）
+func readPlayTimeUsage(ctx context.Context, nk runtime.NakamaModule, userID string, now time.Time) (*PlayTimeUsage, string, error) {
+	today := now.UTC().Format("2006-01-02")
+	usage := &PlayTimeUsage{Date: today}
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: playTimeUsageCollection,
+		Key:        playTimeUsageKey,
+		UserID:     userID,
+	}})
+	if err != nil {
+		return nil, "", err
+	}
+	if len(objects) == 0 {
+		return usage, "", nil
+	}
+	stored := &PlayTimeUsage{}
+	if err := json.Unmarshal([]byte(objects[0].GetValue()), stored); err != nil {
+		return nil, "", err
+	}
+	if stored.Date == today {
+		usage = stored
+	}
+	return usage, objects[0].GetVersion(), nil
+}
+
+// playTimeStatus - プレイヤーの今日の利用状況を返す
+func playTimeStatus(ctx context.Context, nk runtime.NakamaModule, userID string) (*PlayTimeStatus, error) {
+	controls, _, err := readParentalControls(ctx, nk, userID)
+	if err != nil {
+		return nil, err
+	}
+	usage, _, err := readPlayTimeUsage(ctx, nk, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	status := &PlayTimeStatus{UsedSeconds: usage.Seconds, RemainingSeconds: -1}
+	if controls != nil && controls.DailyLimitMinutes > 0 {
+		status.DailyLimitMinutes = controls.DailyLimitMinutes
+		status.RemainingSeconds = int64(controls.DailyLimitMinutes)*60 - usage.Seconds
+		if status.RemainingSeconds < 0 {
+			status.RemainingSeconds = 0
+		}
+	}
+	return status, nil
+}
+
+// checkPlayTimeAvailable - 今日の上限に達していればエラーを返す（読み込みに失敗した場合は制限しない）
+func checkPlayTimeAvailable(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID string) error {
+	status, err := playTimeStatus(ctx, nk, userID)
+	if err != nil {
+		logger.Warn("Failed to read play time status for %s: %v", userID, err)
+		return nil
+	}
+	if status.RemainingSeconds == 0 {
+		return errPlayTimeExceeded
+	}
+	return nil
+}
+
+// SetPlayTimeLimit - 1日の対局時間の上限を設定するRPC（保護者の暗証番号が必要）
+func SetPlayTimeLimit(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return "", err
+	}
+	req := &SetPlayTimeLimitRequest{}
+	if err := json.Unmarshal([]byte(payload), req); err != nil {
+		return "", errInvalidPayload
+	}
+	if req.DailyLimitMinutes < 0 || req.DailyLimitMinutes > maxDailyLimitMinutes {
+		return "", errInvalidPayload
+	}
+
+	controls, version, err := readParentalControls(ctx, nk, userID)
+	if err != nil {
+		logger.Error("set_play_time_limit: failed to read controls: %v", err)
+		return "", runtime.NewError("failed to read parental controls", errCodeInternal)
+	}
+
+	newPIN := req.NewPIN
+	if controls == nil {
+		// 初回は指定された暗証番号を登録する
+		controls = &ParentalControls{}
+		version = "*"
+		newPIN = req.PIN
+	} else if subtle.ConstantTimeCompare([]byte(hashPIN(controls.PINSalt, req.PIN)), []byte(controls.PINHash)) != 1 {
+		return "", runtime.NewError("incorrect PIN", errCodePermissionDenied)
+	}
+	if newPIN != "" {
+		if len(newPIN) < minParentalPINLength {
+			return "", runtime.NewError("PIN is too short", errCodeInvalidArgument)
+		}
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return "", runtime.NewError("failed to set PIN", errCodeInternal)
+		}
+		controls.PINSalt = hex.EncodeToString(salt)
+		controls.PINHash = hashPIN(controls.PINSalt, newPIN)
+	}
+	controls.DailyLimitMinutes = req.DailyLimitMinutes
+
+	value, err := json.Marshal(controls)
+	if err != nil {
+		return "", err
+	}
+	if _, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      parentalControlsCollection,
+		Key:             parentalControlsKey,
+		UserID:          userID,
+		Value:           string(value),
+		Version:         version,
+		PermissionRead:  0,
+		PermissionWrite: 0,
+	}}); err != nil {
+		return "", runtime.NewError("parental controls were modified concurrently", errCodeFailedPrecondition)
+	}
+
+	return GetPlayTimeStatus(ctx, logger, db, nk, "")
+}
+
+// GetPlayTimeStatus - 今日の対局時間と残り時間を返すRPC
+func GetPlayTimeStatus(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return "", err
+	}
+	status, err := playTimeStatus(ctx, nk, userID)
+	if err != nil {
+		logger.Error("get_play_time_status: failed to read status: %v", err)
+		return "", runtime.NewError("failed to read play time status", errCodeInternal)
+	}
+
+	response, err := json.Marshal(status)
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
+
+// loadPlayTimeBudget - 参加したプレイヤーに上限が設定されていれば、対局中の確認用に読み込む
+func (m *QuoridorChessMatch) loadPlayTimeBudget(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID string) {
+	status, err := playTimeStatus(ctx, nk, userID)
+	if err != nil {
+		logger.Warn("Failed to read play time status for %s: %v", userID, err)
+		return
+	}
+	if status.DailyLimitMinutes == 0 {
+		return
+	}
+	m.playTimeBudgets[userID] = &playTimeBudget{
+		limit:       time.Duration(status.DailyLimitMinutes) * time.Minute,
+		usedAtStart: time.Duration(status.UsedSeconds) * time.Second,
+	}
+}
+
+// checkPlayTime - 対局中に上限が近づいたプレイヤー、超えたプレイヤー本人に警告を送る
+// 対局は打ち切らず、最後まで続けられる
+func (m *QuoridorChessMatch) checkPlayTime(dispatcher runtime.MatchDispatcher, tick int64) {
+	if tick%playTimeCheckInterval != 0 || !m.gameState.GameStarted || m.gameStartedAt.IsZero() {
+		return
+	}
+	elapsed := time.Since(m.gameStartedAt)
+	for userID, budget := range m.playTimeBudgets {
+		remaining := budget.limit - budget.usedAtStart - elapsed
+		if remaining < 0 {
+			remaining = 0
+		}
+		switch {
+		case remaining == 0 && !budget.exceeded:
+			budget.exceeded = true
+			budget.warned = true
+		case remaining <= playTimeWarningBefore && !budget.warned:
+			budget.warned = true
+		default:
+			continue
+		}
+
+		presence, ok := m.presences[userID]
+		if !ok {
+			continue
+		}
+		msg := map[string]interface{}{
+			"type": "play_time_warning",
+			"data": &PlayTimeWarningData{SecondsRemaining: int64(remaining / time.Second)},
+		}
+		m.sendMessage(dispatcher, 1, msg, []runtime.Presence{presence}, true)
+	}
+}
+
+// recordPlayTime - 対局終了時に対局時間を両プレイヤーの今日の利用時間に加算
+func (m *QuoridorChessMatch) recordPlayTime(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule) {
+	if m.gameStartedAt.IsZero() {
+		return
+	}
+	now := time.Now()
+	played := int64(now.Sub(m.gameStartedAt) / time.Second)
+
+	for userID := range m.gameState.Players {
+		if m.isBot(userID) {
+			continue
+		}
+		usage, version, err := readPlayTimeUsage(ctx, nk, userID, now)
+		if err != nil {
+			logger.Warn("Failed to read play time usage for %s: %v", userID, err)
+			continue
+		}
+		usage.Seconds += played
+		value, err := json.Marshal(usage)
+		if err != nil {
+			continue
+		}
+		if version == "" {
+			version = "*"
+		}
+		if _, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+			Collection:      playTimeUsageCollection,
+			Key:             playTimeUsageKey,
+			UserID:          userID,
+			Value:           string(value),
+			Version:         version,
+			PermissionRead:  1,
+			PermissionWrite: 0,
+		}}); err != nil {
+			logger.Warn("Failed to write play time usage for %s: %v", userID, err)
+		}
+	}
+}
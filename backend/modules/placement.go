@@ -0,0 +1,72 @@
+// Quoridor Chess シーズンの昇格前の配置戦
+// シーズンが始まって最初の数局のレーティング戦を配置戦として扱い、通常より大きくレーティングを動かして
+// 実力に近い位置まで早く収束させる。配置戦の進み具合はプロフィールとマッチラベルで確認できる
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	placementGames     = 5     // シーズンごとの配置戦の数
+	placementK         = 64    // 配置戦で1局に動くEloレーティングの大きさ（通常の2倍）
+	placementDeviation = 250.0 // シーズン開始時に引き上げるGlicko-2のレーティング偏差の下限
+)
+
+// PlacementProgress - シーズンの配置戦の進み具合
+type PlacementProgress struct {
+	Season   string `json:"season"`   // シーズン（例: "2026-Q4"）
+	Played   int    `json:"played"`   // 指した配置戦の数
+	Required int    `json:"required"` // 必要な配置戦の数
+	Complete bool   `json:"complete"` // 配置戦を終えたかどうか
+}
+
+// currentSeason - 現在のシーズンを返す（四半期ごとに新しいシーズンが始まる）
+func currentSeason(now time.Time) string {
+	now = now.UTC()
+	return fmt.Sprintf("%d-Q%d", now.Year(), (int(now.Month())-1)/3+1)
+}
+
+// startSeason - 保存されたレーティングが前のシーズンのものであれば、今シーズンの配置戦を始める
+// レーティングは引き継ぎ、Glicko-2の偏差だけ引き上げて配置戦で大きく動くようにする。ティアは配置戦を終えてから付け直す
+func (r *PlayerRating) startSeason(now time.Time) {
+	season := currentSeason(now)
+	if r.Season == season {
+		return
+	}
+	r.Season = season
+	r.PlacementPlayed = 0
+	r.Tier = nil
+	if r.Glicko.Deviation < placementDeviation {
+		r.Glicko.Deviation = placementDeviation
+	}
+}
+
+// inPlacement - 今シーズンの配置戦が残っているかどうか
+func (r *PlayerRating) inPlacement(now time.Time) bool {
+	return r.Season != currentSeason(now) || r.PlacementPlayed < placementGames
+}
+
+// placementProgress - 今シーズンの配置戦の進み具合を返す
+func (r *PlayerRating) placementProgress(now time.Time) *PlacementProgress {
+	progress := &PlacementProgress{Season: currentSeason(now), Required: placementGames}
+	if r.Season == progress.Season {
+		progress.Played = r.PlacementPlayed
+	}
+	progress.Complete = progress.Played >= placementGames
+	return progress
+}
+
+// updatePlacementLabel - レーティング戦に配置戦中のプレイヤーが参加したら、マッチラベルに配置戦として記録する
+func (m *QuoridorChessMatch) updatePlacementLabel(dispatcher runtime.MatchDispatcher, rating *PlayerRating, now time.Time) {
+	if !m.gameState.Ranked || m.label.Placement || !rating.inPlacement(now) {
+		return
+	}
+	m.label.Placement = true
+	labelJSON, _ := json.Marshal(m.label)
+	dispatcher.MatchLabelUpdate(string(labelJSON))
+}
@@ -0,0 +1,142 @@
+// Quoridor Chess プレイテスト用コマンド
+// playtest:true で作成したカジュアル戦に限り、バリアントの調整用に対局中の局面を書き換えるコマンドを MatchSignal で受け付ける
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const maxPlaytestWalls = 20 // grant_walls で設定できる壁数の上限
+
+// プレイテスト用コマンド
+const (
+	PlaytestGrantWalls = "grant_walls" // 残り壁数を設定
+	PlaytestTeleport   = "teleport"    // コマを任意のマスに移動
+	PlaytestSetClock   = "set_clock"   // 持ち時間を設定
+)
+
+// PlaytestCommand - プレイテスト用コマンドの内容
+type PlaytestCommand struct {
+	Command     string    `json:"command"`                // コマンド名
+	PlayerID    string    `json:"player_id"`              // 対象のプレイヤー
+	Walls       int       `json:"walls,omitempty"`        // grant_walls: 設定する残り壁数
+	Position    *Position `json:"position,omitempty"`     // teleport: 移動先
+	RemainingMs int64     `json:"remaining_ms,omitempty"` // set_clock: 設定する持ち時間（ミリ秒）
+}
+
+// AdminPlaytestCommandRequest - admin_playtest_command RPCのリクエスト
+type AdminPlaytestCommandRequest struct {
+	MatchID string `json:"match_id"`
+	PlaytestCommand
+}
+
+// applyPlaytestCommand - コマンドを検証して局面に適用する（エラーの場合は理由を返す）
+func (m *QuoridorChessMatch) applyPlaytestCommand(cmd *PlaytestCommand) string {
+	player := m.gameState.Players[cmd.PlayerID]
+	if player == nil {
+		return "player_id must be a player in the match"
+	}
+
+	switch cmd.Command {
+	case PlaytestGrantWalls:
+		if cmd.Walls < 0 || cmd.Walls > maxPlaytestWalls {
+			return "walls out of range"
+		}
+		player.Walls = cmd.Walls
+
+	case PlaytestTeleport:
+		pos := cmd.Position
+		if pos == nil || !m.gameState.Board.InBounds(pos.X, pos.Y) {
+			return "position out of bounds"
+		}
+		for id, other := range m.gameState.Players {
+			if id != cmd.PlayerID && other.Position != nil && *other.Position == *pos {
+				return "square is occupied"
+			}
+		}
+		if m.goalOf(player).Reached(pos.X, pos.Y) {
+			return "cannot teleport onto the goal"
+		}
+		player.Position = &Position{X: pos.X, Y: pos.Y}
+
+	case PlaytestSetClock:
+		if player.Clock == nil {
+			return "match has no clock"
+		}
+		if cmd.RemainingMs <= 0 {
+			return "remaining_ms must be positive"
+		}
+		player.Clock.RemainingMs = cmd.RemainingMs
+
+	default:
+		return "unknown playtest command"
+	}
+	return ""
+}
+
+// handlePlaytestSignal - プレイテスト用コマンドを適用してイベントログに記録し、全員に新しい局面を送る
+func (m *QuoridorChessMatch) handlePlaytestSignal(dispatcher runtime.MatchDispatcher, data json.RawMessage) string {
+	if !m.playtest {
+		return signalError("match was not created for playtesting")
+	}
+	if !m.gameState.GameStarted {
+		return signalError("game is not in progress")
+	}
+	cmd := &PlaytestCommand{}
+	if err := json.Unmarshal(data, cmd); err != nil {
+		return signalError("invalid playtest command")
+	}
+	if reason := m.applyPlaytestCommand(cmd); reason != "" {
+		return signalError(reason)
+	}
+
+	m.recordEvent("playtest_command", EventSourceServer, cmd.PlayerID, map[string]interface{}{"command": cmd})
+	msg := map[string]interface{}{
+		"type": "playtest_command",
+		"data": &PlaytestCommandData{Command: cmd.Command, PlayerID: cmd.PlayerID, GameState: m.gameState},
+	}
+	m.sendMessage(dispatcher, 1, msg, nil, true)
+
+	response, _ := json.Marshal(map[string]interface{}{"success": true, "game_state": m.gameState})
+	return string(response)
+}
+
+// AdminPlaytestCommand - プレイテスト用のマッチにコマンドを送るRPC（サーバー間呼び出しのみ）
+func AdminPlaytestCommand(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	if err := requireServerCaller(ctx); err != nil {
+		return "", err
+	}
+	req := &AdminPlaytestCommandRequest{}
+	if err := json.Unmarshal([]byte(payload), req); err != nil || req.MatchID == "" || req.Command == "" {
+		return "", errInvalidPayload
+	}
+
+	signal, err := json.Marshal(map[string]interface{}{
+		"type": "playtest",
+		"data": &req.PlaytestCommand,
+	})
+	if err != nil {
+		return "", err
+	}
+	result, err := nk.MatchSignal(ctx, req.MatchID, string(signal))
+	if err != nil {
+		logger.Warn("admin_playtest_command: signal to match %s failed: %v", req.MatchID, err)
+		return "", runtime.NewError("match not found", errCodeNotFound)
+	}
+
+	var response struct {
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(result), &response); err != nil {
+		return "", runtime.NewError("invalid response from match", errCodeInternal)
+	}
+	if !response.Success {
+		return "", runtime.NewError(response.Error, errCodeFailedPrecondition)
+	}
+	return result, nil
+}
@@ -0,0 +1,61 @@
+// Quoridor Chess プレゼンスの照合
+// ノードの障害などで MatchLeave が呼ばれなかった接続を検出し、マッチから取り除く
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	// Nakama の権威マッチのストリームモード（サーバー側の StreamModeMatchAuthoritative）
+	streamModeMatchAuthoritative = 6
+
+	presenceReconcileInterval = 100 // 照合を行う間隔（ティック数、10Hzで10秒）
+)
+
+// stalePresences - m.presences と観戦者のうち、マッチのストリームに実際には接続していないものを返す
+func (m *QuoridorChessMatch) stalePresences(logger runtime.Logger, nk runtime.NakamaModule) []runtime.Presence {
+	// マッチIDは "<UUID>.<ノード名>" の形式
+	parts := strings.SplitN(m.matchID, ".", 2)
+	if len(parts) != 2 {
+		return nil
+	}
+	connected, err := nk.StreamUserList(streamModeMatchAuthoritative, parts[0], "", parts[1], true, true)
+	if err != nil {
+		logger.Warn("Failed to list presences of match %s: %v", m.matchID, err)
+		return nil
+	}
+
+	live := make(map[string]bool, len(connected))
+	for _, presence := range connected {
+		live[presence.GetSessionId()] = true
+	}
+	stale := make([]runtime.Presence, 0)
+	for _, presence := range m.presenceList() {
+		if !live[presence.GetSessionId()] {
+			stale = append(stale, presence)
+		}
+	}
+	return stale
+}
+
+// reconcilePresences - 一定間隔で接続中のプレゼンスを照合し、切断済みのものを退出として処理する
+// 退出処理は MatchLeave と同じ流れで行う。戻り値が nil の場合はマッチを終了する
+func (m *QuoridorChessMatch) reconcilePresences(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, tick int64, state interface{}) interface{} {
+	if tick%presenceReconcileInterval != 0 || len(m.presences)+len(m.spectators) == 0 {
+		return state
+	}
+	stale := m.stalePresences(logger, nk)
+	if len(stale) == 0 {
+		return state
+	}
+
+	for _, presence := range stale {
+		logger.Warn("Removing stale presence %s (session %s) from match %s", presence.GetUserId(), presence.GetSessionId(), m.matchID)
+		m.recordEvent("stale_presence_removed", EventSourceServer, presence.GetUserId(), map[string]interface{}{"session_id": presence.GetSessionId()})
+	}
+	return m.MatchLeave(ctx, logger, nil, nk, dispatcher, tick, state, stale)
+}
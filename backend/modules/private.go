@@ -0,0 +1,126 @@
+// Quoridor Chess プライベートマッチ
+// 参加コードを知っている相手だけが参加できるマッチを作成し、公開マッチの一覧には載せない
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// CreatePrivateMatchRequest - create_private_match RPCのリクエスト
+type CreatePrivateMatchRequest struct {
+	Variant string `json:"variant"` // バリアント名（省略時は標準ルール）
+}
+
+// JoinByCodeRequest - join_by_code RPCのリクエスト
+type JoinByCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// PrivateMatchResponse - プライベートマッチの作成・参加コード解決RPCのレスポンス
+// 参加する際はこのコードを参加時のメタデータ "code" に指定する
+type PrivateMatchResponse struct {
+	MatchID   string `json:"match_id"`   // 参加先のマッチID
+	Code      string `json:"code"`       // 参加コード
+	ExpiresAt int64  `json:"expires_at"` // 参加コードの有効期限（Unix時刻）
+}
+
+// checkPrivateJoin - プライベートマッチへの参加を参加コードで確認する
+// 作成者と対局者（再接続）はコードなしで参加できる。問題なければ空文字列を返す
+func (m *QuoridorChessMatch) checkPrivateJoin(ctx context.Context, nk runtime.NakamaModule, userID string, metadata map[string]string) string {
+	if !m.label.Private || userID == m.privateOwner || m.gameState.Players[userID] != nil {
+		return ""
+	}
+	code := normalizeInviteCode(metadata["code"])
+	if code == "" {
+		return "Join code required"
+	}
+	invite, _, err := readInviteCode(ctx, nk, code)
+	if err != nil || invite == nil || invite.MatchID != m.matchID || invite.ExpiresAt <= time.Now().Unix() {
+		return "Invalid join code"
+	}
+	return ""
+}
+
+// CreatePrivateMatch - プライベートマッチを作成し、参加コードを発行するRPC
+func CreatePrivateMatch(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return "", err
+	}
+	req := &CreatePrivateMatchRequest{}
+	if payload != "" {
+		if err := json.Unmarshal([]byte(payload), req); err != nil {
+			return "", errInvalidPayload
+		}
+	}
+	if _, ok := victoryConditions[req.Variant]; req.Variant != "" && !ok {
+		return "", runtime.NewError("unknown variant", errCodeInvalidArgument)
+	}
+
+	matchID, err := nk.MatchCreate(ctx, "quoridor_chess", map[string]interface{}{
+		"variant":       req.Variant,
+		"private_owner": userID,
+	})
+	if err != nil {
+		logger.Error("create_private_match: failed to create match: %v", err)
+		return "", runtime.NewError("failed to create match", errCodeInternal)
+	}
+
+	invite, err := storeInviteCode(ctx, nk, matchID, userID)
+	if err != nil {
+		if runtimeErr, ok := err.(*runtime.Error); ok {
+			return "", runtimeErr
+		}
+		logger.Error("create_private_match: failed to store join code: %v", err)
+		return "", runtime.NewError("failed to store join code", errCodeInternal)
+	}
+
+	response, err := json.Marshal(&PrivateMatchResponse{
+		MatchID:   matchID,
+		Code:      invite.Code,
+		ExpiresAt: invite.ExpiresAt,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
+
+// JoinByCode - 参加コードからプライベートマッチのマッチIDを返すRPC
+func JoinByCode(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	if _, err := contextUserID(ctx); err != nil {
+		return "", err
+	}
+	req := &JoinByCodeRequest{}
+	if err := json.Unmarshal([]byte(payload), req); err != nil || req.Code == "" {
+		return "", errInvalidPayload
+	}
+
+	code := normalizeInviteCode(req.Code)
+	invite, _, err := readInviteCode(ctx, nk, code)
+	if err != nil {
+		logger.Error("join_by_code: failed to read join code: %v", err)
+		return "", runtime.NewError("failed to read join code", errCodeInternal)
+	}
+	if invite == nil || invite.ExpiresAt <= time.Now().Unix() {
+		return "", runtime.NewError("join code not found or expired", errCodeNotFound)
+	}
+	if !isMatchJoinable(ctx, nk, invite.MatchID) {
+		return "", runtime.NewError("match is no longer open", errCodeFailedPrecondition)
+	}
+
+	response, err := json.Marshal(&PrivateMatchResponse{
+		MatchID:   invite.MatchID,
+		Code:      code,
+		ExpiresAt: invite.ExpiresAt,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
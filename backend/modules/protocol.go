@@ -0,0 +1,448 @@
+// Quoridor Chess 通信プロトコル定義
+// マッチ内でやり取りするメッセージのペイロードを型として定義し、JSON Schemaとして公開する
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// ProtocolVersion - マッチメッセージのプロトコルバージョン（互換性のない変更で上げる）
+const ProtocolVersion = 1
+
+// メッセージの送信方向
+const (
+	DirectionClientToServer = "client_to_server"
+	DirectionServerToClient = "server_to_client"
+)
+
+// =============================================================================
+// クライアント → サーバー（{"type": ..., 各フィールド} の形式）
+// =============================================================================
+
+// ChatRequest - チャット送信
+type ChatRequest struct {
+	Message string `json:"message"` // 自由入力のメッセージ（キッズセーフモードでは無視される）
+	Emote   string `json:"emote"`   // 定義済みのエモート
+}
+
+// MoveRequest - コマ移動
+type MoveRequest struct {
+	Position Position `json:"position"` // 移動先
+	Confirm  bool     `json:"confirm"`  // トレーニングモードの警告を確認済みかどうか
+}
+
+// PlaceWallRequest - 壁の配置
+type PlaceWallRequest struct {
+	Wall Wall `json:"wall"` // 配置する壁（溝に沿った2マス分）
+}
+
+// SetTrainingModeRequest - トレーニングモードの切り替え
+type SetTrainingModeRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// HeartbeatRequest - 接続確認（放置検出のための入力として扱われる）
+type HeartbeatRequest struct{}
+
+// AdjournRequest - 対局の中断の提案・合意（両プレイヤーが送ると中断する、カジュアル戦のみ）
+type AdjournRequest struct{}
+
+// ResignRequest - 投了（相手の勝ちで対局を終了する）
+type ResignRequest struct{}
+
+// AcceptDrawRequest - 停滞による引き分けの提案への合意（両プレイヤーが送ると引き分け）
+type AcceptDrawRequest struct{}
+
+// =============================================================================
+// サーバー → クライアント（{"type": ..., "data": ペイロード} の形式）
+// =============================================================================
+
+// PlayerJoinedData - プレイヤー参加通知
+type PlayerJoinedData struct {
+	Player    *Player    `json:"player"`
+	GameState *GameState `json:"game_state"`
+}
+
+// PlayerLeftData - プレイヤー退出通知
+type PlayerLeftData struct {
+	PlayerID string `json:"player_id"`
+}
+
+// MatchTerminatedData - マッチ終了通知
+type MatchTerminatedData struct {
+	Reason string `json:"reason"`
+}
+
+// ChatData - チャットメッセージ
+type ChatData struct {
+	SenderID  string `json:"sender_id"`
+	Username  string `json:"username"`
+	Message   string `json:"message"`
+	Emote     string `json:"emote"`
+	Timestamp int64  `json:"timestamp"`
+	Seq       int64  `json:"seq"` // マッチ内のチャットの通し番号（get_match_chat のページングに使う）
+}
+
+// BlunderWarningData - トレーニングモードの悪手警告（本人のみ）
+type BlunderWarningData struct {
+	Position Position `json:"position"`
+	Loss     int      `json:"loss"`
+}
+
+// TrainingModeUpdatedData - トレーニングモード切り替え結果（本人のみ）
+type TrainingModeUpdatedData struct {
+	Enabled bool `json:"enabled"`
+}
+
+// TurnActionData - 1ターンに複数回行動するバリアントで、ターン途中の操作を伝える差分
+// ターンの最後の操作の後は通常どおり game_state_update でゲーム状態全体が送られる
+type TurnActionData struct {
+	Action           *ActionHint `json:"action"`            // 受理した操作
+	ActionsRemaining int         `json:"actions_remaining"` // このターンに残っている操作の数
+}
+
+// PositionSnapshotData - 一定の手数ごとに送る局面全体の正規化表現
+// クライアントは手元の盤面と照合し、食い違っていればこの内容で盤面を置き換える
+type PositionSnapshotData struct {
+	Ply      int    `json:"ply"`      // スナップショット時点の手数（棋譜の長さ）
+	Position string `json:"position"` // 正規化した局面（形式は encodePosition を参照）
+}
+
+// WallsExpiredData - 壁の風化バリアントで消えた壁の通知
+type WallsExpiredData struct {
+	Walls []Wall `json:"walls"`
+}
+
+// ActionBufferedData - 相手のターン中に送った操作を保留したことの通知（本人のみ）
+type ActionBufferedData struct {
+	Action string `json:"action"` // 保留した操作（"move" / "place_wall"）
+}
+
+// BufferedActionInvalidatedData - 保留していた操作が手番の開始時に適用できなかったことの通知（本人のみ）
+type BufferedActionInvalidatedData struct {
+	Action string `json:"action"`
+	Reason string `json:"reason"`
+}
+
+// GameOverData - 対局終了の通知（投了か停滞による引き分けで終わった場合に送られる）
+type GameOverData struct {
+	WinnerID  string            `json:"winner_id"`            // 勝者（引き分けの場合は空）
+	Reason    string            `json:"reason"`               // 決着の理由
+	GameState *GameState        `json:"game_state"`           // 終了時のゲーム状態
+	StaleDraw *StaleDrawDetails `json:"stale_draw,omitempty"` // 停滞の検出内容（停滞による引き分けのみ）
+}
+
+// StaleDrawWithdrawnData - 停滞による引き分けの提案を、その後の進展により取り下げたことの通知
+type StaleDrawWithdrawnData struct{}
+
+// MatchCancelledData - 対局開始前にマッチが取り消されたことの通知
+type MatchCancelledData struct {
+	Reason string `json:"reason"` // 取り消しの理由（"opponent_did_not_join"）
+}
+
+// PlayerDisconnectedData - 対局中に相手が切断したことの通知
+type PlayerDisconnectedData struct {
+	PlayerID     string `json:"player_id"`
+	GraceSeconds int    `json:"grace_seconds"` // 再接続を待つ秒数（過ぎると切断による負け）
+}
+
+// PlayerReconnectedData - 切断していた相手が再接続したことの通知
+type PlayerReconnectedData struct {
+	PlayerID string `json:"player_id"`
+}
+
+// ClocksPausedData - 多数のプレイヤーの一斉切断により、持ち時間と負けの判定を一時停止したことの通知
+type ClocksPausedData struct {
+	Reason   string `json:"reason"`    // 一時停止の理由（"disconnect_storm"）
+	ResumeAt int64  `json:"resume_at"` // 再開予定時刻（Unixミリ秒、切断が続けば延びる）
+}
+
+// ClocksResumedData - 一時停止していた持ち時間と負けの判定を再開したことの通知
+type ClocksResumedData struct {
+	PausedMs int64 `json:"paused_ms"` // 一時停止していた時間（ミリ秒）
+}
+
+// TurnTimeoutData - 1ターンの制限時間切れで対局が終わったことの通知
+type TurnTimeoutData struct {
+	PlayerID string `json:"player_id"` // 制限時間を過ぎたプレイヤー
+	WinnerID string `json:"winner_id"` // 勝者
+}
+
+// GameStartedData - 対局開始の通知
+// ゲーム状態のフィールドに加えて、確定したルールと席の割り当てを含め、クライアントが既定値からルールを推測しなくて済むようにする
+type GameStartedData struct {
+	*GameState
+	Ruleset         *Ruleset          `json:"ruleset"`          // 適用するルール（盤の大きさ、持ち時間、バリアント、特殊ルール）
+	Seats           []*SeatAssignment `json:"seats"`            // 席の割り当て（先手から順）
+	Clocks          map[string]*Clock `json:"clocks,omitempty"` // 対局開始時の持ち時間（時間制限のある対局のみ）
+	ProtocolVersion int               `json:"protocol_version"` // メッセージのプロトコルバージョン
+	Capabilities    []string          `json:"capabilities"`     // このマッチで有効な機能
+}
+
+// SeatAssignment - 対局者の席の割り当て
+type SeatAssignment struct {
+	PlayerID   string      `json:"player_id"`
+	Color      string      `json:"color"`           // "white" または "black"
+	Start      *Position   `json:"start"`           // 開始位置
+	GoalRow    int         `json:"goal_row"`        // ゴール行
+	Walls      int         `json:"walls"`           // 壁の初期数
+	MovesFirst bool        `json:"moves_first"`     // 先手かどうか
+	Tier       *PlayerTier `json:"tier,omitempty"`  // 今シーズンのティア（配置戦を終えている場合のみ）
+	Flair      *Flair      `json:"flair,omitempty"` // 名前の横に表示する称号（選んでいる場合のみ）
+}
+
+// ActionRejectedData - 操作の拒否通知（本人のみ）
+type ActionRejectedData struct {
+	Action string `json:"action"`
+	Reason string `json:"reason"`
+}
+
+// HeartbeatAckData - 接続確認への応答（本人のみ）
+type HeartbeatAckData struct {
+	Timestamp int64 `json:"timestamp"`
+}
+
+// AfkWarningData - 放置警告（手番のプレイヤー本人のみ）
+type AfkWarningData struct {
+	SecondsRemaining int `json:"seconds_remaining"` // 負けになるまでの残り秒数
+}
+
+// OpponentAfkData - 相手が放置していることの通知
+type OpponentAfkData struct {
+	PlayerID         string `json:"player_id"`
+	SecondsRemaining int    `json:"seconds_remaining"`
+}
+
+// PlayTimeWarningData - 対局中の利用時間の警告（本人のみ）
+type PlayTimeWarningData struct {
+	SecondsRemaining int64 `json:"seconds_remaining"` // 上限までの残り秒数（0 は超過、対局は最後まで続けられる）
+}
+
+// AdjournOfferedData - 相手からの中断の提案（同意する場合は adjourn を送る）
+type AdjournOfferedData struct {
+	PlayerID string `json:"player_id"`
+}
+
+// GameAdjournedData - 対局の中断通知（game_id を resume_adjourned RPC に渡すと再開できる）
+type GameAdjournedData struct {
+	GameID string `json:"game_id"`
+}
+
+// TutorialTipData - 始めたばかりのプレイヤー向けのヒント（本人のみ、各ヒント1局に1回まで）
+type TutorialTipData struct {
+	Tip     string         `json:"tip"`              // ヒントの種類（クライアントでの翻訳キー）
+	Message string         `json:"message"`          // 既定の英語メッセージ
+	Params  map[string]int `json:"params,omitempty"` // メッセージに埋め込む値
+}
+
+// ServerInfoData - 参加時にクライアントへ送るサーバー情報（本人のみ）
+type ServerInfoData struct {
+	ModuleVersion   string   `json:"module_version"`   // Goモジュールのバージョン
+	ProtocolVersion int      `json:"protocol_version"` // メッセージのプロトコルバージョン
+	Features        []string `json:"features"`         // このマッチで有効な機能
+	Ruleset         *Ruleset `json:"ruleset"`          // 適用中のルール
+	RatingSystem    string   `json:"rating_system"`    // 表示に使うレーティング方式（"elo" / "glicko2"）
+}
+
+// PositionCorrectedData - 管理者による盤面訂正の通知（受信したら盤面を置き換える）
+type PositionCorrectedData struct {
+	Reason    string     `json:"reason"`
+	GameState *GameState `json:"game_state"`
+}
+
+// PlaytestCommandData - プレイテスト用コマンドで局面が書き換えられたことの通知
+type PlaytestCommandData struct {
+	Command   string     `json:"command"`
+	PlayerID  string     `json:"player_id"`
+	GameState *GameState `json:"game_state"`
+}
+
+// protocolMessage - プロトコルに含まれるメッセージの定義
+type protocolMessage struct {
+	Type      string
+	OpCode    int64
+	Direction string
+	Payload   interface{}
+}
+
+// protocolMessages - 現在のプロトコルバージョンで使われる全メッセージ
+// クライアントからのメッセージはオペコードに関係なく type で処理される（慣例として 2: チャット、3: ゲーム操作）
+var protocolMessages = []protocolMessage{
+	{Type: "chat", OpCode: 2, Direction: DirectionClientToServer, Payload: ChatRequest{}},
+	{Type: "move", OpCode: 3, Direction: DirectionClientToServer, Payload: MoveRequest{}},
+	{Type: "place_wall", OpCode: 3, Direction: DirectionClientToServer, Payload: PlaceWallRequest{}},
+	{Type: "set_training_mode", OpCode: 3, Direction: DirectionClientToServer, Payload: SetTrainingModeRequest{}},
+	{Type: "heartbeat", OpCode: 1, Direction: DirectionClientToServer, Payload: HeartbeatRequest{}},
+	{Type: "adjourn", OpCode: 3, Direction: DirectionClientToServer, Payload: AdjournRequest{}},
+	{Type: "resign", OpCode: 3, Direction: DirectionClientToServer, Payload: ResignRequest{}},
+	{Type: "accept_draw", OpCode: 3, Direction: DirectionClientToServer, Payload: AcceptDrawRequest{}},
+	{Type: "replay_play", OpCode: 3, Direction: DirectionClientToServer, Payload: ReplayPlayRequest{}},
+	{Type: "replay_pause", OpCode: 3, Direction: DirectionClientToServer, Payload: ReplayPauseRequest{}},
+	{Type: "replay_seek", OpCode: 3, Direction: DirectionClientToServer, Payload: ReplaySeekRequest{}},
+	{Type: "replay_set_speed", OpCode: 3, Direction: DirectionClientToServer, Payload: ReplaySetSpeedRequest{}},
+
+	{Type: "player_joined", OpCode: 1, Direction: DirectionServerToClient, Payload: PlayerJoinedData{}},
+	{Type: "game_started", OpCode: 1, Direction: DirectionServerToClient, Payload: GameStartedData{}},
+	{Type: "player_left", OpCode: 1, Direction: DirectionServerToClient, Payload: PlayerLeftData{}},
+	{Type: "game_state_update", OpCode: 1, Direction: DirectionServerToClient, Payload: GameState{}},
+	{Type: "match_terminated", OpCode: 1, Direction: DirectionServerToClient, Payload: MatchTerminatedData{}},
+	{Type: "blunder_warning", OpCode: 1, Direction: DirectionServerToClient, Payload: BlunderWarningData{}},
+	{Type: "training_mode_updated", OpCode: 1, Direction: DirectionServerToClient, Payload: TrainingModeUpdatedData{}},
+	{Type: "game_over", OpCode: 1, Direction: DirectionServerToClient, Payload: GameOverData{}},
+	{Type: "stale_draw_offered", OpCode: 1, Direction: DirectionServerToClient, Payload: StaleDrawDetails{}},
+	{Type: "stale_draw_withdrawn", OpCode: 1, Direction: DirectionServerToClient, Payload: StaleDrawWithdrawnData{}},
+	{Type: "match_cancelled", OpCode: 1, Direction: DirectionServerToClient, Payload: MatchCancelledData{}},
+	{Type: "player_disconnected", OpCode: 1, Direction: DirectionServerToClient, Payload: PlayerDisconnectedData{}},
+	{Type: "player_reconnected", OpCode: 1, Direction: DirectionServerToClient, Payload: PlayerReconnectedData{}},
+	{Type: "spectator_count", OpCode: 1, Direction: DirectionServerToClient, Payload: SpectatorCountData{}},
+	{Type: "state_delta", OpCode: 1, Direction: DirectionServerToClient, Payload: StateDeltaData{}},
+	{Type: "bandwidth_mode", OpCode: 1, Direction: DirectionServerToClient, Payload: BandwidthModeData{}},
+	{Type: "clocks_paused", OpCode: 1, Direction: DirectionServerToClient, Payload: ClocksPausedData{}},
+	{Type: "clocks_resumed", OpCode: 1, Direction: DirectionServerToClient, Payload: ClocksResumedData{}},
+	{Type: "turn_timeout", OpCode: 1, Direction: DirectionServerToClient, Payload: TurnTimeoutData{}},
+	{Type: "action_rejected", OpCode: 1, Direction: DirectionServerToClient, Payload: ActionRejectedData{}},
+	{Type: "turn_action", OpCode: 1, Direction: DirectionServerToClient, Payload: TurnActionData{}},
+	{Type: "position_snapshot", OpCode: 1, Direction: DirectionServerToClient, Payload: PositionSnapshotData{}},
+	{Type: "walls_expired", OpCode: 1, Direction: DirectionServerToClient, Payload: WallsExpiredData{}},
+	{Type: "action_buffered", OpCode: 1, Direction: DirectionServerToClient, Payload: ActionBufferedData{}},
+	{Type: "buffered_action_invalidated", OpCode: 1, Direction: DirectionServerToClient, Payload: BufferedActionInvalidatedData{}},
+	{Type: "heartbeat_ack", OpCode: 1, Direction: DirectionServerToClient, Payload: HeartbeatAckData{}},
+	{Type: "afk_warning", OpCode: 1, Direction: DirectionServerToClient, Payload: AfkWarningData{}},
+	{Type: "opponent_afk", OpCode: 1, Direction: DirectionServerToClient, Payload: OpponentAfkData{}},
+	{Type: "state_resync", OpCode: 1, Direction: DirectionServerToClient, Payload: GameState{}},
+	{Type: "play_time_warning", OpCode: 1, Direction: DirectionServerToClient, Payload: PlayTimeWarningData{}},
+	{Type: "adjourn_offered", OpCode: 1, Direction: DirectionServerToClient, Payload: AdjournOfferedData{}},
+	{Type: "game_adjourned", OpCode: 1, Direction: DirectionServerToClient, Payload: GameAdjournedData{}},
+	{Type: "tutorial_tip", OpCode: 1, Direction: DirectionServerToClient, Payload: TutorialTipData{}},
+	{Type: "server_info", OpCode: 1, Direction: DirectionServerToClient, Payload: ServerInfoData{}},
+	{Type: "playtest_command", OpCode: 1, Direction: DirectionServerToClient, Payload: PlaytestCommandData{}},
+	{Type: "position_corrected", OpCode: 1, Direction: DirectionServerToClient, Payload: PositionCorrectedData{}},
+	{Type: "replay_loaded", OpCode: 1, Direction: DirectionServerToClient, Payload: ReplayLoadedData{}},
+	{Type: "replay_position", OpCode: 1, Direction: DirectionServerToClient, Payload: ReplayPositionData{}},
+	{Type: "replay_move", OpCode: 1, Direction: DirectionServerToClient, Payload: MoveLogEntry{}},
+	{Type: "replay_state", OpCode: 1, Direction: DirectionServerToClient, Payload: ReplayStateData{}},
+	{Type: "chat", OpCode: 2, Direction: DirectionServerToClient, Payload: ChatData{}},
+}
+
+// ProtocolSchema - get_protocol_schema RPCのレスポンス
+type ProtocolSchema struct {
+	ProtocolVersion int                     `json:"protocol_version"`
+	Messages        []ProtocolMessageSchema `json:"messages"`
+}
+
+// ProtocolMessageSchema - メッセージ1種類分のスキーマ
+type ProtocolMessageSchema struct {
+	Type      string                 `json:"type"`
+	OpCode    int64                  `json:"op_code"`
+	Direction string                 `json:"direction"`
+	Schema    map[string]interface{} `json:"schema"` // メッセージ全体のJSON Schema
+}
+
+// jsonSchemaFor - Goの型からJSON Schemaを生成（json タグのフィールド名を使用）
+func jsonSchemaFor(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": jsonSchemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": jsonSchemaFor(t.Elem())}
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" && !field.Anonymous {
+				continue // 非公開フィールドはシリアライズされない
+			}
+			// タグのない埋め込み構造体はフィールドが同じ階層に展開される
+			if field.Anonymous && field.Tag.Get("json") == "" {
+				embedded, _ := jsonSchemaFor(field.Type)["properties"].(map[string]interface{})
+				for name, schema := range embedded {
+					properties[name] = schema
+				}
+				continue
+			}
+			name := field.Name
+			if tag := field.Tag.Get("json"); tag != "" {
+				name = strings.Split(tag, ",")[0]
+				if name == "-" {
+					continue
+				}
+			}
+			properties[name] = jsonSchemaFor(field.Type)
+		}
+		return map[string]interface{}{"type": "object", "properties": properties}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// messageSchema - メッセージ全体（type と本体）のJSON Schemaを生成
+func messageSchema(message protocolMessage) map[string]interface{} {
+	typeSchema := map[string]interface{}{"const": message.Type}
+	payload := jsonSchemaFor(reflect.TypeOf(message.Payload))
+
+	if message.Direction == DirectionServerToClient {
+		return map[string]interface{}{
+			"$schema":  "https://json-schema.org/draft/2020-12/schema",
+			"type":     "object",
+			"required": []string{"type", "data"},
+			"properties": map[string]interface{}{
+				"type": typeSchema,
+				"data": payload,
+			},
+		}
+	}
+
+	// クライアントからのメッセージはペイロードのフィールドが type と同じ階層に並ぶ
+	properties, _ := payload["properties"].(map[string]interface{})
+	flattened := map[string]interface{}{"type": typeSchema}
+	for name, schema := range properties {
+		flattened[name] = schema
+	}
+	return map[string]interface{}{
+		"$schema":    "https://json-schema.org/draft/2020-12/schema",
+		"type":       "object",
+		"required":   []string{"type"},
+		"properties": flattened,
+	}
+}
+
+// GetProtocolSchema - 全マッチメッセージのJSON Schemaを返すRPC
+func GetProtocolSchema(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	schema := &ProtocolSchema{
+		ProtocolVersion: ProtocolVersion,
+		Messages:        make([]ProtocolMessageSchema, 0, len(protocolMessages)),
+	}
+	for _, message := range protocolMessages {
+		schema.Messages = append(schema.Messages, ProtocolMessageSchema{
+			Type:      message.Type,
+			OpCode:    message.OpCode,
+			Direction: message.Direction,
+			Schema:    messageSchema(message),
+		})
+	}
+
+	response, err := json.Marshal(schema)
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
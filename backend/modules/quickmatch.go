@@ -0,0 +1,159 @@
+// Quoridor Chess クイックマッチ
+// 参加待ちのマッチを探して返し、なければサーバー側で作成する
+// 同時に呼び出した2人が別々に空のマッチを作らないよう、参加待ちのマッチはストレージの1か所で楽観的排他制御する
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	quickMatchCollection = "quick_match" // クイックマッチのストレージコレクション（システムが所有）
+	quickMatchKey        = "waiting"     // 参加待ちのマッチを記録するキー
+	quickMatchRetries    = 5             // 同時に更新された場合の再試行回数
+	quickMatchSearchSize = 10            // 手動で作成された参加待ちのマッチを探す件数
+)
+
+// quickMatchSlot - クイックマッチで参加待ちになっているマッチ
+type quickMatchSlot struct {
+	MatchID   string `json:"match_id"`   // 参加待ちのマッチID
+	UserID    string `json:"user_id"`    // マッチを作成して待っているユーザーID
+	CreatedAt int64  `json:"created_at"` // 作成時刻（Unix時刻）
+}
+
+// readQuickMatchSlot - 参加待ちのマッチを読み込む（存在しない場合は nil）
+func readQuickMatchSlot(ctx context.Context, nk runtime.NakamaModule) (*quickMatchSlot, string, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: quickMatchCollection,
+		Key:        quickMatchKey,
+	}})
+	if err != nil || len(objects) == 0 {
+		return nil, "", err
+	}
+	slot := &quickMatchSlot{}
+	if err := json.Unmarshal([]byte(objects[0].GetValue()), slot); err != nil {
+		return nil, objects[0].GetVersion(), nil
+	}
+	return slot, objects[0].GetVersion(), nil
+}
+
+// findListedMatch - クイックマッチ以外で作成された、相手を待っているカジュアル戦のマッチを探す
+func findListedMatch(ctx context.Context, nk runtime.NakamaModule, userID string) (string, error) {
+	minSize := 1
+	maxSize := MaxPlayers - 1
+	query := "+label.open:true +label.mode:" + MatchModeCasual + " -label.anonymous:true -label.private:true"
+	matches, err := nk.MatchList(ctx, quickMatchSearchSize, true, "", &minSize, &maxSize, query)
+	if err != nil {
+		return "", err
+	}
+	for _, match := range matches {
+		// 自分が作ったマッチには相手として参加しない（マッチIDは "<UUID>.<ノード名>" の形式）
+		parts := strings.SplitN(match.GetMatchId(), ".", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		presences, err := nk.StreamUserList(streamModeMatchAuthoritative, parts[0], "", parts[1], true, true)
+		if err != nil {
+			continue
+		}
+		own := false
+		for _, presence := range presences {
+			if presence.GetUserId() == userID {
+				own = true
+			}
+		}
+		if !own {
+			return match.GetMatchId(), nil
+		}
+	}
+	return "", nil
+}
+
+// QuickMatch - 参加待ちのマッチに参加するか、なければ新しく作成してマッチIDを返すRPC
+func QuickMatch(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	for attempt := 0; attempt < quickMatchRetries; attempt++ {
+		slot, version, err := readQuickMatchSlot(ctx, nk)
+		if err != nil {
+			logger.Error("quick_match: failed to read waiting match: %v", err)
+			return "", runtime.NewError("failed to read waiting match", errCodeInternal)
+		}
+
+		// 参加待ちのマッチがあれば、記録を消して自分が相手になる
+		if slot != nil && slot.MatchID != "" && isMatchJoinable(ctx, nk, slot.MatchID) {
+			if slot.UserID == userID {
+				return quickMatchResponse(slot.MatchID, false)
+			}
+			if err := nk.StorageDelete(ctx, []*runtime.StorageDelete{{
+				Collection: quickMatchCollection,
+				Key:        quickMatchKey,
+				Version:    version,
+			}}); err != nil {
+				continue // 他の人が先に参加した
+			}
+			return quickMatchResponse(slot.MatchID, false)
+		}
+
+		// 手動で作成されたマッチで相手を待っているものがあれば参加する
+		matchID, err := findListedMatch(ctx, nk, userID)
+		if err != nil {
+			logger.Error("quick_match: failed to list matches: %v", err)
+			return "", runtime.NewError("failed to list matches", errCodeInternal)
+		}
+		if matchID != "" {
+			return quickMatchResponse(matchID, false)
+		}
+
+		// 見つからなければマッチを作成して参加待ちとして記録する
+		matchID, err = nk.MatchCreate(ctx, "quoridor_chess", map[string]interface{}{})
+		if err != nil {
+			logger.Error("quick_match: failed to create match: %v", err)
+			return "", runtime.NewError("failed to create match", errCodeInternal)
+		}
+		value, err := json.Marshal(&quickMatchSlot{MatchID: matchID, UserID: userID, CreatedAt: time.Now().Unix()})
+		if err != nil {
+			return "", err
+		}
+		if version == "" {
+			version = "*"
+		}
+		if _, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+			Collection:      quickMatchCollection,
+			Key:             quickMatchKey,
+			Value:           string(value),
+			Version:         version,
+			PermissionRead:  0,
+			PermissionWrite: 0,
+		}}); err != nil {
+			// 他の人が同時にマッチを作成した場合は、作ったマッチを閉じてそちらに参加し直す
+			if _, err := nk.MatchSignal(ctx, matchID, `{"type":"close_if_empty"}`); err != nil {
+				logger.Warn("quick_match: failed to close unused match %s: %v", matchID, err)
+			}
+			continue
+		}
+		return quickMatchResponse(matchID, true)
+	}
+	return "", runtime.NewError("too many concurrent quick match requests", errCodeResourceExhausted)
+}
+
+// quickMatchResponse - quick_match RPCのレスポンスを作成
+func quickMatchResponse(matchID string, created bool) (string, error) {
+	response, err := json.Marshal(map[string]interface{}{
+		"match_id": matchID,
+		"created":  created,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
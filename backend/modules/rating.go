@@ -0,0 +1,360 @@
+// Quoridor Chess レーティング
+// レーティング対象の対局の終了時に両者のレーティングを計算してストレージに保存し、終了時のゲーム状態で知らせる
+// EloとGlicko-2の両方を常に計算して保存し、クライアントにはデプロイ設定で選んだ方を表示させる
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// レーティング方式（runtime.env の rating_system）
+const (
+	RatingSystemElo     = "elo"     // Elo（既定）
+	RatingSystemGlicko2 = "glicko2" // Glicko-2
+)
+
+const (
+	ratingCollection = "ratings" // レーティングのストレージコレクション（システムが所有、キーはユーザーID）
+	initialRating    = 1200      // 初めてレーティング戦を指すプレイヤーのレーティング
+	ratingK          = 32        // 1局で動くレーティングの大きさ
+)
+
+// PlayerRating - 保存するプレイヤーのレーティング
+type PlayerRating struct {
+	Rating          int           `json:"rating"`           // 現在のレーティング
+	Peak            int           `json:"peak"`             // これまでの最高レーティング
+	GamesPlayed     int           `json:"games_played"`     // レーティング戦の対局数
+	UpdatedAt       int64         `json:"updated_at"`       // 最後に変動した時刻（Unix時刻）
+	Glicko          *GlickoRating `json:"glicko"`           // Glicko-2のレーティング
+	Season          string        `json:"season"`           // 配置戦を数えているシーズン
+	PlacementPlayed int           `json:"placement_played"` // 今シーズンに指した配置戦の数
+	Tier            *PlayerTier   `json:"tier,omitempty"`   // 今シーズンのティア（配置戦を終えるまでは nil）
+}
+
+// RatingChange - 対局によるレーティングの変動
+type RatingChange struct {
+	Before    int                `json:"before"`               // 対局前のレーティング
+	After     int                `json:"after"`                // 対局後のレーティング
+	Delta     int                `json:"delta"`                // 変動量
+	Frozen    bool               `json:"frozen"`               // 審査中のため変動させなかったかどうか
+	Glicko    *GlickoChange      `json:"glicko"`               // Glicko-2のレーティングの変動
+	Placement *PlacementProgress `json:"placement,omitempty"`  // 配置戦の進み具合（配置戦として計算した場合のみ）
+	Tier      *PlayerTier        `json:"tier,omitempty"`       // 対局後のティア（配置戦を終えている場合のみ）
+	TierEvent string             `json:"tier_event,omitempty"` // 対局で起きたティアの変化（昇格・降格など）
+	// 対局前と対局後のレーティング全体（異議申し立てで取り消し・反映し直すときにそのまま書き戻す）
+	Previous *PlayerRating `json:"previous,omitempty"`
+	Updated  *PlayerRating `json:"updated,omitempty"`
+}
+
+// ratingWriteRetries - 両者のレーティングの書き込みが同時更新と衝突したときの再試行回数
+const ratingWriteRetries = 3
+
+// matchRatingChangeCollection - 対局ごとのレーティングの変動のストレージコレクション（システムが所有、キーはマッチID）
+const matchRatingChangeCollection = "match_rating_changes"
+
+// 対局によるレーティングの変動の状態
+const (
+	MatchRatingsApplied  = "applied"  // 反映している（対局の終了時、または申し立てが退けられた後）
+	MatchRatingsHeld     = "held"     // 異議申し立ての審査中のため取り消して保留している
+	MatchRatingsReverted = "reverted" // 申し立てが認められたため取り消した
+)
+
+// MatchRatingChanges - 対局によるレーティングの変動の記録（異議申し立てを受けたときの取り消しと、解決時の反映に使う）
+type MatchRatingChanges struct {
+	MatchID string                   `json:"match_id"`
+	Status  string                   `json:"status"`  // 変動の状態
+	Changes map[string]*RatingChange `json:"changes"` // プレイヤーごとの変動
+}
+
+// GlickoChange - 対局によるGlicko-2のレーティングの変動
+type GlickoChange struct {
+	Before    float64 `json:"before"`    // 対局前のレーティング
+	After     float64 `json:"after"`     // 対局後のレーティング
+	Deviation float64 `json:"deviation"` // 対局後のレーティング偏差
+}
+
+// GetRatingRequest - get_rating RPCのリクエスト
+type GetRatingRequest struct {
+	UserID string `json:"user_id"` // 対象ユーザー（空の場合は自分）
+}
+
+// RatingResponse - get_rating RPCのレスポンス
+type RatingResponse struct {
+	UserID    string             `json:"user_id"`
+	System    string             `json:"system"` // 表示に使うレーティング方式（"elo" / "glicko2"）
+	Rating    *PlayerRating      `json:"rating"`
+	Placement *PlacementProgress `json:"placement"` // 今シーズンの配置戦の進み具合
+}
+
+// ratingSystem - 表示に使うレーティング方式を返す（誤った値の場合は Elo）
+func ratingSystem(ctx context.Context) string {
+	if envValue(ctx, EnvRatingSystem, RatingSystemElo) == RatingSystemGlicko2 {
+		return RatingSystemGlicko2
+	}
+	return RatingSystemElo
+}
+
+// readRating - プレイヤーのレーティングを読み込む（未作成の場合は初期値）
+func readRating(ctx context.Context, nk runtime.NakamaModule, userID string) (*PlayerRating, string, error) {
+	rating := &PlayerRating{Rating: initialRating, Peak: initialRating, Glicko: newGlickoRating()}
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: ratingCollection,
+		Key:        userID,
+	}})
+	if err != nil {
+		return nil, "", err
+	}
+	if len(objects) == 0 {
+		return rating, "", nil
+	}
+	if err := json.Unmarshal([]byte(objects[0].GetValue()), rating); err != nil {
+		return nil, "", err
+	}
+	// Glicko-2を導入する前に保存したレーティングは初期値から始める
+	if rating.Glicko == nil {
+		rating.Glicko = newGlickoRating()
+	}
+	return rating, objects[0].GetVersion(), nil
+}
+
+// snapshot - ティアやGlicko-2の値も含めたレーティングの複製を返す（元のレーティングを変更しても影響しない）
+func (r *PlayerRating) snapshot() *PlayerRating {
+	copied := *r
+	if r.Glicko != nil {
+		glicko := *r.Glicko
+		copied.Glicko = &glicko
+	}
+	if r.Tier != nil {
+		tier := *r.Tier
+		if r.Tier.Series != nil {
+			series := *r.Tier.Series
+			tier.Series = &series
+		}
+		copied.Tier = &tier
+	}
+	return &copied
+}
+
+// expectedScore - レーティング差から期待される得点（0〜1）を返す
+func expectedScore(rating, opponent int) float64 {
+	return 1 / (1 + math.Pow(10, float64(opponent-rating)/400))
+}
+
+// eloDelta - 得点（勝ち 1、引き分け 0.5、負け 0）からレーティングの変動量を返す
+// k は1局で動く大きさ。同じ相手との対局を繰り返している場合は gainFactor で上昇分を減らす
+func eloDelta(rating, opponent, k int, score, gainFactor float64) int {
+	delta := float64(k) * (score - expectedScore(rating, opponent))
+	if delta > 0 {
+		delta *= gainFactor
+	}
+	return int(math.Round(delta))
+}
+
+// isRatingFrozen - 審査フラグによりレーティングの変動が止められているかどうかを返す
+func isRatingFrozen(ctx context.Context, nk runtime.NakamaModule, userID string, now time.Time) (bool, error) {
+	for _, kind := range []string{reviewFlagSandbagging, reviewFlagCollusion} {
+		flag, _, err := readReviewFlag(ctx, nk, userID, kind)
+		if err != nil {
+			return false, err
+		}
+		if flag.RatingFrozenUntil > now.Unix() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// updateRatings - レーティング対象の対局の結果から両者のレーティングを更新する
+// 変動は m.gameState.Ratings に記録し、終了時のゲーム状態と一緒に全員へ送られる
+func (m *QuoridorChessMatch) updateRatings(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule) {
+	// サーバーが打ち切った対局はレーティングに反映しない
+	if !m.gameState.Ranked || len(m.gameState.Players) != MaxPlayers || m.gameState.ResultReason == ResultReasonAborted {
+		return
+	}
+	playerIDs := make([]string, 0, MaxPlayers)
+	for id := range m.gameState.Players {
+		playerIDs = append(playerIDs, id)
+	}
+
+	history, _, err := readPairHistory(ctx, nk, pairKey(playerIDs[0], playerIDs[1]))
+	if err != nil {
+		logger.Error("Failed to read pair history for ratings: %v", err)
+		return
+	}
+
+	// 別の対局が同時に同じプレイヤーのレーティングを書き換えたときは、読み直して計算し直す
+	for attempt := 0; attempt < ratingWriteRetries; attempt++ {
+		changes, writes, err := m.buildRatingWrites(ctx, nk, playerIDs, history)
+		if err != nil {
+			logger.Error("Failed to compute ratings for match %s: %v", m.matchID, err)
+			return
+		}
+		// 両者の更新はまとめて書き込み、片方だけが変動することのないようにする
+		if len(writes) > 0 {
+			if _, err := nk.StorageWrite(ctx, writes); err != nil {
+				logger.Warn("Failed to store ratings for match %s (attempt %d): %v", m.matchID, attempt+1, err)
+				continue
+			}
+		}
+		m.gameState.Ratings = changes
+		m.notifyTierChanges(ctx, logger, nk, changes)
+		return
+	}
+	logger.Error("Gave up storing ratings for match %s after %d attempts", m.matchID, ratingWriteRetries)
+}
+
+// buildRatingWrites - 両者の現在のレーティングを読み直し、対局結果を反映した変動と書き込みを組み立てる
+func (m *QuoridorChessMatch) buildRatingWrites(ctx context.Context, nk runtime.NakamaModule, playerIDs []string, history *PairHistory) (map[string]*RatingChange, []*runtime.StorageWrite, error) {
+	ratings := make(map[string]*PlayerRating, MaxPlayers)
+	versions := make(map[string]string, MaxPlayers)
+	for _, id := range playerIDs {
+		rating, version, err := readRating(ctx, nk, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		ratings[id], versions[id] = rating, version
+	}
+
+	now := time.Now()
+	changes := make(map[string]*RatingChange, MaxPlayers)
+	writes := make([]*runtime.StorageWrite, 0, MaxPlayers+1)
+	for _, id := range playerIDs {
+		rating := ratings[id]
+		rating.startSeason(now)
+		change := &RatingChange{
+			Before: rating.Rating,
+			After:  rating.Rating,
+			Glicko: &GlickoChange{
+				Before:    rating.Glicko.Rating,
+				After:     rating.Glicko.Rating,
+				Deviation: rating.Glicko.currentDeviation(now),
+			},
+		}
+		changes[id] = change
+
+		frozen, err := isRatingFrozen(ctx, nk, id, now)
+		if err != nil {
+			return nil, nil, err
+		}
+		if frozen {
+			change.Frozen = true
+			continue
+		}
+
+		change.Previous = rating.snapshot()
+
+		score := 0.0
+		if id == m.gameState.Winner {
+			score = 1
+		} else if m.gameState.Winner == "" {
+			score = 0.5 // 引き分け
+		}
+		opponent := ratings[m.opponentOf(id)]
+		// 配置戦は通常より大きく動かして早く実力に近づける
+		k := ratingK
+		placement := rating.inPlacement(now)
+		if placement {
+			k = placementK
+		}
+		change.Delta = eloDelta(rating.Rating, opponent.Rating, k, score, history.RatingGainFactor)
+		change.After = rating.Rating + change.Delta
+
+		glicko := glickoUpdate(rating.Glicko, opponent.Glicko, score, now)
+		if glicko.Rating > rating.Glicko.Rating {
+			glicko.Rating = rating.Glicko.Rating + (glicko.Rating-rating.Glicko.Rating)*history.RatingGainFactor
+		}
+		change.Glicko.After = glicko.Rating
+		change.Glicko.Deviation = glicko.Deviation
+
+		updated := *rating
+		updated.Rating = change.After
+		if updated.Rating > updated.Peak {
+			updated.Peak = updated.Rating
+		}
+		updated.Glicko = glicko
+		updated.GamesPlayed++
+		if placement {
+			updated.PlacementPlayed++
+			change.Placement = updated.placementProgress(now)
+		}
+		change.TierEvent = updated.updateTier(m.ratingSystem, score, now)
+		change.Tier = updated.Tier
+		updated.UpdatedAt = now.Unix()
+		change.Updated = updated.snapshot()
+		value, err := json.Marshal(&updated)
+		if err != nil {
+			return nil, nil, err
+		}
+		version := versions[id]
+		if version == "" {
+			version = "*"
+		}
+		writes = append(writes, &runtime.StorageWrite{
+			Collection:      ratingCollection,
+			Key:             id,
+			Value:           string(value),
+			Version:         version,
+			PermissionRead:  2,
+			PermissionWrite: 0,
+		})
+	}
+
+	// 異議申し立てを受けたときに取り消し・反映し直せるよう、対局ごとの変動を同じ書き込みで記録する
+	record := &MatchRatingChanges{MatchID: m.matchID, Status: MatchRatingsApplied, Changes: changes}
+	if value, err := json.Marshal(record); err == nil {
+		writes = append(writes, &runtime.StorageWrite{
+			Collection:      matchRatingChangeCollection,
+			Key:             m.matchID,
+			Value:           string(value),
+			PermissionRead:  0,
+			PermissionWrite: 0,
+		})
+	}
+
+	return changes, writes, nil
+}
+
+// GetRating - プレイヤーのレーティングを返すRPC（プロフィール画面用）
+func GetRating(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	req := &GetRatingRequest{}
+	if payload != "" {
+		if err := json.Unmarshal([]byte(payload), req); err != nil {
+			return "", errInvalidPayload
+		}
+	}
+	if req.UserID == "" {
+		userID, err := contextUserID(ctx)
+		if err != nil {
+			return "", err
+		}
+		req.UserID = userID
+	}
+
+	rating, _, err := readRating(ctx, nk, req.UserID)
+	if err != nil {
+		logger.Error("get_rating: failed to read rating: %v", err)
+		return "", runtime.NewError("failed to read rating", errCodeInternal)
+	}
+
+	// しばらく対局していない場合の偏差の広がりを表示に反映する
+	rating.Glicko.Deviation = rating.Glicko.currentDeviation(time.Now())
+	// 前のシーズンのティアは表示しない
+	rating.Tier = rating.currentTier(time.Now())
+
+	response, err := json.Marshal(&RatingResponse{
+		UserID:    req.UserID,
+		System:    ratingSystem(ctx),
+		Rating:    rating,
+		Placement: rating.placementProgress(time.Now()),
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
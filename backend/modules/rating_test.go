@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestEloDelta(t *testing.T) {
+	tests := []struct {
+		name             string
+		rating, opponent int
+		k                int
+		score            float64
+		gainFactor       float64
+		want             int
+	}{
+		{"同じレーティングに勝つ", 1200, 1200, ratingK, 1, 1, 16},
+		{"同じレーティングに負ける", 1200, 1200, ratingK, 0, 1, -16},
+		{"同じレーティングと引き分け", 1200, 1200, ratingK, 0.5, 1, 0},
+		// 期待得点 1 / (1 + 10^(200/400)) = 0.2403
+		{"200高い相手に勝つ", 1200, 1400, ratingK, 1, 1, 24},
+		{"200低い相手に負ける", 1400, 1200, ratingK, 0, 1, -24},
+		{"200高い相手と引き分け", 1200, 1400, ratingK, 0.5, 1, 8},
+		{"配置戦の係数", 1200, 1200, placementK, 1, 1, placementK / 2},
+		{"同じ相手との連戦で上昇分を減らす", 1200, 1200, ratingK, 1, 0.5, 8},
+		{"連戦でも下降分は減らさない", 1200, 1200, ratingK, 0, 0.5, -16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := eloDelta(tt.rating, tt.opponent, tt.k, tt.score, tt.gainFactor); got != tt.want {
+				t.Errorf("eloDelta = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
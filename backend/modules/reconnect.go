@@ -0,0 +1,110 @@
+// Quoridor Chess 再接続
+// 対局中に切断したプレイヤーの席を猶予時間のあいだ残し、再接続したら全体の状態を送り直して対局を続ける
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// markDisconnected - 対局中に切断したプレイヤーを再接続待ちにして相手に知らせる
+func (m *QuoridorChessMatch) markDisconnected(logger runtime.Logger, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, userID string) {
+	player := m.gameState.Players[userID]
+	if player == nil || player.Disconnected {
+		return
+	}
+	player.Disconnected = true
+	now := time.Now()
+	m.disconnectedAt[userID] = now
+	m.openDisconnect(nk, userID, now)
+	noteStormDisconnect(logger, nk, now)
+	m.recordEvent("player_disconnected", EventSourceServer, userID, nil)
+
+	msg := map[string]interface{}{
+		"type": "player_disconnected",
+		"data": &PlayerDisconnectedData{
+			PlayerID:     userID,
+			GraceSeconds: int(m.reconnectGrace.Seconds()),
+		},
+	}
+	m.sendMessage(dispatcher, 1, msg, nil, true)
+}
+
+// reconnectPlayer - 再接続待ちのプレイヤーが戻ってきた場合に席を戻し、本人に全体の状態を送り直す
+// 再接続として処理した場合は true を返す
+func (m *QuoridorChessMatch) reconnectPlayer(nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, presence runtime.Presence) bool {
+	userID := presence.GetUserId()
+	player := m.gameState.Players[userID]
+	if player == nil || !player.Disconnected {
+		return false
+	}
+	player.Disconnected = false
+	delete(m.disconnectedAt, userID)
+	m.closeDisconnect(nk, userID, true)
+	m.recordEvent("player_reconnected", EventSourceServer, userID, nil)
+	// 切断していた間を放置として数えないよう、再接続を入力として扱う
+	m.recordActivity(userID)
+
+	// 本人には切断中に取りこぼした分を含めた全体の状態を送る
+	resyncMsg := map[string]interface{}{
+		"type": "state_resync",
+		"data": m.gameState,
+	}
+	m.sendMessage(dispatcher, 1, resyncMsg, []runtime.Presence{presence}, true)
+
+	// 相手には再接続を知らせる
+	others := make([]runtime.Presence, 0, len(m.presences))
+	for id, other := range m.presences {
+		if id != userID {
+			others = append(others, other)
+		}
+	}
+	if len(others) > 0 {
+		msg := map[string]interface{}{
+			"type": "player_reconnected",
+			"data": &PlayerReconnectedData{PlayerID: userID},
+		}
+		m.sendMessage(dispatcher, 1, msg, others, true)
+	}
+	return true
+}
+
+// checkReconnectGrace - 猶予時間内に再接続しなかったプレイヤーを切断による負けとする
+// 戻り値が nil の場合はマッチを終了する
+func (m *QuoridorChessMatch) checkReconnectGrace(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher) interface{} {
+	if !m.gameState.GameStarted || len(m.disconnectedAt) == 0 {
+		return m.gameState
+	}
+
+	// 先に切断したプレイヤーから判定する
+	var userID string
+	var earliest time.Time
+	for id, at := range m.disconnectedAt {
+		if userID == "" || at.Before(earliest) {
+			userID, earliest = id, at
+		}
+	}
+	if time.Since(earliest) < m.reconnectGrace {
+		return m.gameState
+	}
+
+	logger.Info("Player %s did not reconnect to match %s in time", userID, m.matchID)
+	delete(m.disconnectedAt, userID)
+	m.closeDisconnect(nk, userID, false)
+	if opponentID := m.opponentOf(userID); opponentID != "" {
+		m.endGame(ctx, logger, nk, opponentID, ResultReasonDisconnect)
+		updateMsg := map[string]interface{}{
+			"type": "game_state_update",
+			"data": m.gameState,
+		}
+		m.sendMessage(dispatcher, 1, updateMsg, nil, true)
+	}
+
+	// 誰も接続していなければマッチを終了
+	if len(m.presences) == 0 {
+		return nil
+	}
+	return m.gameState
+}
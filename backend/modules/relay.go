@@ -0,0 +1,261 @@
+// Quoridor Chess 中継ストリーム
+// トーナメントの決勝など注目の対局を、権威マッチに参加させずに何人でも観戦できるよう、
+// 全員宛てのメッセージを一定時間遅らせて公開のストリームに流す（対局者への助言を防ぐため遅延を挟む）
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const relayStreamMode uint8 = 10 // 中継ストリームのモード（Nakamaの組み込みストリームと重ならない値、サブジェクトはマッチID）
+
+// RelayEnvelope - 中継ストリームに流す1件（マッチ内で送ったメッセージをそのまま包む）
+type RelayEnvelope struct {
+	MatchID string          `json:"match_id"`
+	Seq     int64           `json:"seq"`     // マッチ内の全員宛てのイベントの通し番号
+	OpCode  int64           `json:"op_code"` // マッチ内で送ったオペコード
+	SentAt  int64           `json:"sent_at"` // マッチ内で送った時刻（Unixミリ秒）
+	Message json.RawMessage `json:"message"` // マッチ内で送ったメッセージ
+}
+
+// relayEntry - 遅延が明けるのを待っている中継
+type relayEntry struct {
+	at        time.Time
+	data      []byte
+	fullState bool // ゲーム状態全体を含むか（途中から見始めた人の最初の局面に使う）
+}
+
+// BroadcastInfo - watch_broadcast RPCのレスポンス
+type BroadcastInfo struct {
+	MatchID  string          `json:"match_id"`
+	DelayMs  int64           `json:"delay_ms"` // 対局からの遅れ（ミリ秒）
+	Snapshot json.RawMessage `json:"snapshot"` // 直近に中継したゲーム状態全体（まだなければ null）
+	Viewers  int             `json:"viewers"`  // 中継の視聴者数
+}
+
+// AdminSetBroadcastRequest - admin_set_broadcast RPCのリクエスト
+type AdminSetBroadcastRequest struct {
+	MatchID string `json:"match_id"`
+	Enabled bool   `json:"enabled"` // true で中継を始め、false で止める
+}
+
+// BroadcastRequest - watch_broadcast / leave_broadcast RPCのリクエスト
+type BroadcastRequest struct {
+	MatchID string `json:"match_id"`
+}
+
+// queueRelay - 全員宛てのメッセージを中継の待ち行列に加える（チャットは中継しない）
+// 待ち行列はマッチループの毎ティックで flushRelay が流す
+func (m *QuoridorChessMatch) queueRelay(opCode int64, msg interface{}, msgBytes []byte) {
+	if !m.relaying || opCode == 2 {
+		return
+	}
+	now := time.Now()
+	data, err := json.Marshal(&RelayEnvelope{
+		MatchID: m.matchID,
+		Seq:     m.gameState.Seq,
+		OpCode:  opCode,
+		SentAt:  now.UnixMilli(),
+		Message: msgBytes,
+	})
+	if err != nil {
+		return
+	}
+	m.relayQueue = append(m.relayQueue, &relayEntry{at: now, data: data, fullState: isRelaySnapshot(msg)})
+}
+
+// isRelaySnapshot - 途中から見始めた人の最初の局面に使えるメッセージかどうか
+func isRelaySnapshot(msg interface{}) bool {
+	fields, ok := msg.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	switch fields["type"] {
+	case "game_state_update", "state_resync", "game_started", "game_over":
+		return true
+	}
+	return false
+}
+
+// flushRelay - 遅延が明けた中継をストリームに流す
+// 対局が終わった後やマッチを終了するときは助言の心配がないため、残りをすぐに流す
+func (m *QuoridorChessMatch) flushRelay(logger runtime.Logger, nk runtime.NakamaModule, terminating bool) {
+	delay := m.relayDelay
+	if m.gameState.ResultReason != "" || terminating {
+		delay = 0
+	}
+	now := time.Now()
+	sent := 0
+	for _, entry := range m.relayQueue {
+		if now.Sub(entry.at) < delay {
+			break
+		}
+		if err := nk.StreamSend(relayStreamMode, m.matchID, "", "", string(entry.data), nil, true); err != nil {
+			logger.Warn("Failed to relay message of match %s: %v", m.matchID, err)
+		}
+		if entry.fullState {
+			m.relaySnapshot = entry.data
+		}
+		sent++
+	}
+	if sent > 0 {
+		m.relayQueue = append([]*relayEntry(nil), m.relayQueue[sent:]...)
+	}
+}
+
+// handleSetBroadcastSignal - 中継を始める・止める（始めた時点の局面を最初の中継として流す）
+func (m *QuoridorChessMatch) handleSetBroadcastSignal(dispatcher runtime.MatchDispatcher, data json.RawMessage) string {
+	var req struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return signalError("invalid set_broadcast payload")
+	}
+	if m.anonymous {
+		return signalError("anonymous matches cannot be broadcast")
+	}
+	if req.Enabled != m.relaying {
+		m.relaying = req.Enabled
+		m.relayQueue = nil
+		m.relaySnapshot = nil
+		m.recordEvent("broadcast", EventSourceServer, "", map[string]interface{}{"enabled": req.Enabled})
+		m.label.Broadcast = req.Enabled
+		labelJSON, _ := json.Marshal(m.label)
+		dispatcher.MatchLabelUpdate(string(labelJSON))
+		if m.relaying {
+			msg := map[string]interface{}{
+				"type": "state_resync",
+				"data": m.gameState,
+			}
+			msgBytes, _ := json.Marshal(msg)
+			m.queueRelay(1, msg, msgBytes)
+		}
+	}
+	response, _ := json.Marshal(map[string]interface{}{"success": true, "enabled": m.relaying})
+	return string(response)
+}
+
+// handleGetBroadcastSignal - 中継の遅延と、直近に中継したゲーム状態全体を返す
+func (m *QuoridorChessMatch) handleGetBroadcastSignal() string {
+	if !m.relaying {
+		return signalError("match is not being broadcast")
+	}
+	response, _ := json.Marshal(map[string]interface{}{
+		"success":  true,
+		"delay_ms": m.relayDelay.Milliseconds(),
+		"snapshot": json.RawMessage(m.relaySnapshot),
+	})
+	return string(response)
+}
+
+// AdminSetBroadcast - 対局中のマッチの中継を始める・止めるRPC（サーバー間呼び出しのみ）
+func AdminSetBroadcast(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	if err := requireServerCaller(ctx); err != nil {
+		return "", err
+	}
+	req := &AdminSetBroadcastRequest{}
+	if err := json.Unmarshal([]byte(payload), req); err != nil || req.MatchID == "" {
+		return "", errInvalidPayload
+	}
+
+	signal, err := json.Marshal(map[string]interface{}{
+		"type": "set_broadcast",
+		"data": map[string]bool{"enabled": req.Enabled},
+	})
+	if err != nil {
+		return "", err
+	}
+	result, err := nk.MatchSignal(ctx, req.MatchID, string(signal))
+	if err != nil {
+		logger.Warn("admin_set_broadcast: signal to match %s failed: %v", req.MatchID, err)
+		return "", runtime.NewError("match not found", errCodeNotFound)
+	}
+
+	var response struct {
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(result), &response); err != nil {
+		return "", runtime.NewError("invalid response from match", errCodeInternal)
+	}
+	if !response.Success {
+		return "", runtime.NewError(response.Error, errCodeFailedPrecondition)
+	}
+	return result, nil
+}
+
+// WatchBroadcast - 中継ストリームに参加し、遅延と最初に表示する局面を返すRPC（ソケット接続が必要）
+func WatchBroadcast(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return "", err
+	}
+	sessionID, _ := ctx.Value(runtime.RUNTIME_CTX_SESSION_ID).(string)
+	if sessionID == "" {
+		return "", runtime.NewError("a socket session is required to watch a broadcast", errCodeFailedPrecondition)
+	}
+	req := &BroadcastRequest{}
+	if err := json.Unmarshal([]byte(payload), req); err != nil || req.MatchID == "" || len(req.MatchID) > maxMatchIDLength {
+		return "", errInvalidPayload
+	}
+
+	result, err := nk.MatchSignal(ctx, req.MatchID, `{"type":"get_broadcast"}`)
+	if err != nil {
+		return "", runtime.NewError("match not found", errCodeNotFound)
+	}
+	var response struct {
+		Success  bool            `json:"success"`
+		Error    string          `json:"error"`
+		DelayMs  int64           `json:"delay_ms"`
+		Snapshot json.RawMessage `json:"snapshot"`
+	}
+	if err := json.Unmarshal([]byte(result), &response); err != nil {
+		return "", runtime.NewError("invalid response from match", errCodeInternal)
+	}
+	if !response.Success {
+		return "", runtime.NewError(response.Error, errCodeFailedPrecondition)
+	}
+
+	if _, err := nk.StreamUserJoin(relayStreamMode, req.MatchID, "", "", userID, sessionID, false, false, ""); err != nil {
+		logger.Error("watch_broadcast: failed to join stream of match %s: %v", req.MatchID, err)
+		return "", runtime.NewError("failed to join broadcast", errCodeInternal)
+	}
+	viewers, err := nk.StreamCount(relayStreamMode, req.MatchID, "", "")
+	if err != nil {
+		viewers = -1
+	}
+
+	out, err := json.Marshal(&BroadcastInfo{
+		MatchID:  req.MatchID,
+		DelayMs:  response.DelayMs,
+		Snapshot: response.Snapshot,
+		Viewers:  viewers,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// LeaveBroadcast - 中継ストリームから抜けるRPC
+func LeaveBroadcast(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return "", err
+	}
+	sessionID, _ := ctx.Value(runtime.RUNTIME_CTX_SESSION_ID).(string)
+	req := &BroadcastRequest{}
+	if err := json.Unmarshal([]byte(payload), req); err != nil || req.MatchID == "" || sessionID == "" {
+		return "", errInvalidPayload
+	}
+	if err := nk.StreamUserLeave(relayStreamMode, req.MatchID, "", "", userID, sessionID); err != nil {
+		logger.Warn("leave_broadcast: failed to leave stream of match %s: %v", req.MatchID, err)
+		return "", runtime.NewError("failed to leave broadcast", errCodeInternal)
+	}
+	return `{"success": true}`, nil
+}
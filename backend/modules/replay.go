@@ -0,0 +1,147 @@
+// Quoridor Chess 対局の再生
+// 受理した移動と壁の配置をティックと時刻つきで手順として記録し、対局終了時に保存して、
+// クライアントが終わった対局を1手ずつ再生できるようにする
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const replayCollection = "match_replays" // 再生用の手順のストレージコレクション（システムが所有、キーはマッチID）
+
+// MoveLogEntry - 手順の1手
+type MoveLogEntry struct {
+	Ply       int       `json:"ply"`              // 何手目か（1から）
+	Tick      int64     `json:"tick"`             // 受理したティック
+	Timestamp int64     `json:"timestamp"`        // 受理した時刻（Unix時刻、ミリ秒）
+	PlayerID  string    `json:"player_id"`        // 指したプレイヤーID
+	Kind      string    `json:"kind"`             // 操作の種類（"move" または "wall"）
+	Notation  string    `json:"notation"`         // 棋譜の表記
+	From      *Position `json:"from,omitempty"`   // 移動元（コマ移動のみ）
+	To        *Position `json:"to,omitempty"`     // 移動先（コマ移動のみ）
+	Pushed    *Position `json:"pushed,omitempty"` // 押し出した相手のコマの移動先（押し出しバリアントのみ）
+	Wall      *Wall     `json:"wall,omitempty"`   // 配置した壁（壁配置のみ）
+	Auto      bool      `json:"auto"`             // サーバーが自動で指した手かどうか
+}
+
+// Replay - 保存する再生用の手順
+type Replay struct {
+	MatchID    string            `json:"match_id"`
+	Ruleset    *Ruleset          `json:"ruleset"`     // 適用したルール
+	Seats      []*SeatAssignment `json:"seats"`       // 開始時の席の割り当て（先手が先頭）
+	Usernames  map[string]string `json:"usernames"`   // 対局者ごとのユーザー名
+	WinnerID   string            `json:"winner_id"`   // 勝者のユーザーID（引き分けは空）
+	Reason     string            `json:"reason"`      // 決着の理由
+	Moves      []*MoveLogEntry   `json:"moves"`       // 手順（指した順）
+	FinishedAt int64             `json:"finished_at"` // 対局終了時刻（Unix時刻）
+}
+
+// GetReplayRequest - get_replay RPCのリクエスト
+type GetReplayRequest struct {
+	MatchID string `json:"match_id"`
+}
+
+// recordMoveLog - 受理した操作（直前の LastAction と棋譜）を手順に追加
+func (m *QuoridorChessMatch) recordMoveLog() {
+	action := m.gameState.LastAction
+	if action == nil || len(m.gameState.Notation) == 0 {
+		return
+	}
+	m.gameState.MoveLog = append(m.gameState.MoveLog, &MoveLogEntry{
+		Ply:       m.ply(),
+		Tick:      m.tick,
+		Timestamp: time.Now().UnixMilli(),
+		PlayerID:  action.PlayerID,
+		Kind:      action.Kind,
+		Notation:  m.gameState.Notation[len(m.gameState.Notation)-1],
+		From:      action.From,
+		To:        action.To,
+		Pushed:    action.Pushed,
+		Wall:      action.Wall,
+		Auto:      action.Auto,
+	})
+}
+
+// persistReplay - 対局終了時に書き出した分を含む手順全体を保存
+func (m *QuoridorChessMatch) persistReplay(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule) {
+	moves, err := m.fullMoveLog(ctx, nk)
+	if err != nil {
+		logger.Error("Failed to read move log of match %s: %v", m.matchID, err)
+		return
+	}
+	replay := &Replay{
+		MatchID:    m.matchID,
+		Ruleset:    m.ruleset,
+		Seats:      m.startSeats,
+		Usernames:  make(map[string]string, len(m.gameState.Players)),
+		WinnerID:   m.gameState.Winner,
+		Reason:     m.gameState.ResultReason,
+		Moves:      moves,
+		FinishedAt: time.Now().Unix(),
+	}
+	for id, player := range m.gameState.Players {
+		replay.Usernames[id] = player.Username
+	}
+
+	value, err := json.Marshal(replay)
+	if err != nil {
+		logger.Error("Failed to encode replay: %v", err)
+		return
+	}
+	if _, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      replayCollection,
+		Key:             m.matchID,
+		Value:           string(value),
+		PermissionRead:  2,
+		PermissionWrite: 0,
+	}}); err != nil {
+		logger.Error("Failed to store replay for match %s: %v", m.matchID, err)
+	}
+}
+
+// readReplay - 保存した手順を読み込む（存在しない場合は nil）
+func readReplay(ctx context.Context, nk runtime.NakamaModule, matchID string) (*Replay, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: replayCollection,
+		Key:        matchID,
+	}})
+	if err != nil || len(objects) == 0 {
+		return nil, err
+	}
+	replay := &Replay{}
+	if err := json.Unmarshal([]byte(objects[0].GetValue()), replay); err != nil {
+		return nil, err
+	}
+	return replay, nil
+}
+
+// GetReplay - 終了した対局の手順を指した順に返すRPC
+func GetReplay(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	if _, err := contextUserID(ctx); err != nil {
+		return "", err
+	}
+	req := &GetReplayRequest{}
+	if err := json.Unmarshal([]byte(payload), req); err != nil || req.MatchID == "" {
+		return "", errInvalidPayload
+	}
+
+	replay, err := readReplay(ctx, nk, req.MatchID)
+	if err != nil {
+		logger.Error("get_replay: failed to read replay: %v", err)
+		return "", runtime.NewError("failed to read replay", errCodeInternal)
+	}
+	if replay == nil {
+		return "", runtime.NewError("replay not found", errCodeNotFound)
+	}
+
+	response, err := json.Marshal(replay)
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
@@ -0,0 +1,290 @@
+// Quoridor Chess 対局の再生マッチ
+// 保存した手順を読み込み、参加したプレゼンスに指定の速さで1手ずつ送る（再生・一時停止・シークはマッチメッセージで操作）
+// クライアントは対局と同じ盤面の描画で再生でき、再生のロジックを持たなくてよい
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// ReplayMatchName - 再生マッチの登録名
+const ReplayMatchName = "quoridor_chess_replay"
+
+const (
+	defaultReplaySpeed = 1.0              // 既定の再生速度（1秒あたりの手数）
+	minReplaySpeed     = 0.25             // 指定できる再生速度の下限
+	maxReplaySpeed     = 10.0             // 指定できる再生速度の上限
+	replayIdleTimeout  = 60 * time.Second // 誰も参加していない再生マッチを終了するまでの時間
+)
+
+// ReplayMatch - 対局の再生マッチ
+type ReplayMatch struct {
+	matchID   string                      // 再生マッチのマッチID
+	replay    *Replay                     // 再生する手順
+	presences map[string]runtime.Presence // 参加中のプレゼンス（ユーザーID -> Presence）
+	ply       int                         // 送信済みの手数
+	playing   bool                        // 再生中かどうか
+	speed     float64                     // 再生速度（1秒あたりの手数）
+	nextAt    time.Time                   // 次の手を送る時刻
+	emptyAt   time.Time                   // 参加者がいなくなった時刻（参加者がいればゼロ値）
+	tickRate  int                         // サーバーの更新頻度
+}
+
+// ReplaySeekRequest - 再生位置の変更
+type ReplaySeekRequest struct {
+	Ply int `json:"ply"` // 移動先の手数（0 は開始局面）
+}
+
+// ReplaySetSpeedRequest - 再生速度の変更
+type ReplaySetSpeedRequest struct {
+	Speed float64 `json:"speed"` // 1秒あたりの手数
+}
+
+// ReplayPlayRequest - 再生の開始（最後まで再生済みの場合は最初から）
+type ReplayPlayRequest struct{}
+
+// ReplayPauseRequest - 再生の一時停止
+type ReplayPauseRequest struct{}
+
+// ReplayLoadedData - 参加時に送る再生する対局の情報（本人のみ）
+type ReplayLoadedData struct {
+	MatchID    string            `json:"match_id"`    // 再生する対局のマッチID
+	Ruleset    *Ruleset          `json:"ruleset"`     // 適用したルール
+	Seats      []*SeatAssignment `json:"seats"`       // 開始時の席の割り当て（先手が先頭）
+	Usernames  map[string]string `json:"usernames"`   // 対局者ごとのユーザー名
+	WinnerID   string            `json:"winner_id"`   // 勝者のユーザーID（引き分けは空）
+	Reason     string            `json:"reason"`      // 決着の理由
+	TotalMoves int               `json:"total_moves"` // 全体の手数
+}
+
+// ReplayPositionData - 再生位置までの手順（参加時とシーク時に送り、クライアントは開始局面から適用し直す）
+type ReplayPositionData struct {
+	Ply   int             `json:"ply"`   // 再生位置の手数
+	Moves []*MoveLogEntry `json:"moves"` // 開始から再生位置までの手順
+}
+
+// ReplayStateData - 再生の状態の通知
+type ReplayStateData struct {
+	Ply        int     `json:"ply"`         // 再生位置の手数
+	TotalMoves int     `json:"total_moves"` // 全体の手数
+	Playing    bool    `json:"playing"`     // 再生中かどうか
+	Speed      float64 `json:"speed"`       // 再生速度（1秒あたりの手数）
+}
+
+// isValidReplaySpeed - 指定された再生速度が範囲内かどうか
+func isValidReplaySpeed(speed float64) bool {
+	return speed >= minReplaySpeed && speed <= maxReplaySpeed
+}
+
+// MatchInit - 再生マッチの初期化（replay_match_id の手順を読み込み、speed があれば再生速度とする）
+func (r *ReplayMatch) MatchInit(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, params map[string]interface{}) (interface{}, int, string) {
+	r.matchID, _ = ctx.Value(runtime.RUNTIME_CTX_MATCH_ID).(string)
+	r.presences = make(map[string]runtime.Presence)
+	r.tickRate = 10
+	r.speed = defaultReplaySpeed
+	if speed, ok := params["speed"].(float64); ok && isValidReplaySpeed(speed) {
+		r.speed = speed
+	}
+	r.emptyAt = time.Now()
+
+	replayMatchID, _ := params["replay_match_id"].(string)
+	replay, err := readReplay(ctx, nk, replayMatchID)
+	if err != nil || replay == nil {
+		logger.Error("Failed to load replay %s: %v", replayMatchID, err)
+		return nil, r.tickRate, ""
+	}
+	r.replay = replay
+
+	label, _ := json.Marshal(map[string]interface{}{"replay": true, "replay_match_id": replayMatchID})
+	return r, r.tickRate, string(label)
+}
+
+// MatchJoinAttempt - 再生マッチには誰でも参加できる
+func (r *ReplayMatch) MatchJoinAttempt(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, tick int64, state interface{}, presence runtime.Presence, metadata map[string]string) (interface{}, bool, string) {
+	return state, true, ""
+}
+
+// MatchJoin - 参加したプレゼンスに対局の情報と現在の再生位置を送る
+func (r *ReplayMatch) MatchJoin(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, tick int64, state interface{}, presences []runtime.Presence) interface{} {
+	for _, presence := range presences {
+		r.presences[presence.GetUserId()] = presence
+	}
+	r.emptyAt = time.Time{}
+
+	r.send(dispatcher, "replay_loaded", &ReplayLoadedData{
+		MatchID:    r.replay.MatchID,
+		Ruleset:    r.replay.Ruleset,
+		Seats:      r.replay.Seats,
+		Usernames:  r.replay.Usernames,
+		WinnerID:   r.replay.WinnerID,
+		Reason:     r.replay.Reason,
+		TotalMoves: len(r.replay.Moves),
+	}, presences)
+	r.send(dispatcher, "replay_position", &ReplayPositionData{Ply: r.ply, Moves: r.replay.Moves[:r.ply]}, presences)
+	r.send(dispatcher, "replay_state", r.state(), presences)
+	return r
+}
+
+// MatchLeave - 退出したプレゼンスを取り除く（誰もいなくなったら一定時間後に終了）
+func (r *ReplayMatch) MatchLeave(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, tick int64, state interface{}, presences []runtime.Presence) interface{} {
+	for _, presence := range presences {
+		delete(r.presences, presence.GetUserId())
+	}
+	if len(r.presences) == 0 {
+		r.emptyAt = time.Now()
+	}
+	return r
+}
+
+// MatchLoop - 再生の操作を処理し、再生中は再生速度に合わせて次の手を送る
+func (r *ReplayMatch) MatchLoop(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, tick int64, state interface{}, messages []runtime.MatchData) interface{} {
+	// 誰も参加しないまま一定時間たったら終了
+	if len(r.presences) == 0 && !r.emptyAt.IsZero() && time.Since(r.emptyAt) >= replayIdleTimeout {
+		return nil
+	}
+
+	for _, msg := range messages {
+		var data map[string]interface{}
+		if err := json.Unmarshal(msg.GetData(), &data); err != nil {
+			continue // JSON解析エラーは無視
+		}
+
+		msgType, _ := data["type"].(string)
+		switch msgType {
+		case "replay_play":
+			// 最後まで再生済みなら最初から再生し直す
+			if r.ply >= len(r.replay.Moves) {
+				r.seek(dispatcher, 0)
+			}
+			r.playing = true
+			r.nextAt = time.Now()
+
+		case "replay_pause":
+			r.playing = false
+
+		case "replay_seek":
+			ply, ok := data["ply"].(float64)
+			if !ok || ply < 0 || int(ply) > len(r.replay.Moves) {
+				continue
+			}
+			r.seek(dispatcher, int(ply))
+
+		case "replay_set_speed":
+			speed, ok := data["speed"].(float64)
+			if !ok || !isValidReplaySpeed(speed) {
+				continue
+			}
+			r.speed = speed
+
+		default:
+			continue
+		}
+		r.send(dispatcher, "replay_state", r.state(), nil)
+	}
+
+	// 再生中は再生速度に合わせて次の手を送る
+	if r.playing && !time.Now().Before(r.nextAt) {
+		if r.ply < len(r.replay.Moves) {
+			r.send(dispatcher, "replay_move", r.replay.Moves[r.ply], nil)
+			r.ply++
+			r.nextAt = time.Now().Add(time.Duration(float64(time.Second) / r.speed))
+		}
+		// 最後の手を送ったら停止する
+		if r.ply >= len(r.replay.Moves) {
+			r.playing = false
+			r.send(dispatcher, "replay_state", r.state(), nil)
+		}
+	}
+	return r
+}
+
+// MatchTerminate - 再生マッチの終了処理
+func (r *ReplayMatch) MatchTerminate(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, tick int64, state interface{}, graceSeconds int) interface{} {
+	return state
+}
+
+// MatchSignal - 再生マッチはシグナルを受け付けない
+func (r *ReplayMatch) MatchSignal(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, tick int64, state interface{}, data string) (interface{}, string) {
+	return state, signalError("unknown signal type")
+}
+
+// seek - 再生位置を変更し、全員に開始から再生位置までの手順を送る
+func (r *ReplayMatch) seek(dispatcher runtime.MatchDispatcher, ply int) {
+	r.ply = ply
+	r.nextAt = time.Now()
+	r.send(dispatcher, "replay_position", &ReplayPositionData{Ply: ply, Moves: r.replay.Moves[:ply]}, nil)
+}
+
+// state - 現在の再生の状態
+func (r *ReplayMatch) state() *ReplayStateData {
+	return &ReplayStateData{
+		Ply:        r.ply,
+		TotalMoves: len(r.replay.Moves),
+		Playing:    r.playing,
+		Speed:      r.speed,
+	}
+}
+
+// send - 再生マッチのメッセージを送信（recipients が nil の場合は全員）
+func (r *ReplayMatch) send(dispatcher runtime.MatchDispatcher, msgType string, data interface{}, recipients []runtime.Presence) {
+	msgBytes, err := json.Marshal(map[string]interface{}{
+		"type": msgType,
+		"data": data,
+	})
+	if err != nil {
+		return
+	}
+	dispatcher.BroadcastMessage(1, msgBytes, recipients, nil, true)
+}
+
+// WatchReplayRequest - watch_replay RPCのリクエスト
+type WatchReplayRequest struct {
+	MatchID string  `json:"match_id"` // 再生する対局のマッチID
+	Speed   float64 `json:"speed"`    // 再生速度（1秒あたりの手数、省略時は既定値）
+}
+
+// WatchReplay - 終了した対局の再生マッチを作成し、そのマッチIDを返すRPC
+func WatchReplay(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	if _, err := contextUserID(ctx); err != nil {
+		return "", err
+	}
+	req := &WatchReplayRequest{}
+	if err := json.Unmarshal([]byte(payload), req); err != nil || req.MatchID == "" {
+		return "", errInvalidPayload
+	}
+	if req.Speed == 0 {
+		req.Speed = defaultReplaySpeed
+	}
+	if !isValidReplaySpeed(req.Speed) {
+		return "", runtime.NewError("speed is out of range", errCodeInvalidArgument)
+	}
+
+	replay, err := readReplay(ctx, nk, req.MatchID)
+	if err != nil {
+		logger.Error("watch_replay: failed to read replay: %v", err)
+		return "", runtime.NewError("failed to read replay", errCodeInternal)
+	}
+	if replay == nil {
+		return "", runtime.NewError("replay not found", errCodeNotFound)
+	}
+
+	matchID, err := nk.MatchCreate(ctx, ReplayMatchName, map[string]interface{}{
+		"replay_match_id": req.MatchID,
+		"speed":           req.Speed,
+	})
+	if err != nil {
+		logger.Error("watch_replay: failed to create replay match: %v", err)
+		return "", runtime.NewError("failed to create replay match", errCodeInternal)
+	}
+
+	response, err := json.Marshal(map[string]interface{}{"match_id": matchID})
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
@@ -0,0 +1,45 @@
+// Quoridor Chess RPC共通処理
+// RPCハンドラーで使うエラーコードと呼び出し元ユーザーの取得
+package main
+
+import (
+	"context"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// RPCエラーコード（gRPCのステータスコードに対応）
+const (
+	errCodeInvalidArgument    = 3
+	errCodeNotFound           = 5
+	errCodeAlreadyExists      = 6
+	errCodePermissionDenied   = 7
+	errCodeResourceExhausted  = 8
+	errCodeFailedPrecondition = 9
+	errCodeInternal           = 13
+	errCodeUnauthenticated    = 16
+)
+
+// 共通のRPCエラー
+var (
+	errNoUserID       = runtime.NewError("no user ID in context", errCodeUnauthenticated)
+	errInvalidPayload = runtime.NewError("invalid request payload", errCodeInvalidArgument)
+	errServerOnly     = runtime.NewError("this RPC is restricted to server-to-server calls", errCodePermissionDenied)
+)
+
+// contextUserID - RPCの呼び出し元ユーザーIDを返す（セッションなしの呼び出しはエラー）
+func contextUserID(ctx context.Context) (string, error) {
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok || userID == "" {
+		return "", errNoUserID
+	}
+	return userID, nil
+}
+
+// requireServerCaller - 管理用RPCをサーバー間呼び出し（HTTPキー経由でセッションなし）に限定する
+func requireServerCaller(ctx context.Context) error {
+	if userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string); ok && userID != "" {
+		return errServerOnly
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+// Quoridor Chess わざと負ける行為（サンドバッグ）の検出
+// レーティング対象の対局でゴールから遠ざかる移動を数え、不自然な負け方をしたアカウントを審査対象として記録する
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	reviewFlagCollection  = "review_flags" // 審査フラグのストレージコレクション
+	reviewFlagSandbagging = "sandbagging"  // サンドバッグの審査フラグのストレージキー
+	reviewFlagCollusion   = "collusion"    // 談合の審査フラグのストレージキー
+
+	sandbagRegressiveMoves = 5                  // 1局でこの回数以上ゴールから遠ざかって負けたら審査対象
+	sandbagMinMoves        = 6                  // 割合で判定するのに必要な最小手数
+	sandbagRatingFreeze    = 7 * 24 * time.Hour // 審査対象になったときにレーティング変動を止める期間
+)
+
+// ReviewFlag - アカウントの審査フラグ
+type ReviewFlag struct {
+	Count             int   `json:"count"`               // これまでに検出された回数
+	LastFlaggedAt     int64 `json:"last_flagged_at"`     // 最後に検出された時刻（Unix時刻）
+	RatingFrozenUntil int64 `json:"rating_frozen_until"` // この時刻までレーティング変動を止める（Unix時刻）
+}
+
+// recordRegressiveMove - ゴールまでの最短経路が長くなる移動を数える（レーティング対象の対局のみ）
+func (m *QuoridorChessMatch) recordRegressiveMove(player *Player, newX, newY int) {
+	if !m.gameState.Ranked {
+		return
+	}
+	goal := m.goalOf(player)
+	before := m.gameState.Board.ShortestPathTo(player.Position, goal)
+	after := m.gameState.Board.ShortestPathTo(&Position{X: newX, Y: newY}, goal)
+	if after > before {
+		m.regressiveMoves[player.ID]++
+	}
+}
+
+// isSuspectedSandbagging - 負けたプレイヤーの移動内容がわざと負けたように見えるかどうかを返す
+func (m *QuoridorChessMatch) isSuspectedSandbagging(userID string) bool {
+	regressive := m.regressiveMoves[userID]
+	if regressive >= sandbagRegressiveMoves {
+		return true
+	}
+	moves := 0
+	if timing, ok := m.moveTimings[userID]; ok {
+		moves = timing.moves
+	}
+	// 手数の半分以上がゴールから遠ざかる移動
+	return moves >= sandbagMinMoves && regressive*2 >= moves
+}
+
+// checkSandbagging - 対局終了時に敗者の移動内容を確認し、疑わしければ審査フラグを立てる
+func (m *QuoridorChessMatch) checkSandbagging(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule) {
+	if !m.gameState.Ranked || m.gameState.Winner == "" {
+		return
+	}
+
+	for userID := range m.gameState.Players {
+		if m.isBot(userID) || userID == m.gameState.Winner || !m.isSuspectedSandbagging(userID) {
+			continue
+		}
+
+		writeAuditEvent(ctx, logger, nk, &AuditEvent{
+			Type:    "sandbagging_suspected",
+			MatchID: m.matchID,
+			UserID:  userID,
+			Details: map[string]interface{}{
+				"regressive_moves": m.regressiveMoves[userID],
+				"result_reason":    m.gameState.ResultReason,
+			},
+		})
+		if err := flagForReview(ctx, nk, userID, reviewFlagSandbagging); err != nil {
+			logger.Error("Failed to flag %s for review: %v", userID, err)
+		}
+	}
+}
+
+// flagForReview - アカウントに審査フラグ（kind は検出の種類）を立て、一定期間レーティング変動を止める
+func flagForReview(ctx context.Context, nk runtime.NakamaModule, userID, kind string) error {
+	flag, version, err := readReviewFlag(ctx, nk, userID, kind)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	flag.Count++
+	flag.LastFlaggedAt = now.Unix()
+	flag.RatingFrozenUntil = now.Add(sandbagRatingFreeze).Unix()
+
+	value, err := json.Marshal(flag)
+	if err != nil {
+		return err
+	}
+	if version == "" {
+		version = "*"
+	}
+	_, err = nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      reviewFlagCollection,
+		Key:             kind,
+		UserID:          userID,
+		Value:           string(value),
+		Version:         version,
+		PermissionRead:  0,
+		PermissionWrite: 0,
+	}})
+	return err
+}
+
+// readReviewFlag - アカウントの審査フラグを読み込む（未作成の場合は空）
+func readReviewFlag(ctx context.Context, nk runtime.NakamaModule, userID, kind string) (*ReviewFlag, string, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: reviewFlagCollection,
+		Key:        kind,
+		UserID:     userID,
+	}})
+	if err != nil {
+		return nil, "", err
+	}
+
+	flag := &ReviewFlag{}
+	if len(objects) == 0 {
+		return flag, "", nil
+	}
+	if err := json.Unmarshal([]byte(objects[0].GetValue()), flag); err != nil {
+		return nil, "", err
+	}
+	return flag, objects[0].GetVersion(), nil
+}
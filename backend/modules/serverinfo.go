@@ -0,0 +1,87 @@
+// Quoridor Chess サーバー情報
+// 参加したクライアントにモジュールのバージョンや有効な機能を伝え、UIの機能を切り替えられるようにする
+package main
+
+import "github.com/heroiclabs/nakama-common/runtime"
+
+// enabledFeatures - このマッチで有効な機能の一覧を返す
+func (m *QuoridorChessMatch) enabledFeatures() []string {
+	features := []string{"action_hints", "heartbeat", "low_bandwidth"}
+	if m.featureEnabled(FlagAutoMove) {
+		features = append(features, "auto_move")
+	}
+	if m.anonymous {
+		features = append(features, "anonymous")
+	}
+	if m.emoteOnlyChat() {
+		features = append(features, "emote_only_chat")
+	} else {
+		features = append(features, "chat", "emotes")
+	}
+	if m.spectatorCap > 0 {
+		features = append(features, "spectators")
+	}
+	if m.vsBot() {
+		features = append(features, "vs_bot")
+	}
+	// トレーニングモードはカジュアル戦のみ
+	if !m.gameState.Ranked {
+		features = append(features, "training_mode")
+	}
+	return features
+}
+
+// gameStartedData - 対局開始の通知内容を作成（先手から順に席を並べる）
+func (m *QuoridorChessMatch) gameStartedData() *GameStartedData {
+	data := &GameStartedData{
+		GameState:       m.gameState,
+		Ruleset:         m.ruleset,
+		Seats:           make([]*SeatAssignment, 0, len(m.gameState.Players)),
+		ProtocolVersion: ProtocolVersion,
+		Capabilities:    m.enabledFeatures(),
+	}
+	for id, player := range m.gameState.Players {
+		var start *Position
+		if player.Position != nil {
+			start = &Position{X: player.Position.X, Y: player.Position.Y}
+		}
+		seat := &SeatAssignment{
+			PlayerID:   id,
+			Color:      player.Color,
+			Start:      start,
+			GoalRow:    goalRow(player.Color),
+			Walls:      player.Walls,
+			MovesFirst: id == m.gameState.CurrentTurn,
+			Tier:       m.tiers[id],
+			Flair:      m.flairs[id],
+		}
+		if seat.MovesFirst {
+			data.Seats = append([]*SeatAssignment{seat}, data.Seats...)
+		} else {
+			data.Seats = append(data.Seats, seat)
+		}
+		if player.Clock != nil {
+			if data.Clocks == nil {
+				data.Clocks = make(map[string]*Clock)
+			}
+			clock := *player.Clock
+			data.Clocks[id] = &clock
+		}
+	}
+	return data
+}
+
+// sendServerInfo - 参加したプレゼンスにサーバー情報を送信
+func (m *QuoridorChessMatch) sendServerInfo(dispatcher runtime.MatchDispatcher, presence runtime.Presence) {
+	msg := map[string]interface{}{
+		"type": "server_info",
+		"data": &ServerInfoData{
+			ModuleVersion:   ModuleVersion,
+			ProtocolVersion: ProtocolVersion,
+			Features:        m.enabledFeatures(),
+			Ruleset:         m.ruleset,
+			RatingSystem:    m.ratingSystem,
+		},
+	}
+	m.sendMessage(dispatcher, 1, msg, []runtime.Presence{presence}, true)
+}
@@ -0,0 +1,150 @@
+// Quoridor Chess ゲームプレイ設定
+// 対局の進行に影響する設定（自動移動など）をサーバー側に保存し、マッチのロジックで適用する
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	gameplaySettingsCollection = "user_settings" // ユーザー設定のストレージコレクション
+	gameplaySettingsKey        = "gameplay"      // ゲームプレイ設定のストレージキー
+)
+
+// GameplaySettings - 対局中にサーバーが適用する設定
+type GameplaySettings struct {
+	// 壁を使い切っていて移動先が1つしかないとき、サーバーが自動でその手を指す
+	AutoMove bool `json:"auto_move"`
+	// 始めたばかりのプレイヤー向けのヒントを送らない
+	DisableTips bool `json:"disable_tips"`
+	// ロビーで配信される注目の結果のお知らせを受け取らない
+	DisableNews bool `json:"disable_news"`
+}
+
+// UpdateGameplaySettingsRequest - update_gameplay_settings RPCのリクエスト（省略した項目は変更しない）
+type UpdateGameplaySettingsRequest struct {
+	AutoMove    *bool `json:"auto_move"`
+	DisableTips *bool `json:"disable_tips"`
+	DisableNews *bool `json:"disable_news"`
+}
+
+// readGameplaySettings - ユーザーのゲームプレイ設定を読み込む（未保存の場合は既定値）
+func readGameplaySettings(ctx context.Context, nk runtime.NakamaModule, userID string) (*GameplaySettings, string, error) {
+	settings := &GameplaySettings{}
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: gameplaySettingsCollection,
+		Key:        gameplaySettingsKey,
+		UserID:     userID,
+	}})
+	if err != nil {
+		return nil, "", err
+	}
+	if len(objects) == 0 {
+		return settings, "", nil
+	}
+	if err := json.Unmarshal([]byte(objects[0].GetValue()), settings); err != nil {
+		return nil, "", err
+	}
+	return settings, objects[0].GetVersion(), nil
+}
+
+// GetGameplaySettings - 自分のゲームプレイ設定を返すRPC
+func GetGameplaySettings(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return "", err
+	}
+	settings, _, err := readGameplaySettings(ctx, nk, userID)
+	if err != nil {
+		logger.Error("get_gameplay_settings: failed to read settings: %v", err)
+		return "", runtime.NewError("failed to read settings", errCodeInternal)
+	}
+
+	response, err := json.Marshal(settings)
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
+
+// UpdateGameplaySettings - 自分のゲームプレイ設定を更新するRPC（次に参加するマッチから適用）
+func UpdateGameplaySettings(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return "", err
+	}
+	req := &UpdateGameplaySettingsRequest{}
+	if err := json.Unmarshal([]byte(payload), req); err != nil {
+		return "", errInvalidPayload
+	}
+
+	settings, version, err := readGameplaySettings(ctx, nk, userID)
+	if err != nil {
+		logger.Error("update_gameplay_settings: failed to read settings: %v", err)
+		return "", runtime.NewError("failed to read settings", errCodeInternal)
+	}
+	if req.AutoMove != nil {
+		settings.AutoMove = *req.AutoMove
+	}
+	if req.DisableTips != nil {
+		settings.DisableTips = *req.DisableTips
+	}
+	if req.DisableNews != nil {
+		settings.DisableNews = *req.DisableNews
+	}
+
+	value, err := json.Marshal(settings)
+	if err != nil {
+		return "", err
+	}
+	if version == "" {
+		version = "*"
+	}
+	if _, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      gameplaySettingsCollection,
+		Key:             gameplaySettingsKey,
+		UserID:          userID,
+		Value:           string(value),
+		Version:         version,
+		PermissionRead:  1,
+		PermissionWrite: 0,
+	}}); err != nil {
+		return "", runtime.NewError("settings were modified concurrently", errCodeFailedPrecondition)
+	}
+	return string(value), nil
+}
+
+// loadGameplaySettings - 参加したプレイヤーの設定をマッチに読み込む（失敗した場合は既定値）
+func (m *QuoridorChessMatch) loadGameplaySettings(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID string) {
+	settings, _, err := readGameplaySettings(ctx, nk, userID)
+	if err != nil {
+		logger.Warn("Failed to read gameplay settings for %s: %v", userID, err)
+		settings = &GameplaySettings{}
+	}
+	m.settings[userID] = settings
+}
+
+// playForcedMove - 手番のプレイヤーが自動移動を有効にしていて、指せる手が1つしかない場合にその手を指す
+// 壁が残っている間は壁を置く選択肢があるため自動移動しない
+func (m *QuoridorChessMatch) playForcedMove(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher) {
+	if !m.gameState.GameStarted || !m.featureEnabled(FlagAutoMove) {
+		return
+	}
+	player := m.gameState.Players[m.gameState.CurrentTurn]
+	if player == nil || player.Walls > 0 {
+		return
+	}
+	if settings := m.settings[player.ID]; settings == nil || !settings.AutoMove {
+		return
+	}
+
+	moves := m.legalMoves(player)
+	if len(moves) != 1 {
+		return
+	}
+	m.applyMove(ctx, logger, nk, dispatcher, player, moves[0].X, moves[0].Y, true)
+}
@@ -0,0 +1,59 @@
+// Quoridor Chess 局面のスナップショット
+// 一定の手数ごとに局面全体を正規化した短い文字列で全員に送り、クライアントが手元の盤面を照合・修復できるようにする
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const positionSnapshotInterval = 10 // スナップショットを送る間隔（手数）
+
+// encodePosition - 局面を正規化した文字列に変換する
+// 形式: "<ボードサイズ>|<手番の色 w/b>|<白のマス>:<白の残り壁>|<黒のマス>:<黒の残り壁>|<壁の棋譜表記をソートしてカンマ区切り>"
+// 例: "9|w|e2:9|e8:10|d3h"
+func (m *QuoridorChessMatch) encodePosition() string {
+	gs := m.gameState
+	turn := "-"
+	pawns := map[string]string{"white": "-", "black": "-"}
+	for id, player := range gs.Players {
+		if player.Position != nil {
+			pawns[player.Color] = squareName(player.Position.X, player.Position.Y) + ":" + strconv.Itoa(player.Walls)
+		}
+		if id == gs.CurrentTurn {
+			turn = player.Color[:1]
+		}
+	}
+
+	walls := make([]string, 0, len(gs.Board.Walls))
+	for _, wall := range gs.Board.Walls {
+		if wall.Start != nil {
+			walls = append(walls, wallNotation(wall))
+		}
+	}
+	sort.Strings(walls)
+
+	return strings.Join([]string{
+		strconv.Itoa(gs.Board.Size),
+		turn,
+		pawns["white"],
+		pawns["black"],
+		strings.Join(walls, ","),
+	}, "|")
+}
+
+// sendPositionSnapshot - 一定の手数ごとに局面のスナップショットを全員に送る
+func (m *QuoridorChessMatch) sendPositionSnapshot(dispatcher runtime.MatchDispatcher) {
+	ply := m.ply()
+	if ply == 0 || ply%positionSnapshotInterval != 0 {
+		return
+	}
+	msg := map[string]interface{}{
+		"type": "position_snapshot",
+		"data": &PositionSnapshotData{Ply: ply, Position: m.encodePosition()},
+	}
+	m.sendMessage(dispatcher, 1, msg, nil, false)
+}
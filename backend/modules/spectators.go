@@ -0,0 +1,118 @@
+// Quoridor Chess 観戦
+// 参加時のメタデータで spectator を指定したプレゼンスを、対局者とは別に観戦者として受け入れる
+// 観戦者はゲーム状態の Players や手番の処理には含めず、全員宛ての通知だけを受け取る（操作は受け付けない）
+package main
+
+import (
+	"context"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// SpectatorCountData - 観戦者の人数の通知（重要でないメッセージとして送る）
+type SpectatorCountData struct {
+	Count int `json:"count"`
+}
+
+// isSpectatorRequest - 参加時のメタデータで観戦を指定しているかどうか
+func isSpectatorRequest(metadata map[string]string) bool {
+	return metadata["spectator"] == "true"
+}
+
+// spectatorJoinAttempt - 観戦者としての参加を判定し、拒否する場合はその理由を返す
+func (m *QuoridorChessMatch) spectatorJoinAttempt(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, presence runtime.Presence, metadata map[string]string) string {
+	userID := presence.GetUserId()
+	if m.gameState.Players[userID] != nil {
+		return "Players cannot spectate their own match"
+	}
+	if len(m.spectators) >= m.spectatorCap {
+		return "Spectator limit reached"
+	}
+	// プライベートマッチの観戦にも参加コードが必要
+	if reason := m.checkPrivateJoin(ctx, nk, userID, metadata); reason != "" {
+		return reason
+	}
+	// キッズセーフモードでは対局者のフレンドのみ観戦できる
+	if m.kidSafe && !m.isFriendOfPlayer(ctx, logger, nk, userID) {
+		return "Only friends of the players can spectate this match"
+	}
+	m.spectatorRequests[userID] = true
+	return ""
+}
+
+// isFriendOfPlayer - ユーザーがいずれかの対局者（ボットを除く）の相互フレンドかどうかを返す
+// フレンド一覧を取得できなかった対局者は飛ばして、他の対局者を調べる
+func (m *QuoridorChessMatch) isFriendOfPlayer(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID string) bool {
+	mutual := 0
+	for playerID := range m.gameState.Players {
+		if m.isBot(playerID) {
+			continue
+		}
+		cursor := ""
+		for {
+			friends, next, err := nk.FriendsList(ctx, playerID, 100, &mutual, cursor)
+			if err != nil {
+				logger.Warn("Failed to list friends of %s for spectator %s: %v", playerID, userID, err)
+				break
+			}
+			for _, friend := range friends {
+				if friend.GetUser().GetId() == userID {
+					return true
+				}
+			}
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+	}
+	return false
+}
+
+// joinSpectator - 観戦者として参加を許可したプレゼンスを登録し、本人に全体の状態を送る
+// 観戦者として処理した場合は true を返す
+func (m *QuoridorChessMatch) joinSpectator(dispatcher runtime.MatchDispatcher, presence runtime.Presence) bool {
+	userID := presence.GetUserId()
+	if !m.spectatorRequests[userID] {
+		return false
+	}
+	delete(m.spectatorRequests, userID)
+	m.spectators[userID] = presence
+	m.recordEvent("spectator_joined", EventSourceServer, userID, nil)
+
+	m.sendServerInfo(dispatcher, presence)
+	stateMsg := map[string]interface{}{
+		"type": "state_resync",
+		"data": m.gameState,
+	}
+	m.sendMessage(dispatcher, 1, stateMsg, []runtime.Presence{presence}, true)
+	m.sendSpectatorCount(dispatcher)
+	return true
+}
+
+// leaveSpectator - 観戦者の退出を処理する（観戦者でなければ false を返す）
+func (m *QuoridorChessMatch) leaveSpectator(dispatcher runtime.MatchDispatcher, presence runtime.Presence) bool {
+	userID := presence.GetUserId()
+	if _, ok := m.spectators[userID]; !ok {
+		return false
+	}
+	delete(m.spectators, userID)
+	m.recordEvent("spectator_left", EventSourceServer, userID, nil)
+	m.sendSpectatorCount(dispatcher)
+	return true
+}
+
+// isSpectator - 観戦者かどうか
+func (m *QuoridorChessMatch) isSpectator(userID string) bool {
+	_, ok := m.spectators[userID]
+	return ok
+}
+
+// sendSpectatorCount - 観戦者の人数を全員に知らせる
+func (m *QuoridorChessMatch) sendSpectatorCount(dispatcher runtime.MatchDispatcher) {
+	msg := map[string]interface{}{
+		"type": "spectator_count",
+		"data": &SpectatorCountData{Count: len(m.spectators)},
+	}
+	m.sendMessage(dispatcher, 1, msg, nil, false)
+}
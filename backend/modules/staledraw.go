@@ -0,0 +1,164 @@
+// Quoridor Chess 停滞による引き分け
+// 両者とも壁を置かず、ゴールまでの最短手数も縮められないまま一定のターン数が続いた場合（行ったり来たりするだけの局面）に、
+// ルールセットに応じて引き分けを提案するか、引き分けで対局を終了する
+package main
+
+import (
+	"context"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// 停滞を検出したときの扱い
+const (
+	StaleDrawPolicyOffer   = "offer"   // 両者に引き分けを提案し、両者が合意したら引き分け
+	StaleDrawPolicyEnforce = "enforce" // その場で引き分けとして対局を終了
+)
+
+const (
+	defaultStaleDrawTurns = 10  // 停滞とみなすまでのターン数の既定値（両者が1手ずつ指して1ターン）
+	maxStaleDrawTurns     = 100 // 停滞とみなすまでのターン数の上限
+)
+
+// StaleDrawDetails - 停滞の検出内容（引き分けの提案と対局終了の通知に含める）
+type StaleDrawDetails struct {
+	Turns       int            `json:"turns"`        // 進展のなかったターン数
+	PathLengths map[string]int `json:"path_lengths"` // 各プレイヤーのゴールまでの最短手数
+	BestLengths map[string]int `json:"best_lengths"` // 停滞が始まってからの各プレイヤーの最短手数の最小値
+}
+
+// staleDrawSettings - マッチ作成時のパラメータから停滞とみなすターン数と扱いを決める
+func staleDrawSettings(params map[string]interface{}) (int, string) {
+	turns := defaultStaleDrawTurns
+	if value, ok := params["stale_draw_turns"].(float64); ok && value >= 0 && value <= maxStaleDrawTurns {
+		turns = int(value)
+	}
+	policy := StaleDrawPolicyOffer
+	if value, _ := params["stale_draw_policy"].(string); value == StaleDrawPolicyEnforce {
+		policy = StaleDrawPolicyEnforce
+	}
+	if turns == 0 {
+		return 0, ""
+	}
+	return turns, policy
+}
+
+// pathLengths - 各プレイヤーのゴールまでの最短手数を返す
+func (m *QuoridorChessMatch) pathLengths() map[string]int {
+	lengths := make(map[string]int, len(m.gameState.Players))
+	for id, player := range m.gameState.Players {
+		if player.Position != nil {
+			lengths[id] = m.gameState.Board.ShortestPathTo(player.Position, m.goalOf(player))
+		}
+	}
+	return lengths
+}
+
+// staleDrawDetails - 現在の停滞の検出内容を返す
+func (m *QuoridorChessMatch) staleDrawDetails() *StaleDrawDetails {
+	best := make(map[string]int, len(m.staleBest))
+	for id, length := range m.staleBest {
+		best[id] = length
+	}
+	return &StaleDrawDetails{
+		Turns:       m.staleHalfTurns / 2,
+		PathLengths: m.pathLengths(),
+		BestLengths: best,
+	}
+}
+
+// trackStaleness - 手番が移るたびに、壁の配置か最短手数の更新があったかを調べて停滞の長さを数える
+func (m *QuoridorChessMatch) trackStaleness(dispatcher runtime.MatchDispatcher, wallPlaced bool) {
+	if m.ruleset.StaleDrawTurns == 0 {
+		return
+	}
+
+	lengths := m.pathLengths()
+	progress := wallPlaced
+	if wallPlaced {
+		// 壁で盤面が変わったら、その時点の最短手数から数え直す
+		m.staleBest = lengths
+	} else {
+		for id, length := range lengths {
+			if best, ok := m.staleBest[id]; !ok || length < best {
+				m.staleBest[id] = length
+				progress = true
+			}
+		}
+	}
+	if !progress {
+		m.staleHalfTurns++
+		return
+	}
+
+	m.staleHalfTurns = 0
+	// 提案中に進展があれば提案を取り下げる
+	if m.staleDrawOffered {
+		m.staleDrawOffered = false
+		m.drawAccepts = make(map[string]bool)
+		msg := map[string]interface{}{
+			"type": "stale_draw_withdrawn",
+			"data": &StaleDrawWithdrawnData{},
+		}
+		m.sendMessage(dispatcher, 1, msg, nil, true)
+	}
+}
+
+// checkStaleDraw - 停滞が一定のターン数続いた場合に、ルールセットに応じて引き分けを提案するか対局を終了する
+func (m *QuoridorChessMatch) checkStaleDraw(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher) {
+	if !m.gameState.GameStarted || m.ruleset.StaleDrawTurns == 0 || m.staleDrawOffered {
+		return
+	}
+	if m.staleHalfTurns < 2*m.ruleset.StaleDrawTurns {
+		return
+	}
+
+	if m.ruleset.StaleDrawPolicy == StaleDrawPolicyEnforce {
+		m.endStaleDraw(ctx, logger, nk, dispatcher)
+		return
+	}
+
+	m.staleDrawOffered = true
+	details := m.staleDrawDetails()
+	m.recordEvent("stale_draw_offered", EventSourceServer, "", map[string]interface{}{"turns": details.Turns})
+	msg := map[string]interface{}{
+		"type": "stale_draw_offered",
+		"data": details,
+	}
+	m.sendMessage(dispatcher, 1, msg, nil, true)
+}
+
+// handleAcceptDraw - 停滞による引き分けの提案への合意を受け付け、両者が合意したら引き分けで対局を終了する
+func (m *QuoridorChessMatch) handleAcceptDraw(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, userID string) {
+	if m.gameState.Players[userID] == nil {
+		return
+	}
+	if !m.gameState.GameStarted || !m.staleDrawOffered {
+		m.rejectAction(dispatcher, userID, "accept_draw", "no draw has been offered")
+		return
+	}
+	m.drawAccepts[userID] = true
+	m.recordEvent("accept_draw", EventSourcePlayer, userID, nil)
+	for id := range m.gameState.Players {
+		if !m.drawAccepts[id] {
+			return
+		}
+	}
+	m.endStaleDraw(ctx, logger, nk, dispatcher)
+}
+
+// endStaleDraw - 停滞による引き分けで対局を終了し、検出内容とともに全員に game_over を通知する
+func (m *QuoridorChessMatch) endStaleDraw(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher) {
+	details := m.staleDrawDetails()
+	logger.Info("Match %s drawn after %d turns without progress", m.matchID, details.Turns)
+	m.endGame(ctx, logger, nk, "", ResultReasonStaleDraw)
+	msg := map[string]interface{}{
+		"type": "game_over",
+		"data": &GameOverData{
+			Reason:    ResultReasonStaleDraw,
+			GameState: m.gameState,
+			StaleDraw: details,
+		},
+	}
+	m.sendMessage(dispatcher, 1, msg, nil, true)
+}
@@ -0,0 +1,330 @@
+// Quoridor Chess プレイヤー統計
+// 対局数・勝敗（バリアントと速さの区分ごとの内訳を含む）と1手あたりの平均思考時間を記録し、対局ペースの指標として公開する
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	playerStatsCollection = "player_stats" // プレイヤー統計のストレージコレクション
+	playerStatsKey        = "stats"        // プレイヤー統計のストレージキー
+
+	paceMinTimedMoves = 10               // ペースを判定するのに必要な最小手数
+	paceFastThreshold = 5 * time.Second  // これより速ければ "fast"
+	paceSlowThreshold = 20 * time.Second // これより遅ければ "slow"
+)
+
+// 対局ペースの区分
+const (
+	PaceUnknown = "unknown"
+	PaceFast    = "fast"
+	PaceNormal  = "normal"
+	PaceSlow    = "slow"
+)
+
+// 持ち時間による対局の速さの区分
+const (
+	SpeedUntimed   = "untimed"   // 持ち時間なし
+	SpeedBullet    = "bullet"    // 3分未満
+	SpeedBlitz     = "blitz"     // 10分未満
+	SpeedRapid     = "rapid"     // 30分未満
+	SpeedClassical = "classical" // 30分以上
+)
+
+// playerStatsSchemaVersion - プレイヤー統計の保存形式のバージョン（2 からバリアント・速さ別の内訳を持つ）
+const playerStatsSchemaVersion = 2
+
+// PlayerStats - プレイヤーごとの累積統計
+type PlayerStats struct {
+	SchemaVersion   int   `json:"schema_version"`         // 保存形式のバージョン
+	GamesPlayed     int   `json:"games_played"`           // 対局数
+	Wins            int   `json:"wins"`                   // 勝利数
+	Losses          int   `json:"losses"`                 // 敗北数
+	Draws           int   `json:"draws,omitempty"`        // 引き分け数
+	WinStreak       int   `json:"win_streak"`             // 現在の連勝数
+	TotalMoveTimeMs int64 `json:"total_move_time_ms"`     // 思考時間の合計（ミリ秒）
+	TimedMoves      int   `json:"timed_moves"`            // 思考時間を計測した手数
+	WallsPlaced     int   `json:"walls_placed,omitempty"` // 置いた壁の累計枚数
+	// バリアント -> 速さの区分 -> 成績
+	ByVariant map[string]map[string]*CategoryStats `json:"by_variant"`
+	// 初心者用の待ち行列を卒業した時刻（Unix時刻、卒業前は 0）
+	GraduatedAt int64 `json:"graduated_at,omitempty"`
+}
+
+// CategoryStats - バリアントと速さの区分ごとの成績
+type CategoryStats struct {
+	GamesPlayed int     `json:"games_played"`    // 対局数
+	Wins        int     `json:"wins"`            // 勝利数
+	Losses      int     `json:"losses"`          // 敗北数
+	Draws       int     `json:"draws,omitempty"` // 引き分け数
+	WinRate     float64 `json:"win_rate"`        // 勝率（0〜1）
+}
+
+// speedCategory - ルールセットの持ち時間から速さの区分を返す
+// 加算時間は40手分として持ち時間に足して見積もる
+func speedCategory(ruleset *Ruleset) string {
+	if ruleset == nil || ruleset.ClockInitialMs <= 0 {
+		return SpeedUntimed
+	}
+	estimated := time.Duration(ruleset.ClockInitialMs+40*ruleset.ClockIncrementMs) * time.Millisecond
+	switch {
+	case estimated < 3*time.Minute:
+		return SpeedBullet
+	case estimated < 10*time.Minute:
+		return SpeedBlitz
+	case estimated < 30*time.Minute:
+		return SpeedRapid
+	default:
+		return SpeedClassical
+	}
+}
+
+// migrate - 古い形式の統計を現在の形式に変換する
+// バージョン1の統計は内訳を持たないが、当時の対局は持ち時間がなくほぼ標準ルールだったため標準ルール・持ち時間なしに計上する
+func (s *PlayerStats) migrate() {
+	if s.SchemaVersion >= playerStatsSchemaVersion {
+		return
+	}
+	if s.ByVariant == nil {
+		s.ByVariant = make(map[string]map[string]*CategoryStats)
+	}
+	if s.GamesPlayed > 0 && len(s.ByVariant) == 0 {
+		s.ByVariant[VariantStandard] = map[string]*CategoryStats{
+			SpeedUntimed: {GamesPlayed: s.GamesPlayed, Wins: s.Wins, Losses: s.Losses},
+		}
+		s.ByVariant[VariantStandard][SpeedUntimed].updateWinRate()
+	}
+	s.SchemaVersion = playerStatsSchemaVersion
+}
+
+// recordGame - 対局の勝敗をバリアントと速さの区分ごとの成績に加える
+func (s *PlayerStats) recordGame(variant, speed string, won, drawn bool) {
+	if s.ByVariant[variant] == nil {
+		s.ByVariant[variant] = make(map[string]*CategoryStats)
+	}
+	category := s.ByVariant[variant][speed]
+	if category == nil {
+		category = &CategoryStats{}
+		s.ByVariant[variant][speed] = category
+	}
+	category.GamesPlayed++
+	switch {
+	case drawn:
+		category.Draws++
+	case won:
+		category.Wins++
+	default:
+		category.Losses++
+	}
+	category.updateWinRate()
+}
+
+// updateWinRate - 勝率を計算し直す
+func (c *CategoryStats) updateWinRate() {
+	if c.GamesPlayed == 0 {
+		c.WinRate = 0
+		return
+	}
+	c.WinRate = float64(c.Wins) / float64(c.GamesPlayed)
+}
+
+// PlayerStatsResponse - get_player_stats RPCのレスポンス
+type PlayerStatsResponse struct {
+	UserID        string       `json:"user_id"`
+	Stats         *PlayerStats `json:"stats"`
+	AvgMoveTimeMs int64        `json:"avg_move_time_ms"` // 1手あたりの平均思考時間（ミリ秒）
+	Pace          string       `json:"pace"`             // ペース区分
+}
+
+// GetPlayerStatsRequest - get_player_stats RPCのリクエスト
+type GetPlayerStatsRequest struct {
+	UserID string `json:"user_id"` // 対象ユーザー（空の場合は自分）
+}
+
+// moveTiming - マッチ内でのプレイヤーごとの思考時間の集計
+type moveTiming struct {
+	totalMs int64
+	moves   int
+}
+
+// AvgMoveTimeMs - 1手あたりの平均思考時間を返す
+func (s *PlayerStats) AvgMoveTimeMs() int64 {
+	if s.TimedMoves == 0 {
+		return 0
+	}
+	return s.TotalMoveTimeMs / int64(s.TimedMoves)
+}
+
+// Pace - 平均思考時間からペース区分を返す
+func (s *PlayerStats) Pace() string {
+	if s.TimedMoves < paceMinTimedMoves {
+		return PaceUnknown
+	}
+	avg := time.Duration(s.AvgMoveTimeMs()) * time.Millisecond
+	switch {
+	case avg < paceFastThreshold:
+		return PaceFast
+	case avg > paceSlowThreshold:
+		return PaceSlow
+	default:
+		return PaceNormal
+	}
+}
+
+// isValidPace - ペース区分として有効な値かどうかを返す
+func isValidPace(pace string) bool {
+	return pace == PaceFast || pace == PaceNormal || pace == PaceSlow
+}
+
+// readPlayerStats - プレイヤー統計を読み込む（未作成の場合は空の統計、古い形式は現在の形式に変換）
+func readPlayerStats(ctx context.Context, nk runtime.NakamaModule, userID string) (*PlayerStats, string, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: playerStatsCollection,
+		Key:        playerStatsKey,
+		UserID:     userID,
+	}})
+	if err != nil {
+		return nil, "", err
+	}
+
+	stats := &PlayerStats{}
+	if len(objects) > 0 {
+		if err := json.Unmarshal([]byte(objects[0].GetValue()), stats); err != nil {
+			return nil, "", err
+		}
+	}
+	// 古い形式の統計は読み込み時に変換し、次の書き込みで保存される
+	stats.migrate()
+	if len(objects) == 0 {
+		return stats, "", nil
+	}
+	return stats, objects[0].GetVersion(), nil
+}
+
+// writePlayerStats - プレイヤー統計を保存（誰でも閲覧可能）
+func writePlayerStats(ctx context.Context, nk runtime.NakamaModule, userID, version string, stats *PlayerStats) error {
+	value, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+	if version == "" {
+		version = "*"
+	}
+	_, err = nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      playerStatsCollection,
+		Key:             playerStatsKey,
+		UserID:          userID,
+		Value:           string(value),
+		Version:         version,
+		PermissionRead:  2,
+		PermissionWrite: 0,
+	}})
+	return err
+}
+
+// startTurnTimer - 手番が切り替わった時刻を記録
+func (m *QuoridorChessMatch) startTurnTimer() {
+	m.turnStartedAt = time.Now()
+	m.afkWarned = false
+}
+
+// recordMoveTime - 手番開始からの経過時間をプレイヤーの思考時間として集計
+func (m *QuoridorChessMatch) recordMoveTime(userID string) {
+	if m.turnStartedAt.IsZero() {
+		return
+	}
+	timing, ok := m.moveTimings[userID]
+	if !ok {
+		timing = &moveTiming{}
+		m.moveTimings[userID] = timing
+	}
+	timing.totalMs += time.Since(m.turnStartedAt).Milliseconds()
+	timing.moves++
+}
+
+// updatePlayerStats - 対局終了時に両プレイヤーの統計を更新
+func (m *QuoridorChessMatch) updatePlayerStats(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule) {
+	// ボットとの練習対局は統計に含めない
+	if m.vsBot() {
+		return
+	}
+	for userID := range m.gameState.Players {
+		if m.isBot(userID) {
+			continue
+		}
+		stats, version, err := readPlayerStats(ctx, nk, userID)
+		if err != nil {
+			logger.Error("Failed to read stats for %s: %v", userID, err)
+			continue
+		}
+
+		stats.GamesPlayed++
+		drawn := m.gameState.Winner == ""
+		switch {
+		case drawn:
+			stats.Draws++
+		case userID == m.gameState.Winner:
+			stats.Wins++
+		default:
+			stats.Losses++
+		}
+		if userID == m.gameState.Winner {
+			stats.WinStreak++
+		} else {
+			stats.WinStreak = 0
+		}
+		stats.recordGame(m.ruleset.Name, speedCategory(m.ruleset), userID == m.gameState.Winner, drawn)
+		if stats.GraduatedAt == 0 && stats.GamesPlayed >= newcomerGames {
+			stats.GraduatedAt = time.Now().Unix()
+		}
+		if timing, ok := m.moveTimings[userID]; ok {
+			stats.TotalMoveTimeMs += timing.totalMs
+			stats.TimedMoves += timing.moves
+		}
+		stats.WallsPlaced += m.wallsPlaced[userID]
+
+		if err := writePlayerStats(ctx, nk, userID, version, stats); err != nil {
+			logger.Error("Failed to write stats for %s: %v", userID, err)
+		}
+	}
+}
+
+// GetPlayerStats - プレイヤー統計とペース指標を返すRPC
+func GetPlayerStats(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	req := &GetPlayerStatsRequest{}
+	if payload != "" {
+		if err := json.Unmarshal([]byte(payload), req); err != nil {
+			return "", errInvalidPayload
+		}
+	}
+	if req.UserID == "" {
+		userID, err := contextUserID(ctx)
+		if err != nil {
+			return "", err
+		}
+		req.UserID = userID
+	}
+
+	stats, _, err := readPlayerStats(ctx, nk, req.UserID)
+	if err != nil {
+		logger.Error("get_player_stats: failed to read stats: %v", err)
+		return "", runtime.NewError("failed to read stats", errCodeInternal)
+	}
+
+	response, err := json.Marshal(&PlayerStatsResponse{
+		UserID:        req.UserID,
+		Stats:         stats,
+		AvgMoveTimeMs: stats.AvgMoveTimeMs(),
+		Pace:          stats.Pace(),
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
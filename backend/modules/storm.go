@@ -0,0 +1,134 @@
+// Quoridor Chess 一斉切断への対応
+// ネットワーク分断やノードの障害で多数のプレイヤーが同時に切断した場合、モジュール全体で「切断の嵐」として検出し、
+// 影響を受けたマッチでは猶予期間のあいだ持ち時間と切断・放置による負けを止めて、無関係なプレイヤーが一斉に負けにならないようにする
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	stormWindow    = 10 * time.Second // 切断を数える時間幅
+	stormThreshold = 20               // 時間幅の中でこの数以上の対局中の切断があれば切断の嵐とみなす
+	stormGrace     = 2 * time.Minute  // 最後に嵐を検出してから持ち時間と負けの判定を止めておく時間
+)
+
+// PauseReasonDisconnectStorm - 切断の嵐による一時停止の理由
+const PauseReasonDisconnectStorm = "disconnect_storm"
+
+// disconnectStorm - モジュール全体の対局中の切断の発生状況（各マッチのハンドラーから共有する）
+var disconnectStorm = &stormDetector{}
+
+// stormDetector - 直近の切断時刻から切断の嵐を検出する
+type stormDetector struct {
+	mu     sync.Mutex
+	recent []time.Time // 時間幅の中の切断時刻（古い順）
+	until  time.Time   // この時刻まで切断の嵐として扱う
+}
+
+// recordDisconnect - 対局中の切断を記録し、これにより切断の嵐が始まった場合は true を返す
+func (s *stormDetector) recordDisconnect(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := now.Add(-stormWindow)
+	kept := s.recent[:0]
+	for _, at := range s.recent {
+		if at.After(cutoff) {
+			kept = append(kept, at)
+		}
+	}
+	s.recent = append(kept, now)
+	if len(s.recent) < stormThreshold {
+		return false
+	}
+	started := !now.Before(s.until)
+	s.until = now.Add(stormGrace)
+	return started
+}
+
+// activeUntil - 切断の嵐が続いている場合はその終了予定時刻を返す（嵐でなければゼロ値）
+func (s *stormDetector) activeUntil(now time.Time) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Before(s.until) {
+		return s.until
+	}
+	return time.Time{}
+}
+
+// noteStormDisconnect - 対局中の切断をモジュール全体の切断の嵐の検出に加える
+func noteStormDisconnect(logger runtime.Logger, nk runtime.NakamaModule, now time.Time) {
+	if disconnectStorm.recordDisconnect(now) {
+		logger.Warn("Disconnect storm detected: %d or more in-game disconnects within %s, pausing affected matches", stormThreshold, stormWindow)
+		nk.MetricsCounterAdd("quoridor_disconnect_storms", nil, 1)
+	}
+}
+
+// checkDisconnectStorm - 切断の嵐の間、再接続待ちのプレイヤーがいるマッチを一時停止し、嵐が収まったら再開する
+// 一時停止中は true を返し、呼び出し側は持ち時間と負けの判定を行わない
+func (m *QuoridorChessMatch) checkDisconnectStorm(dispatcher runtime.MatchDispatcher) bool {
+	now := time.Now()
+	if m.stormPausedAt.IsZero() {
+		if !m.gameState.GameStarted || len(m.disconnectedAt) == 0 {
+			return false
+		}
+		until := disconnectStorm.activeUntil(now)
+		if until.IsZero() {
+			return false
+		}
+		// 止めるまでに経過した時間は持ち時間から精算しておく
+		m.chargeClock()
+		m.stormPausedAt, m.stormShiftedAt = now, now
+		m.recordEvent("clocks_paused", EventSourceServer, "", map[string]interface{}{"reason": PauseReasonDisconnectStorm})
+		msg := map[string]interface{}{
+			"type": "clocks_paused",
+			"data": &ClocksPausedData{Reason: PauseReasonDisconnectStorm, ResumeAt: until.UnixMilli()},
+		}
+		m.sendMessage(dispatcher, 1, msg, nil, true)
+		return true
+	}
+
+	m.shiftPausedTimers(now)
+	if m.gameState.GameStarted && !disconnectStorm.activeUntil(now).IsZero() {
+		return true
+	}
+
+	paused := now.Sub(m.stormPausedAt)
+	m.stormPausedAt, m.stormShiftedAt = time.Time{}, time.Time{}
+	if !m.gameState.GameStarted {
+		return false
+	}
+	m.recordEvent("clocks_resumed", EventSourceServer, "", map[string]interface{}{"paused_ms": paused.Milliseconds()})
+	msg := map[string]interface{}{
+		"type": "clocks_resumed",
+		"data": &ClocksResumedData{PausedMs: paused.Milliseconds()},
+	}
+	m.sendMessage(dispatcher, 1, msg, nil, true)
+	return false
+}
+
+// shiftPausedTimers - 一時停止中に経過した時間の分だけ、持ち時間・手番・放置・再接続の猶予の起点をずらす
+// 一時停止中に指された手で起点が更新されることがあるため、ティックごとに差分だけずらす
+func (m *QuoridorChessMatch) shiftPausedTimers(now time.Time) {
+	elapsed := now.Sub(m.stormShiftedAt)
+	m.stormShiftedAt = now
+	if !m.clockUpdatedAt.IsZero() {
+		m.clockUpdatedAt = now
+	}
+	if !m.turnStartedAt.IsZero() {
+		m.turnStartedAt = m.turnStartedAt.Add(elapsed)
+	}
+	if !m.turnChangedAt.IsZero() {
+		m.turnChangedAt = m.turnChangedAt.Add(elapsed)
+	}
+	for id, at := range m.lastActivity {
+		m.lastActivity[id] = at.Add(elapsed)
+	}
+	for id, at := range m.disconnectedAt {
+		m.disconnectedAt[id] = at.Add(elapsed)
+	}
+}
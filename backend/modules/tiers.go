@@ -0,0 +1,244 @@
+// Quoridor Chess ティアとディビジョン
+// レーティングをブロンズからマスターまでの名前付きのティアと、その中のディビジョンに対応させる
+// ティアの境目では昇格戦・降格戦（3局中2勝/2敗）を挟み、結果は通知で知らせる。ティアは配置戦を終えてから付く
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	NotificationCodeTierChanged = 105 // ティア・ディビジョンの変化や昇格戦・降格戦の通知
+)
+
+const (
+	tierDivisions     = 4  // マスター以外のティアのディビジョン数（1 が最上位）
+	tierDivisionWidth = 50 // 1ディビジョンのレーティングの幅
+	tierSeriesGames   = 3  // 昇格戦・降格戦の対局数
+	tierSeriesNeeded  = 2  // 昇格戦・降格戦の決着に必要な勝ち数（負け数）
+)
+
+// ティア（下位から順、floor はそのティアになる最低のレーティング）
+var tierDefinitions = []struct {
+	name  string
+	floor int
+}{
+	{"bronze", 0},
+	{"silver", 1100},
+	{"gold", 1300},
+	{"platinum", 1500},
+	{"diamond", 1700},
+	{"master", 1900}, // 最上位のティアにはディビジョンがない
+}
+
+// 昇格戦・降格戦の種類
+const (
+	TierSeriesPromotion = "promotion" // 上のティアへの昇格戦（2勝で昇格）
+	TierSeriesDemotion  = "demotion"  // 下のティアへの降格戦（2敗で降格）
+)
+
+// 対局によるティアの変化
+const (
+	TierEventPlaced          = "placed"           // 配置戦を終えて最初のティアが付いた
+	TierEventDivisionUp      = "division_up"      // 同じティアの中でディビジョンが上がった
+	TierEventDivisionDown    = "division_down"    // 同じティアの中でディビジョンが下がった
+	TierEventPromotionSeries = "promotion_series" // 昇格戦が始まった
+	TierEventDemotionSeries  = "demotion_series"  // 降格戦が始まった
+	TierEventPromoted        = "promoted"         // 昇格戦に勝って昇格した
+	TierEventDemoted         = "demoted"          // 降格戦に負けて降格した
+	TierEventSeriesFailed    = "series_failed"    // 昇格戦に敗れた
+	TierEventSeriesSurvived  = "series_survived"  // 降格戦をしのいだ
+)
+
+// TierRank - ティアとディビジョン
+type TierRank struct {
+	Tier     string `json:"tier"`     // ティア名（"bronze" 〜 "master"）
+	Division int    `json:"division"` // ディビジョン（1 が最上位、マスターは 0）
+}
+
+// TierSeries - 進行中の昇格戦・降格戦
+type TierSeries struct {
+	Kind   string   `json:"kind"`   // "promotion" / "demotion"
+	Target TierRank `json:"target"` // 決着したときに移るティアとディビジョン
+	Wins   int      `json:"wins"`   // 勝ち数
+	Losses int      `json:"losses"` // 負け数（引き分けは数えない）
+}
+
+// PlayerTier - 保存するプレイヤーのティア
+type PlayerTier struct {
+	TierRank
+	Series *TierSeries `json:"series,omitempty"` // 進行中の昇格戦・降格戦
+}
+
+// tierIndex - ティアの順位（下位から 0）を返す
+func tierIndex(name string) int {
+	for i, definition := range tierDefinitions {
+		if definition.name == name {
+			return i
+		}
+	}
+	return 0
+}
+
+// tierTop - ティアの最上位のディビジョン
+func tierTop(index int) TierRank {
+	if index == len(tierDefinitions)-1 {
+		return TierRank{Tier: tierDefinitions[index].name}
+	}
+	return TierRank{Tier: tierDefinitions[index].name, Division: 1}
+}
+
+// tierBottom - ティアの最下位のディビジョン
+func tierBottom(index int) TierRank {
+	if index == len(tierDefinitions)-1 {
+		return TierRank{Tier: tierDefinitions[index].name}
+	}
+	return TierRank{Tier: tierDefinitions[index].name, Division: tierDivisions}
+}
+
+// tierForRating - レーティングに対応するティアとディビジョンを返す
+// ディビジョンはティアの上端から tierDivisionWidth ずつ数え、最下位のディビジョンは下限なし
+func tierForRating(rating int) TierRank {
+	index := 0
+	for i, definition := range tierDefinitions {
+		if rating >= definition.floor {
+			index = i
+		}
+	}
+	if index == len(tierDefinitions)-1 {
+		return tierTop(index)
+	}
+	division := 1 + (tierDefinitions[index+1].floor-1-rating)/tierDivisionWidth
+	if division > tierDivisions {
+		division = tierDivisions
+	}
+	return TierRank{Tier: tierDefinitions[index].name, Division: division}
+}
+
+// order - ティアとディビジョンの並び順（大きいほど上位）
+func (r TierRank) order() int {
+	return tierIndex(r.Tier)*(tierDivisions+1) + (tierDivisions - r.Division)
+}
+
+// update - 対局後のレーティングと得点（勝ち 1、引き分け 0.5、負け 0）からティアを更新し、起きた変化を返す
+// 昇格戦・降格戦の間はレーティングに関係なく、その結果だけでティアが決まる
+func (t *PlayerTier) update(rating int, score float64) string {
+	if series := t.Series; series != nil {
+		if score == 1 {
+			series.Wins++
+		} else if score == 0 {
+			series.Losses++
+		}
+		if series.Kind == TierSeriesPromotion {
+			if series.Wins >= tierSeriesNeeded {
+				t.TierRank, t.Series = series.Target, nil
+				return TierEventPromoted
+			}
+			if series.Losses > tierSeriesGames-tierSeriesNeeded {
+				t.Series = nil
+				return TierEventSeriesFailed
+			}
+			return ""
+		}
+		if series.Losses >= tierSeriesNeeded {
+			t.TierRank, t.Series = series.Target, nil
+			return TierEventDemoted
+		}
+		if series.Wins > tierSeriesGames-tierSeriesNeeded {
+			t.Series = nil
+			return TierEventSeriesSurvived
+		}
+		return ""
+	}
+
+	next := tierForRating(rating)
+	current := tierIndex(t.Tier)
+	switch {
+	case next.Tier == t.Tier:
+		if next.Division == t.Division {
+			return ""
+		}
+		event := TierEventDivisionDown
+		if next.order() > t.order() {
+			event = TierEventDivisionUp
+		}
+		t.Division = next.Division
+		return event
+	case next.order() > t.order():
+		// ティアの境目を越えたら、今のティアの最上位に留めて昇格戦を始める
+		t.TierRank = tierTop(current)
+		t.Series = &TierSeries{Kind: TierSeriesPromotion, Target: tierBottom(current + 1)}
+		return TierEventPromotionSeries
+	default:
+		t.TierRank = tierBottom(current)
+		t.Series = &TierSeries{Kind: TierSeriesDemotion, Target: tierTop(current - 1)}
+		return TierEventDemotionSeries
+	}
+}
+
+// displayRating - ティアの判定に使うレーティング（表示に使うレーティング方式の値）
+func (r *PlayerRating) displayRating(system string) int {
+	if system == RatingSystemGlicko2 {
+		return int(r.Glicko.Rating + 0.5)
+	}
+	return r.Rating
+}
+
+// currentTier - 今シーズンのティアを返す（配置戦を終えていない場合は nil）
+func (r *PlayerRating) currentTier(now time.Time) *PlayerTier {
+	if r.Season != currentSeason(now) {
+		return nil
+	}
+	return r.Tier
+}
+
+// updateTier - レーティング戦の結果をティアに反映し、起きた変化を返す
+// 配置戦を終えた対局で最初のティアを付け、それ以降は昇格戦・降格戦を含めて1局ずつ更新する
+func (r *PlayerRating) updateTier(system string, score float64, now time.Time) string {
+	if r.inPlacement(now) {
+		return ""
+	}
+	if r.Tier == nil {
+		r.Tier = &PlayerTier{TierRank: tierForRating(r.displayRating(system))}
+		return TierEventPlaced
+	}
+	return r.Tier.update(r.displayRating(system), score)
+}
+
+// loadTier - 参加したプレイヤーのレーティングを読み込み、対局開始の通知に含めるティアを記録する
+// 配置戦中のプレイヤーが参加したレーティング戦はラベルに配置戦として記録する
+func (m *QuoridorChessMatch) loadTier(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, userID string) {
+	if m.anonymous {
+		return
+	}
+	rating, _, err := readRating(ctx, nk, userID)
+	if err != nil {
+		logger.Warn("Failed to read rating of %s for tier: %v", userID, err)
+		return
+	}
+	now := time.Now()
+	if tier := rating.currentTier(now); tier != nil {
+		m.tiers[userID] = tier
+	}
+	m.updatePlacementLabel(dispatcher, rating, now)
+}
+
+// notifyTierChanges - レーティングの保存後に、ティアが変化したプレイヤーへ通知する
+func (m *QuoridorChessMatch) notifyTierChanges(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, changes map[string]*RatingChange) {
+	for id, change := range changes {
+		if change.TierEvent == "" {
+			continue
+		}
+		content := map[string]interface{}{
+			"match_id": m.matchID,
+			"event":    change.TierEvent,
+			"tier":     change.Tier,
+		}
+		if err := nk.NotificationSend(ctx, id, "Tier updated", content, NotificationCodeTierChanged, "", true); err != nil {
+			logger.Warn("Failed to notify %s of tier change: %v", id, err)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+// Quoridor Chess 称号とフレア
+// 対局の節目やトーナメントの優勝で称号を贈り、プレイヤーが選んだ称号を「フレア」として名前の横に表示させる
+// 選べるのは獲得済みの称号だけで、選択はサーバーで検証して保存する
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	titlesCollection = "player_titles" // 称号のストレージコレクション（キーは titlesKey、所有者はプレイヤー、書き込みはサーバーのみ）
+	titlesKey        = "titles"
+
+	NotificationCodeTitleEarned = 106 // 称号を獲得したことの通知
+
+	wallMasterWalls = 100 // 「Wall Master」に必要な壁の累計枚数
+	marathonPly     = 80  // 「Marathoner」に必要な1局の手数
+)
+
+// 節目で獲得する称号
+const (
+	TitleWallMaster = "wall_master" // 壁を累計 wallMasterWalls 枚置いた
+	TitleMarathoner = "marathoner"  // marathonPly 手以上の対局を最後まで指した
+)
+
+// titleNames - 節目で獲得する称号の表示名
+var titleNames = map[string]string{
+	TitleWallMaster: "Wall Master",
+	TitleMarathoner: "Marathoner",
+}
+
+// championTitlePrefix - トーナメントの優勝の称号のIDの接頭辞（続けてトーナメントID）
+const championTitlePrefix = "champion:"
+
+// EarnedTitle - 獲得した称号
+type EarnedTitle struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`      // 表示名（例: "Season 1 Champion"）
+	EarnedAt int64  `json:"earned_at"` // 獲得した時刻（Unix時刻）
+}
+
+// PlayerTitles - 保存するプレイヤーの称号
+type PlayerTitles struct {
+	Earned   []*EarnedTitle `json:"earned"`   // 獲得した称号（獲得順）
+	Selected string         `json:"selected"` // フレアとして表示する称号のID（空の場合は表示しない）
+}
+
+// Flair - 名前の横に表示する称号
+type Flair struct {
+	TitleID string `json:"title_id"`
+	Name    string `json:"name"`
+}
+
+// SetFlairRequest - set_flair RPCのリクエスト
+type SetFlairRequest struct {
+	TitleID string `json:"title_id"` // 獲得済みの称号のID（空の場合はフレアを外す）
+}
+
+// GetTitlesRequest - get_titles RPCのリクエスト
+type GetTitlesRequest struct {
+	UserID string `json:"user_id"` // 対象ユーザー（空の場合は自分）
+}
+
+// title - 獲得済みの称号を返す（未獲得の場合は nil）
+func (t *PlayerTitles) title(id string) *EarnedTitle {
+	for _, title := range t.Earned {
+		if title.ID == id {
+			return title
+		}
+	}
+	return nil
+}
+
+// flair - フレアとして表示する称号を返す（選んでいない場合は nil）
+func (t *PlayerTitles) flair() *Flair {
+	title := t.title(t.Selected)
+	if title == nil {
+		return nil
+	}
+	return &Flair{TitleID: title.ID, Name: title.Name}
+}
+
+// readPlayerTitles - プレイヤーの称号を読み込む（未作成の場合は空）
+func readPlayerTitles(ctx context.Context, nk runtime.NakamaModule, userID string) (*PlayerTitles, string, error) {
+	titles := &PlayerTitles{Earned: []*EarnedTitle{}}
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: titlesCollection,
+		Key:        titlesKey,
+		UserID:     userID,
+	}})
+	if err != nil {
+		return nil, "", err
+	}
+	if len(objects) == 0 {
+		return titles, "", nil
+	}
+	if err := json.Unmarshal([]byte(objects[0].GetValue()), titles); err != nil {
+		return nil, "", err
+	}
+	return titles, objects[0].GetVersion(), nil
+}
+
+// writePlayerTitles - プレイヤーの称号を保存（誰でも閲覧可能、書き込みはサーバーのみ）
+func writePlayerTitles(ctx context.Context, nk runtime.NakamaModule, userID, version string, titles *PlayerTitles) error {
+	value, err := json.Marshal(titles)
+	if err != nil {
+		return err
+	}
+	if version == "" {
+		version = "*"
+	}
+	_, err = nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      titlesCollection,
+		Key:             titlesKey,
+		UserID:          userID,
+		Value:           string(value),
+		Version:         version,
+		PermissionRead:  2,
+		PermissionWrite: 0,
+	}})
+	return err
+}
+
+// readFlairs - 複数のプレイヤーのフレアをまとめて読み込む（フレアのないプレイヤーは含めない）
+func readFlairs(ctx context.Context, nk runtime.NakamaModule, userIDs []string) (map[string]*Flair, error) {
+	flairs := make(map[string]*Flair, len(userIDs))
+	if len(userIDs) == 0 {
+		return flairs, nil
+	}
+	reads := make([]*runtime.StorageRead, 0, len(userIDs))
+	for _, userID := range userIDs {
+		reads = append(reads, &runtime.StorageRead{Collection: titlesCollection, Key: titlesKey, UserID: userID})
+	}
+	objects, err := nk.StorageRead(ctx, reads)
+	if err != nil {
+		return nil, err
+	}
+	for _, object := range objects {
+		titles := &PlayerTitles{}
+		if err := json.Unmarshal([]byte(object.GetValue()), titles); err != nil {
+			continue
+		}
+		if flair := titles.flair(); flair != nil {
+			flairs[object.GetUserId()] = flair
+		}
+	}
+	return flairs, nil
+}
+
+// awardTitles - 称号を獲得済みでなければ贈り、獲得を本人に通知する
+func awardTitles(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID string, earned []*EarnedTitle) {
+	if len(earned) == 0 {
+		return
+	}
+	titles, version, err := readPlayerTitles(ctx, nk, userID)
+	if err != nil {
+		logger.Error("Failed to read titles of %s: %v", userID, err)
+		return
+	}
+	added := make([]*EarnedTitle, 0, len(earned))
+	for _, title := range earned {
+		if titles.title(title.ID) == nil {
+			titles.Earned = append(titles.Earned, title)
+			added = append(added, title)
+		}
+	}
+	if len(added) == 0 {
+		return
+	}
+	if err := writePlayerTitles(ctx, nk, userID, version, titles); err != nil {
+		logger.Error("Failed to store titles of %s: %v", userID, err)
+		return
+	}
+	for _, title := range added {
+		content := map[string]interface{}{
+			"title_id": title.ID,
+			"name":     title.Name,
+		}
+		if err := nk.NotificationSend(ctx, userID, "Title earned", content, NotificationCodeTitleEarned, "", true); err != nil {
+			logger.Warn("Failed to notify %s of title %s: %v", userID, title.ID, err)
+		}
+	}
+}
+
+// awardMilestoneTitles - 対局終了時に、節目に達したプレイヤーに称号を贈る
+// ボットとの練習対局とプレイテストは対象外
+func (m *QuoridorChessMatch) awardMilestoneTitles(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule) {
+	if m.vsBot() || m.playtest || m.gameState.ResultReason == ResultReasonAborted {
+		return
+	}
+	now := time.Now().Unix()
+	for userID := range m.gameState.Players {
+		if m.isBot(userID) {
+			continue
+		}
+		earned := make([]*EarnedTitle, 0, 2)
+		if m.ply() >= marathonPly {
+			earned = append(earned, &EarnedTitle{ID: TitleMarathoner, Name: titleNames[TitleMarathoner], EarnedAt: now})
+		}
+		if stats, _, err := readPlayerStats(ctx, nk, userID); err != nil {
+			logger.Warn("Failed to read stats of %s for titles: %v", userID, err)
+		} else if stats.WallsPlaced >= wallMasterWalls {
+			earned = append(earned, &EarnedTitle{ID: TitleWallMaster, Name: titleNames[TitleWallMaster], EarnedAt: now})
+		}
+		awardTitles(ctx, logger, nk, userID, earned)
+	}
+}
+
+// onTournamentEnd - トーナメントの終了時に、優勝者へ称号を贈ってお知らせを配信する
+func onTournamentEnd(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, tournament *api.Tournament, end, reset int64) error {
+	awardChampionTitle(ctx, logger, nk, tournament)
+	return announceTournamentEnd(ctx, logger, db, nk, tournament, end, reset)
+}
+
+// awardChampionTitle - トーナメントの終了時に、1位のプレイヤーに「<トーナメント名> Champion」の称号を贈る
+func awardChampionTitle(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, tournament *api.Tournament) {
+	records, _, _, _, err := nk.TournamentRecordsList(ctx, tournament.GetId(), nil, 1, "", 0)
+	if err != nil {
+		logger.Warn("Failed to list records of tournament %s for titles: %v", tournament.GetId(), err)
+		return
+	}
+	if len(records) == 0 {
+		return
+	}
+	awardTitles(ctx, logger, nk, records[0].GetOwnerId(), []*EarnedTitle{{
+		ID:       championTitlePrefix + tournament.GetId(),
+		Name:     tournament.GetTitle() + " Champion",
+		EarnedAt: time.Now().Unix(),
+	}})
+}
+
+// loadFlair - 参加したプレイヤーのフレアを読み込み、対局開始の通知に含める
+func (m *QuoridorChessMatch) loadFlair(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID string) {
+	if m.anonymous {
+		return
+	}
+	titles, _, err := readPlayerTitles(ctx, nk, userID)
+	if err != nil {
+		logger.Warn("Failed to read titles of %s for flair: %v", userID, err)
+		return
+	}
+	if flair := titles.flair(); flair != nil {
+		m.flairs[userID] = flair
+	}
+}
+
+// GetTitles - プレイヤーの獲得した称号と選んでいるフレアを返すRPC
+func GetTitles(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	req := &GetTitlesRequest{}
+	if payload != "" {
+		if err := json.Unmarshal([]byte(payload), req); err != nil {
+			return "", errInvalidPayload
+		}
+	}
+	if req.UserID == "" {
+		userID, err := contextUserID(ctx)
+		if err != nil {
+			return "", err
+		}
+		req.UserID = userID
+	}
+
+	titles, _, err := readPlayerTitles(ctx, nk, req.UserID)
+	if err != nil {
+		logger.Error("get_titles: failed to read titles: %v", err)
+		return "", runtime.NewError("failed to read titles", errCodeInternal)
+	}
+
+	response, err := json.Marshal(map[string]interface{}{
+		"user_id": req.UserID,
+		"earned":  titles.Earned,
+		"flair":   titles.flair(),
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
+
+// SetFlair - 獲得済みの称号からフレアを選ぶRPC（空の title_id でフレアを外す）
+func SetFlair(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := contextUserID(ctx)
+	if err != nil {
+		return "", err
+	}
+	req := &SetFlairRequest{}
+	if err := json.Unmarshal([]byte(payload), req); err != nil {
+		return "", errInvalidPayload
+	}
+
+	titles, version, err := readPlayerTitles(ctx, nk, userID)
+	if err != nil {
+		logger.Error("set_flair: failed to read titles: %v", err)
+		return "", runtime.NewError("failed to read titles", errCodeInternal)
+	}
+	if req.TitleID != "" && titles.title(req.TitleID) == nil {
+		return "", runtime.NewError("title has not been earned", errCodePermissionDenied)
+	}
+	titles.Selected = req.TitleID
+	if err := writePlayerTitles(ctx, nk, userID, version, titles); err != nil {
+		logger.Error("set_flair: failed to store titles: %v", err)
+		return "", runtime.NewError("failed to store flair", errCodeInternal)
+	}
+
+	response, err := json.Marshal(map[string]interface{}{"flair": titles.flair()})
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
@@ -0,0 +1,66 @@
+// Quoridor Chess トーナメント連携
+// tournament_id 付きで作成されたマッチの結果を Nakama のトーナメントに自動で記録し、両プレイヤーに順位を通知する
+package main
+
+import (
+	"context"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	NotificationCodeTournamentResult = 102 // トーナメントの対局結果と現在の順位の通知
+
+	tournamentWinScore = 1 // 勝者に加算するスコア（トーナメントの演算子は "incr" を想定）
+)
+
+// submitTournamentResult - 対局結果をトーナメントのスコアとして記録し、両プレイヤーに現在の順位を通知
+func (m *QuoridorChessMatch) submitTournamentResult(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule) {
+	tournamentID := m.gameState.TournamentID
+	if tournamentID == "" {
+		return
+	}
+
+	// トーナメントの記録には別名ではなく実際のユーザー名を使う
+	userIDs := make([]string, 0, len(m.gameState.Players))
+	for id := range m.gameState.Players {
+		userIDs = append(userIDs, id)
+	}
+	usernames := make(map[string]string, len(userIDs))
+	if users, err := nk.UsersGetId(ctx, userIDs, nil); err == nil {
+		for _, user := range users {
+			usernames[user.GetId()] = user.GetUsername()
+		}
+	} else {
+		logger.Warn("Failed to look up usernames for tournament %s: %v", tournamentID, err)
+	}
+
+	for _, userID := range userIDs {
+		won := userID == m.gameState.Winner
+		score := int64(0)
+		if won {
+			score = tournamentWinScore
+		}
+		metadata := map[string]interface{}{
+			"match_id": m.matchID,
+			"won":      won,
+			"reason":   m.gameState.ResultReason,
+		}
+		record, err := nk.TournamentRecordWrite(ctx, tournamentID, userID, usernames[userID], score, 0, metadata, nil)
+		if err != nil {
+			logger.Error("Failed to write tournament %s record for %s: %v", tournamentID, userID, err)
+			continue
+		}
+
+		content := map[string]interface{}{
+			"tournament_id": tournamentID,
+			"match_id":      m.matchID,
+			"won":           won,
+			"score":         record.GetScore(),
+			"rank":          record.GetRank(),
+		}
+		if err := nk.NotificationSend(ctx, userID, "Tournament result recorded", content, NotificationCodeTournamentResult, "", true); err != nil {
+			logger.Warn("Failed to notify %s of tournament result: %v", userID, err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Quoridor Chess トレーニングモード
+// カジュアル戦で有効化したプレイヤーの移動を評価し、悪手の場合に本人だけへ警告を送る
+package main
+
+import "github.com/heroiclabs/nakama-common/runtime"
+
+// goalRow - プレイヤーの色に対応するゴール行を返す（白は上端、黒は下端を目指す）
+func goalRow(color string) int {
+	if color == "white" {
+		return 0
+	}
+	return 8
+}
+
+// evaluatePosition - プレイヤー視点の評価値（相手の最短経路長 - 自分の最短経路長）を返す
+// 値が大きいほどプレイヤーに有利
+func (m *QuoridorChessMatch) evaluatePosition(player *Player, position *Position) int {
+	board := m.gameState.Board
+	own := board.ShortestPathTo(position, m.goalOf(player))
+
+	opponentPath := 0
+	for id, other := range m.gameState.Players {
+		if id == player.ID {
+			continue
+		}
+		opponentPath = board.ShortestPathTo(other.Position, m.goalOf(other))
+	}
+
+	return opponentPath - own
+}
+
+// evaluateMoveLoss - 指定位置への移動の評価値が、最善の移動と比べて何手分悪いかを返す（最善の移動なら0）
+// 最善の移動と比べるため、壁を迂回する最短経路に沿った移動は悪手とみなさない
+func (m *QuoridorChessMatch) evaluateMoveLoss(player *Player, newX, newY int) int {
+	after := m.evaluatePosition(player, &Position{X: newX, Y: newY})
+	best := after
+	for _, move := range m.legalMoves(player) {
+		move := move
+		if score := m.evaluatePosition(player, &move); score > best {
+			best = score
+		}
+	}
+	return best - after
+}
+
+// sendBlunderWarning - 悪手の警告を移動したプレイヤー本人だけに送信
+// クライアントは "confirm": true を付けて同じ移動を再送信すると移動を確定できる
+func (m *QuoridorChessMatch) sendBlunderWarning(dispatcher runtime.MatchDispatcher, userID string, x, y, loss int) {
+	presence, ok := m.presences[userID]
+	if !ok {
+		return
+	}
+
+	msg := map[string]interface{}{
+		"type": "blunder_warning",
+		"data": map[string]interface{}{
+			"position": &Position{X: x, Y: y}, // 保留中の移動先
+			"loss":     loss,                  // 評価値（経路差）の悪化幅
+		},
+	}
+	m.sendMessage(dispatcher, 1, msg, []runtime.Presence{presence}, true)
+}
+
+// handleSetTrainingMode - トレーニングモードの切り替え要求を処理
+// レーティング対象の対局では無効
+func (m *QuoridorChessMatch) handleSetTrainingMode(dispatcher runtime.MatchDispatcher, userID string, data map[string]interface{}) {
+	if m.gameState.Ranked {
+		return
+	}
+
+	player := m.gameState.Players[userID]
+	if player == nil {
+		return
+	}
+
+	enabled, ok := data["enabled"].(bool)
+	if !ok {
+		return
+	}
+	player.TrainingMode = enabled
+
+	// 切り替え結果を本人に通知
+	presence, ok := m.presences[userID]
+	if !ok {
+		return
+	}
+	msg := map[string]interface{}{
+		"type": "training_mode_updated",
+		"data": map[string]interface{}{
+			"enabled": enabled,
+		},
+	}
+	m.sendMessage(dispatcher, 1, msg, []runtime.Presence{presence}, true)
+}
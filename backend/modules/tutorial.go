@@ -0,0 +1,105 @@
+// Quoridor Chess チュートリアルのヒント
+// 始めたばかりのプレイヤーの手番で、サーバーから見た局面に応じたヒントを本人だけに送る
+package main
+
+import (
+	"context"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	tutorialGames         = 5 // この対局数に達するまでヒントを送る
+	tutorialNearGoalMoves = 3 // 相手のゴールまでの手数がこの値以下なら壁を勧める
+	tutorialLowWalls      = 3 // 残り壁数がこの値以下になったら知らせる
+)
+
+// ヒントの種類（1局につき各1回まで）
+const (
+	TipOpponentNearGoal = "opponent_near_goal" // 相手がゴールに近い
+	TipWallsLow         = "walls_low"          // 残り壁が少ない
+	TipNoWalls          = "no_walls"           // 壁を使い切った
+	TipJumpAvailable    = "jump_available"     // 相手のコマを飛び越えられる
+)
+
+// loadTutorial - 対局数が少なく、ヒントを無効にしていないプレイヤーをヒントの対象にする
+func (m *QuoridorChessMatch) loadTutorial(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID string) {
+	if settings := m.settings[userID]; settings != nil && settings.DisableTips {
+		return
+	}
+	stats, _, err := readPlayerStats(ctx, nk, userID)
+	if err != nil {
+		logger.Warn("Failed to read stats for tutorial of %s: %v", userID, err)
+		return
+	}
+	if stats.GamesPlayed < tutorialGames {
+		m.tutorialTips[userID] = make(map[string]bool)
+	}
+}
+
+// turnTips - 手番のプレイヤーに当てはまるヒントを返す
+func (m *QuoridorChessMatch) turnTips(player *Player) []*TutorialTipData {
+	tips := make([]*TutorialTipData, 0)
+	opponent := m.gameState.Players[m.opponentOf(player.ID)]
+	board := m.gameState.Board
+
+	if opponent != nil && player.Walls > 0 {
+		if moves := board.ShortestPathTo(opponent.Position, m.goalOf(opponent)); moves >= 0 && moves <= tutorialNearGoalMoves {
+			tips = append(tips, &TutorialTipData{
+				Tip:     TipOpponentNearGoal,
+				Message: "Your opponent is close to their goal. Placing a wall can slow them down.",
+				Params:  map[string]int{"opponent_moves": moves},
+			})
+		}
+	}
+	switch {
+	case player.Walls == 0:
+		tips = append(tips, &TutorialTipData{
+			Tip:     TipNoWalls,
+			Message: "You have no walls left. Race to your goal!",
+			Params:  map[string]int{"walls": 0},
+		})
+	case player.Walls <= tutorialLowWalls:
+		tips = append(tips, &TutorialTipData{
+			Tip:     TipWallsLow,
+			Message: "You are running low on walls. Save them for when they matter most.",
+			Params:  map[string]int{"walls": player.Walls},
+		})
+	}
+	for _, move := range m.legalMoves(player) {
+		if abs(move.X-player.Position.X)+abs(move.Y-player.Position.Y) > 1 {
+			tips = append(tips, &TutorialTipData{
+				Tip:     TipJumpAvailable,
+				Message: "You can jump over your opponent's pawn here.",
+			})
+			break
+		}
+	}
+	return tips
+}
+
+// sendTurnTips - 手番が始まったプレイヤーがヒントの対象であれば、まだ送っていないヒントを本人だけに送る
+func (m *QuoridorChessMatch) sendTurnTips(dispatcher runtime.MatchDispatcher) {
+	if !m.gameState.GameStarted {
+		return
+	}
+	userID := m.gameState.CurrentTurn
+	sent, ok := m.tutorialTips[userID]
+	player := m.gameState.Players[userID]
+	presence, online := m.presences[userID]
+	if !ok || player == nil || !online {
+		return
+	}
+
+	for _, tip := range m.turnTips(player) {
+		if sent[tip.Tip] {
+			continue
+		}
+		sent[tip.Tip] = true
+		msg := map[string]interface{}{
+			"type": "tutorial_tip",
+			"data": tip,
+		}
+		m.sendMessage(dispatcher, 1, msg, []runtime.Presence{presence}, false)
+	}
+}
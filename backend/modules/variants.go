@@ -0,0 +1,157 @@
+// Quoridor Chess バリアントとルールセット
+// マッチ作成時のパラメータから適用するルールを決め、勝利条件をバリアントごとに切り替える
+package main
+
+import (
+	"context"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// バリアント名
+const (
+	VariantStandard = "standard" // 標準ルール（相手側の端の行に到達したら勝ち）
+	VariantFlag     = "flag"     // 旗取り（相手側の端の中央のマスに到達したら勝ち）
+	VariantDecay    = "decay"    // 壁の風化（置いた壁が一定のターン数で消える、カジュアル戦の早指し向け）
+	VariantPush     = "push"     // 押し出し（隣の相手のコマを飛び越える代わりに1マス押し出せる）
+	VariantDouble   = "double"   // 2回行動（1ターンに2回移動するか、移動と壁の配置を1回ずつ行う）
+)
+
+// decayWallTurns - 壁の風化バリアントで壁が消えるまでのターン数（両者が1手ずつ指して1ターン）
+const decayWallTurns = 4
+
+// Ruleset - 対局に適用されるルール
+type Ruleset struct {
+	Name         string `json:"name"`          // ルールセット名（バリアント名）
+	BoardSize    int    `json:"board_size"`    // ボードのサイズ
+	InitialWalls int    `json:"initial_walls"` // 各プレイヤーの壁の初期数
+	Ranked       bool   `json:"ranked"`        // レーティング対象の対局かどうか
+	Victory      string `json:"victory"`       // 勝利条件の名前
+	// 置いた壁が消えるまでのターン数（0 の場合は消えない）
+	WallDecayTurns int `json:"wall_decay_turns,omitempty"`
+	// 隣の相手のコマに向かって移動すると、飛び越える代わりに1マス押し出す
+	Push bool `json:"push,omitempty"`
+	// 1ターンに行える操作の数（壁の配置は1ターンに1回まで）
+	ActionsPerTurn int `json:"actions_per_turn"`
+	// 持ち時間と1手ごとの加算時間（ミリ秒、持ち時間が 0 の場合は時間制限なし）
+	ClockInitialMs   int64 `json:"clock_initial_ms"`
+	ClockIncrementMs int64 `json:"clock_increment_ms"`
+	// 1ターンの制限時間（ミリ秒、0 の場合は制限なし）
+	TurnTimeLimitMs int64 `json:"turn_time_limit_ms"`
+	// 壁の配置も最短手数の更新もないまま続いたら停滞とみなすターン数（0 の場合は検出しない）と、そのときの扱い
+	StaleDrawTurns  int    `json:"stale_draw_turns"`
+	StaleDrawPolicy string `json:"stale_draw_policy,omitempty"`
+}
+
+// VictoryCondition - バリアントごとの勝利条件
+type VictoryCondition interface {
+	// Name - 勝利条件の名前（クライアントへの表示用）
+	Name() string
+	// HasWon - プレイヤーが現在の局面で勝利条件を満たしているかどうかを返す
+	HasWon(gs *GameState, player *Player) bool
+	// Goal - プレイヤーが目指す場所を返す（壁の配置の検証や最短経路の評価に使う）
+	Goal(board *Board, color string) Goal
+}
+
+// goalEdgeVictory - 相手側の端の行（ゴール行）に到達したら勝ち
+type goalEdgeVictory struct{}
+
+func (goalEdgeVictory) Name() string { return "goal_edge" }
+
+func (goalEdgeVictory) HasWon(gs *GameState, player *Player) bool {
+	return player.Position != nil && player.Position.Y == goalRow(player.Color)
+}
+
+func (goalEdgeVictory) Goal(board *Board, color string) Goal {
+	return Goal{Row: goalRow(color)}
+}
+
+// flagSquareVictory - ゴール行の中央にある旗のマスに到達したら勝ち
+type flagSquareVictory struct{}
+
+func (flagSquareVictory) Name() string { return "capture_the_flag" }
+
+func (flagSquareVictory) HasWon(gs *GameState, player *Player) bool {
+	if player.Position == nil {
+		return false
+	}
+	flag := flagSquare(gs.Board, player.Color)
+	return player.Position.X == flag.X && player.Position.Y == flag.Y
+}
+
+func (flagSquareVictory) Goal(board *Board, color string) Goal {
+	flag := flagSquare(board, color)
+	return Goal{Row: flag.Y, Square: &flag}
+}
+
+// flagSquare - プレイヤーの色に対応する旗のマス（ゴール行の中央）を返す
+func flagSquare(board *Board, color string) Position {
+	return Position{X: board.Size / 2, Y: goalRow(color)}
+}
+
+// victoryConditions - バリアントごとの勝利条件
+var victoryConditions = map[string]VictoryCondition{
+	VariantStandard: goalEdgeVictory{},
+	VariantFlag:     flagSquareVictory{},
+	VariantDecay:    goalEdgeVictory{},
+	VariantPush:     goalEdgeVictory{},
+	VariantDouble:   goalEdgeVictory{},
+}
+
+// newRuleset - マッチ作成時のパラメータからルールセットを作成（未知のバリアントは標準ルール）
+func newRuleset(params map[string]interface{}, ranked bool) *Ruleset {
+	variant, _ := params["variant"].(string)
+	// レーティング対象の対局は標準ルールのみ
+	if _, ok := victoryConditions[variant]; !ok || ranked {
+		variant = VariantStandard
+	}
+	ruleset := &Ruleset{
+		Name:           variant,
+		BoardSize:      9,
+		InitialWalls:   InitialWalls,
+		Ranked:         ranked,
+		ActionsPerTurn: 1,
+		Victory:        victoryConditions[variant].Name(),
+	}
+	ruleset.ClockInitialMs, ruleset.ClockIncrementMs = timeControl(params)
+	ruleset.TurnTimeLimitMs = turnTimeLimit(params)
+	ruleset.StaleDrawTurns, ruleset.StaleDrawPolicy = staleDrawSettings(params)
+	switch variant {
+	case VariantDecay:
+		ruleset.WallDecayTurns = decayWallTurns
+	case VariantPush:
+		ruleset.Push = true
+	case VariantDouble:
+		ruleset.ActionsPerTurn = 2
+	}
+	return ruleset
+}
+
+// victoryCondition - 対局のルールセットの勝利条件を返す（未知の場合は標準ルール）
+func (m *QuoridorChessMatch) victoryCondition() VictoryCondition {
+	if condition, ok := victoryConditions[m.ruleset.Name]; ok {
+		return condition
+	}
+	return goalEdgeVictory{}
+}
+
+// goalOf - プレイヤーが勝利条件のために目指す場所を返す
+func (m *QuoridorChessMatch) goalOf(player *Player) Goal {
+	return m.victoryCondition().Goal(m.gameState.Board, player.Color)
+}
+
+// checkVictory - 操作の適用後に勝利条件を評価し、満たしたプレイヤーがいれば対局を終了する
+// 対局が終了した場合は true を返す
+func (m *QuoridorChessMatch) checkVictory(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule) bool {
+	if !m.gameState.GameStarted {
+		return false
+	}
+	condition := m.victoryCondition()
+	for id, player := range m.gameState.Players {
+		if condition.HasWon(m.gameState, player) {
+			m.endGame(ctx, logger, nk, id, ResultReasonGoal)
+			return true
+		}
+	}
+	return false
+}
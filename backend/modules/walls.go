@@ -0,0 +1,118 @@
+// Quoridor Chess 壁の配置
+// クライアントから送られた壁を検証し（溝の位置、他の壁との重なり、ゴールへの経路）、残り壁数を減らしてボードに追加する
+package main
+
+import "github.com/heroiclabs/nakama-common/runtime"
+
+// wallNotation - 壁を棋譜表記（開始点のマス名 + 向き h/v）に変換
+func wallNotation(wall Wall) string {
+	orientation := "v"
+	if wall.Horizontal {
+		orientation = "h"
+	}
+	return squareName(wall.Start.X, wall.Start.Y) + orientation
+}
+
+// positionFromData - メッセージ内の {"x", "y"} を座標に変換（不正な場合は nil）
+func positionFromData(value interface{}) *Position {
+	data, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	x, xOk := data["x"].(float64)
+	y, yOk := data["y"].(float64)
+	if !xOk || !yOk {
+		return nil
+	}
+	return &Position{X: int(x), Y: int(y)}
+}
+
+// wallFromData - place_wall メッセージから壁を取り出す（不正な場合は nil）
+func wallFromData(data map[string]interface{}) *Wall {
+	wallData, ok := data["wall"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	horizontal, _ := wallData["horizontal"].(bool)
+	return &Wall{
+		Start:      positionFromData(wallData["start"]),
+		End:        positionFromData(wallData["end"]),
+		Horizontal: horizontal,
+	}
+}
+
+// handlePlaceWall - 手番のプレイヤーからの壁の配置要求を処理する
+// 受け付けなかった場合は送信者本人に理由を通知する
+func (m *QuoridorChessMatch) handlePlaceWall(dispatcher runtime.MatchDispatcher, userID string, data map[string]interface{}) {
+	if !m.gameState.GameStarted {
+		return
+	}
+	player := m.gameState.Players[userID]
+	if player == nil {
+		return
+	}
+	if reason := m.placeWall(dispatcher, player, data); reason != "" {
+		m.rejectAction(dispatcher, userID, "place_wall", reason)
+	}
+}
+
+// placeWall - 壁を検証して適用する。置けない場合はその理由を返す
+func (m *QuoridorChessMatch) placeWall(dispatcher runtime.MatchDispatcher, player *Player, data map[string]interface{}) string {
+	if player.Walls <= 0 {
+		return "no walls remaining"
+	}
+	if m.wallPlacedThisTurn() {
+		return "only one wall can be placed per turn"
+	}
+
+	wall := wallFromData(data)
+	if wall == nil || !m.gameState.Board.WallOnGrid(*wall) {
+		return "wall is not on the grid"
+	}
+
+	// 配置済みの壁と重なる・交差する壁は置けない
+	if m.gameState.Board.CollidesWithWalls(*wall) {
+		return "wall overlaps or crosses another wall"
+	}
+
+	// どちらかのコマがゴールにたどり着けなくなる壁は置けない
+	if m.wallBlocksPath(*wall) {
+		return "wall blocks a player's path to the goal"
+	}
+
+	m.applyWall(dispatcher, player, *wall)
+	return ""
+}
+
+// wallBlocksPath - 壁を置いた場合に、いずれかのプレイヤーのゴールへの経路がなくなるかどうかを返す
+func (m *QuoridorChessMatch) wallBlocksPath(wall Wall) bool {
+	board := m.gameState.Board.WithWall(wall)
+	for _, player := range m.gameState.Players {
+		if board.ShortestPathTo(player.Position, m.goalOf(player)) < 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// applyWall - 検証済みの壁をボードに追加し、手番を相手に渡す
+func (m *QuoridorChessMatch) applyWall(dispatcher runtime.MatchDispatcher, player *Player, wall Wall) {
+	m.recordMoveTime(player.ID)
+
+	player.Walls--
+	m.wallsPlaced[player.ID]++
+	wall.PlacedPly = m.ply()
+	m.gameState.Board.Walls = append(m.gameState.Board.Walls, wall)
+	m.gameState.LastAction = &ActionHint{
+		Kind:     ActionKindWall,
+		PlayerID: player.ID,
+		Wall:     &wall,
+	}
+	m.gameState.Notation = append(m.gameState.Notation, wallNotation(wall))
+	m.recordMoveLog()
+	m.recordEvent("place_wall", EventSourcePlayer, player.ID, map[string]interface{}{
+		"wall": &wall,
+	})
+
+	m.finishTurn(dispatcher)
+}
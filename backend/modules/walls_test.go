@@ -0,0 +1,67 @@
+package main
+
+import "testing"
+
+func TestWallBlocksPath(t *testing.T) {
+	// 白のゴール行（0行目）の手前を右端の列だけ残して塞ぎ、右端の列も横からは入れないようにしておく
+	whiteCorridor := []Wall{hWall(0, 0), hWall(2, 0), hWall(4, 0), hWall(6, 0), vWall(7, 0)}
+	// 黒のゴール行（8行目）も同様に塞いでおく
+	blackCorridor := []Wall{hWall(0, 7), hWall(2, 7), hWall(4, 7), hWall(6, 7), vWall(7, 7)}
+	tests := []struct {
+		name  string
+		walls []Wall
+		wall  Wall
+		want  bool
+	}{
+		{"どちらの経路も残る", nil, hWall(4, 4), false},
+		{"回り道が残る", whiteCorridor, hWall(6, 3), false},
+		{"白の最後の通り道を塞ぐ", whiteCorridor, hWall(7, 1), true},
+		{"黒の最後の通り道を塞ぐ", blackCorridor, hWall(7, 6), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestMatch(false, Position{X: 4, Y: 4}, Position{X: 0, Y: 4}, tt.walls...)
+			if got := m.wallBlocksPath(tt.wall); got != tt.want {
+				t.Errorf("wallBlocksPath = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// wallData - 壁を place_wall メッセージの形に変換する
+func wallData(wall Wall) map[string]interface{} {
+	return map[string]interface{}{
+		"wall": map[string]interface{}{
+			"start":      map[string]interface{}{"x": float64(wall.Start.X), "y": float64(wall.Start.Y)},
+			"end":        map[string]interface{}{"x": float64(wall.End.X), "y": float64(wall.End.Y)},
+			"horizontal": wall.Horizontal,
+		},
+	}
+}
+
+func TestPlaceWallRejects(t *testing.T) {
+	whiteCorridor := []Wall{hWall(0, 0), hWall(2, 0), hWall(4, 0), hWall(6, 0), vWall(7, 0)}
+	tests := []struct {
+		name  string
+		walls []Wall
+		wall  Wall
+		want  string
+	}{
+		{"盤外", nil, hWall(8, 3), "wall is not on the grid"},
+		{"重なる壁", []Wall{hWall(3, 3)}, hWall(4, 3), "wall overlaps or crosses another wall"},
+		{"交差する壁", []Wall{hWall(3, 3)}, vWall(3, 3), "wall overlaps or crosses another wall"},
+		{"経路を塞ぐ壁", whiteCorridor, hWall(7, 1), "wall blocks a player's path to the goal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestMatch(false, Position{X: 4, Y: 4}, Position{X: 0, Y: 4}, tt.walls...)
+			player := m.gameState.Players["white"]
+			if got := m.placeWall(nil, player, wallData(tt.wall)); got != tt.want {
+				t.Errorf("placeWall = %q, want %q", got, tt.want)
+			}
+			if len(m.gameState.Board.Walls) != len(tt.walls) || player.Walls != InitialWalls {
+				t.Errorf("rejected wall was applied")
+			}
+		})
+	}
+}
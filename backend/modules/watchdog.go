@@ -0,0 +1,222 @@
+// Quoridor Chess 停止したマッチの監視
+// モジュール全体のバックグラウンドジョブが稼働中のマッチに定期的にシグナルで応答を確認し、
+// ティックが進まない・シグナルに応答しない・対局が長時間進まないマッチを検出したら、診断情報を残して運営に知らせ、
+// 結果を記録したうえで強制的に終了させる
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	watchdogInterval     = 30 * time.Second                // 監視の間隔
+	watchdogProbeTimeout = 5 * time.Second                 // 1マッチの応答を待つ時間
+	watchdogMaxMisses    = 3                               // 応答なし・ティックの停止がこの回数続いたら停止とみなす
+	watchdogIdleLimit    = 2 * time.Hour                   // 対局中にこの時間だれも操作しなければ停止とみなす
+	watchdogMatchLimit   = 1000                            // 1回の監視で確認するマッチ数の上限
+	watchdogCollection   = "match_watchdog_reports"        // 診断情報のストレージコレクション（システムが所有、キーはマッチID）
+	watchdogProbeSignal  = `{"type":"watchdog_probe"}`     // 応答確認のシグナル
+	watchdogStopSignal   = `{"type":"watchdog_terminate"}` // 強制終了のシグナル
+)
+
+// 停止とみなした理由
+const (
+	WatchdogReasonUnresponsive = "unresponsive" // シグナルに応答しない
+	WatchdogReasonTickStalled  = "tick_stalled" // ティックが進まない
+	WatchdogReasonIdle         = "idle"         // 対局が長時間進まない
+)
+
+// WatchdogProbe - 応答確認のシグナルに対するマッチの応答
+type WatchdogProbe struct {
+	Tick        int64 `json:"tick"`         // 現在のティック
+	GameStarted bool  `json:"game_started"` // 対局中かどうか
+	Ply         int   `json:"ply"`          // 対局開始からの手数
+	Presences   int   `json:"presences"`    // 接続中の対局者の数
+	Spectators  int   `json:"spectators"`   // 接続中の観戦者の数
+	IdleMs      int64 `json:"idle_ms"`      // 最後の操作からの経過時間（対局中のみ）
+}
+
+// WatchdogReport - 停止したマッチの診断情報
+type WatchdogReport struct {
+	MatchID    string         `json:"match_id"`
+	Reason     string         `json:"reason"`          // 停止とみなした理由
+	Label      string         `json:"label"`           // マッチラベル
+	Size       int32          `json:"size"`            // 接続数
+	Misses     int            `json:"misses"`          // 続けて応答しなかった回数
+	Stalls     int            `json:"stalls"`          // 続けてティックが進まなかった回数
+	LastProbe  *WatchdogProbe `json:"last_probe"`      // 最後に受け取った応答
+	Terminated bool           `json:"terminated"`      // 強制終了できたかどうか
+	Error      string         `json:"error,omitempty"` // 強制終了できなかった理由
+	DetectedAt int64          `json:"detected_at"`     // 検出時刻（Unix時刻）
+}
+
+// watchdogRecord - 監視しているマッチの直近の応答状況
+type watchdogRecord struct {
+	probe  *WatchdogProbe
+	misses int
+	stalls int
+}
+
+// runMatchWatchdog - 稼働中の対局マッチを定期的に確認し、停止したマッチを強制終了するワーカー
+func runMatchWatchdog(logger runtime.Logger, nk runtime.NakamaModule) {
+	ticker := time.NewTicker(watchdogInterval)
+	defer ticker.Stop()
+
+	records := make(map[string]*watchdogRecord)
+	for range ticker.C {
+		ctx := context.Background()
+		matches, err := nk.MatchList(ctx, watchdogMatchLimit, true, "", nil, nil, "")
+		if err != nil {
+			logger.Warn("watchdog: failed to list matches: %v", err)
+			continue
+		}
+
+		seen := make(map[string]bool, len(matches))
+		for _, match := range matches {
+			if match.GetHandlerName() != "quoridor_chess" {
+				continue
+			}
+			matchID := match.GetMatchId()
+			seen[matchID] = true
+			record := records[matchID]
+			if record == nil {
+				record = &watchdogRecord{}
+				records[matchID] = record
+			}
+
+			reason := record.observe(ctx, nk, matchID)
+			if reason == "" {
+				continue
+			}
+			report := &WatchdogReport{
+				MatchID:    matchID,
+				Reason:     reason,
+				Label:      match.GetLabel().GetValue(),
+				Size:       match.GetSize(),
+				Misses:     record.misses,
+				Stalls:     record.stalls,
+				LastProbe:  record.probe,
+				DetectedAt: time.Now().Unix(),
+			}
+			terminateStuckMatch(ctx, logger, nk, report)
+			delete(records, matchID)
+		}
+
+		// 終了したマッチの記録は捨てる
+		for matchID := range records {
+			if !seen[matchID] {
+				delete(records, matchID)
+			}
+		}
+	}
+}
+
+// observe - マッチに応答を確認し、停止とみなした場合はその理由を返す
+func (r *watchdogRecord) observe(ctx context.Context, nk runtime.NakamaModule, matchID string) string {
+	probeCtx, cancel := context.WithTimeout(ctx, watchdogProbeTimeout)
+	defer cancel()
+	result, err := nk.MatchSignal(probeCtx, matchID, watchdogProbeSignal)
+	probe := &WatchdogProbe{}
+	if err == nil {
+		err = json.Unmarshal([]byte(result), probe)
+	}
+	if err != nil {
+		r.misses++
+		if r.misses >= watchdogMaxMisses {
+			return WatchdogReasonUnresponsive
+		}
+		return ""
+	}
+
+	r.misses = 0
+	if r.probe != nil && probe.Tick <= r.probe.Tick {
+		r.stalls++
+	} else {
+		r.stalls = 0
+	}
+	r.probe = probe
+	switch {
+	case r.stalls >= watchdogMaxMisses:
+		return WatchdogReasonTickStalled
+	case probe.GameStarted && time.Duration(probe.IdleMs)*time.Millisecond >= watchdogIdleLimit:
+		return WatchdogReasonIdle
+	}
+	return ""
+}
+
+// terminateStuckMatch - 停止したマッチに強制終了のシグナルを送り、診断情報を保存して運営に知らせる
+// 応答しないマッチは終了させられないため、診断情報と通知だけを残す
+func terminateStuckMatch(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, report *WatchdogReport) {
+	stopCtx, cancel := context.WithTimeout(ctx, watchdogProbeTimeout)
+	defer cancel()
+	if _, err := nk.MatchSignal(stopCtx, report.MatchID, watchdogStopSignal); err != nil {
+		report.Error = err.Error()
+	} else {
+		report.Terminated = true
+	}
+
+	logger.Error("watchdog: match %s is stuck (%s), terminated=%v", report.MatchID, report.Reason, report.Terminated)
+	nk.MetricsCounterAdd("quoridor_stuck_matches", map[string]string{"reason": report.Reason}, 1)
+	writeAuditEvent(ctx, logger, nk, &AuditEvent{
+		Type:    "stuck_match",
+		MatchID: report.MatchID,
+		Details: map[string]interface{}{
+			"reason":     report.Reason,
+			"terminated": report.Terminated,
+		},
+	})
+
+	value, err := json.Marshal(report)
+	if err != nil {
+		logger.Error("watchdog: failed to encode report for match %s: %v", report.MatchID, err)
+		return
+	}
+	if _, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      watchdogCollection,
+		Key:             fmt.Sprintf("%s-%d", report.MatchID, report.DetectedAt),
+		Value:           string(value),
+		PermissionRead:  0,
+		PermissionWrite: 0,
+	}}); err != nil {
+		logger.Error("watchdog: failed to store report for match %s: %v", report.MatchID, err)
+	}
+}
+
+// handleWatchdogProbe - 応答確認のシグナルに現在のティックと対局の進み具合を返す
+func (m *QuoridorChessMatch) handleWatchdogProbe(tick int64) string {
+	probe := &WatchdogProbe{
+		Tick:        tick,
+		GameStarted: m.gameState.GameStarted,
+		Ply:         m.ply(),
+		Presences:   len(m.presences),
+		Spectators:  len(m.spectators),
+	}
+	if m.gameState.GameStarted && !m.turnStartedAt.IsZero() {
+		probe.IdleMs = time.Since(m.turnStartedAt).Milliseconds()
+	}
+	response, _ := json.Marshal(probe)
+	return string(response)
+}
+
+// handleWatchdogTerminate - 停止したマッチを打ち切る。対局中であれば勝者なしで結果を記録し、全員に通知する
+func (m *QuoridorChessMatch) handleWatchdogTerminate(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher) {
+	logger.Warn("Match %s terminated by watchdog", m.matchID)
+	if !m.gameState.GameStarted {
+		return
+	}
+	m.endGame(ctx, logger, nk, "", ResultReasonAborted)
+	msg := map[string]interface{}{
+		"type": "game_over",
+		"data": &GameOverData{
+			Reason:    ResultReasonAborted,
+			GameState: m.gameState,
+		},
+	}
+	m.sendMessage(dispatcher, 1, msg, nil, true)
+	m.flushRelay(logger, nk, true)
+}
@@ -0,0 +1,226 @@
+// Quoridor Chess 対局結果Webhook
+// レーティング対象の対局結果をHMAC署名付きで外部サービスへ送信し、失敗時はストレージのキューから再送する
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	webhookQueueCollection = "webhook_queue"  // 再送待ちWebhookのストレージコレクション
+	webhookMaxAttempts     = 10               // 再送を諦めるまでの最大試行回数
+	webhookRetryInterval   = time.Minute      // 再送ワーカーの実行間隔
+	webhookRequestTimeout  = 10 * time.Second // 1回の送信のタイムアウト
+	webhookSignatureHeader = "X-Quoridor-Signature"
+	webhookTimestampHeader = "X-Quoridor-Timestamp"
+)
+
+// WebhookConfig - Webhookの送信先と署名キー
+type WebhookConfig struct {
+	URL    string
+	Secret string
+}
+
+// ResultWebhookPayload - 外部サービスへ送信する対局結果
+type ResultWebhookPayload struct {
+	MatchID    string                `json:"match_id"`    // マッチID
+	Players    []ResultWebhookPlayer `json:"players"`     // 対局者
+	WinnerID   string                `json:"winner_id"`   // 勝者のユーザーID
+	Notation   []string              `json:"notation"`    // 棋譜
+	Ranked     bool                  `json:"ranked"`      // レーティング対象かどうか
+	FinishedAt int64                 `json:"finished_at"` // 対局終了時刻（Unix時刻）
+}
+
+// ResultWebhookPlayer - 対局結果に含めるプレイヤー情報
+type ResultWebhookPlayer struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Color    string `json:"color"`
+	Result   string `json:"result"` // "win" / "loss" / "draw"
+}
+
+// webhookDelivery - 再送キューに保存する配信情報
+type webhookDelivery struct {
+	Body          string `json:"body"`            // 送信するJSON本文
+	Attempts      int    `json:"attempts"`        // これまでの試行回数
+	NextAttemptAt int64  `json:"next_attempt_at"` // 次回の試行時刻（Unix時刻）
+}
+
+// webhookConfigFromContext - runtime.env からWebhook設定を読み取る
+func webhookConfigFromContext(ctx context.Context) WebhookConfig {
+	return WebhookConfig{
+		URL:    envValue(ctx, EnvResultWebhookURL, ""),
+		Secret: envValue(ctx, EnvResultWebhookSecret, ""),
+	}
+}
+
+// signWebhookBody - 本文とタイムスタンプからHMAC-SHA256署名を作成
+func signWebhookBody(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// postWebhook - 署名付きで1回だけ送信する
+func postWebhook(config WebhookConfig, body []byte) error {
+	timestamp := time.Now().Unix()
+	req, err := http.NewRequest(http.MethodPost, config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	if config.Secret != "" {
+		req.Header.Set(webhookSignatureHeader, signWebhookBody(config.Secret, timestamp, body))
+	}
+
+	client := &http.Client{Timeout: webhookRequestTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// sendResultWebhook - 対局結果を送信する（マッチループを止めないよう非同期で実行）
+func (m *QuoridorChessMatch) sendResultWebhook(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule) {
+	config := webhookConfigFromContext(ctx)
+	if config.URL == "" {
+		return
+	}
+
+	// メモリから外した分を含めた棋譜を送る
+	notation, err := m.fullNotation(ctx, nk)
+	if err != nil {
+		logger.Error("Failed to read notation of match %s: %v", m.matchID, err)
+		return
+	}
+
+	payload := &ResultWebhookPayload{
+		MatchID:    m.matchID,
+		Players:    make([]ResultWebhookPlayer, 0, len(m.gameState.Players)),
+		WinnerID:   m.gameState.Winner,
+		Notation:   notation,
+		Ranked:     m.gameState.Ranked,
+		FinishedAt: time.Now().Unix(),
+	}
+	for id, player := range m.gameState.Players {
+		result := "loss"
+		if id == m.gameState.Winner {
+			result = "win"
+		} else if m.gameState.Winner == "" {
+			result = "draw"
+		}
+		payload.Players = append(payload.Players, ResultWebhookPlayer{
+			ID:       id,
+			Username: player.Username,
+			Color:    player.Color,
+			Result:   result,
+		})
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		logger.Error("webhook: failed to encode result: %v", err)
+		return
+	}
+
+	matchID := m.matchID
+	go func() {
+		if err := postWebhook(config, body); err != nil {
+			logger.Warn("webhook: delivery for match %s failed, queued for retry: %v", matchID, err)
+			queueWebhookDelivery(context.Background(), logger, nk, matchID, &webhookDelivery{
+				Body:          string(body),
+				Attempts:      1,
+				NextAttemptAt: time.Now().Add(webhookRetryInterval).Unix(),
+			})
+		}
+	}()
+}
+
+// queueWebhookDelivery - 配信情報を再送キューに保存
+func queueWebhookDelivery(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, key string, delivery *webhookDelivery) {
+	value, err := json.Marshal(delivery)
+	if err != nil {
+		logger.Error("webhook: failed to encode queued delivery: %v", err)
+		return
+	}
+	if _, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      webhookQueueCollection,
+		Key:             key,
+		Value:           string(value),
+		PermissionRead:  0,
+		PermissionWrite: 0,
+	}}); err != nil {
+		logger.Error("webhook: failed to queue delivery %s: %v", key, err)
+	}
+}
+
+// runWebhookRetryWorker - 再送キューを定期的に処理するワーカー
+// 失敗するたびに待ち時間を倍にし、最大試行回数を超えた配信は破棄する
+func runWebhookRetryWorker(config WebhookConfig, logger runtime.Logger, nk runtime.NakamaModule) {
+	ticker := time.NewTicker(webhookRetryInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx := context.Background()
+		// キューが1ページに収まらない場合もすべての配信を処理する
+		cursor := ""
+		for {
+			objects, next, err := nk.StorageList(ctx, "", "", webhookQueueCollection, 100, cursor)
+			if err != nil {
+				logger.Warn("webhook: failed to list retry queue: %v", err)
+				break
+			}
+
+			now := time.Now()
+			for _, object := range objects {
+				delivery := &webhookDelivery{}
+				if err := json.Unmarshal([]byte(object.GetValue()), delivery); err != nil {
+					continue
+				}
+				if delivery.NextAttemptAt > now.Unix() {
+					continue
+				}
+
+				err := postWebhook(config, []byte(delivery.Body))
+				if err == nil || delivery.Attempts+1 >= webhookMaxAttempts {
+					if err != nil {
+						logger.Error("webhook: giving up on delivery %s after %d attempts: %v", object.GetKey(), delivery.Attempts+1, err)
+					}
+					if err := nk.StorageDelete(ctx, []*runtime.StorageDelete{{
+						Collection: webhookQueueCollection,
+						Key:        object.GetKey(),
+					}}); err != nil {
+						logger.Warn("webhook: failed to remove delivery %s from queue: %v", object.GetKey(), err)
+					}
+					continue
+				}
+
+				delivery.Attempts++
+				delivery.NextAttemptAt = now.Add(webhookRetryInterval * time.Duration(1<<uint(delivery.Attempts))).Unix()
+				queueWebhookDelivery(ctx, logger, nk, object.GetKey(), delivery)
+			}
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+	}
+}